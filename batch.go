@@ -0,0 +1,161 @@
+package levels
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+type valueKind byte
+
+const (
+	kindValue valueKind = iota
+	kindDeletion
+)
+
+// encodeValue prefixes value with its kind so that a deletion can be stored in a memtable or table
+// and shadow older values for the same key.
+func encodeValue(kind valueKind, value []byte) []byte {
+	encoded := make([]byte, 1+len(value))
+	encoded[0] = byte(kind)
+	copy(encoded[1:], value)
+	return encoded
+}
+
+func decodeValue(encoded []byte) (kind valueKind, value []byte, err error) {
+	if len(encoded) == 0 {
+		return 0, nil, fmt.Errorf("corrupted value: missing kind")
+	}
+
+	kind = valueKind(encoded[0])
+	if kind != kindValue && kind != kindDeletion {
+		return 0, nil, fmt.Errorf("corrupted value: unknown kind %d", kind)
+	}
+
+	return kind, encoded[1:], nil
+}
+
+// isDeletion reports whether an encoded value marks a deleted key.
+func isDeletion(encoded []byte) bool {
+	return len(encoded) > 0 && valueKind(encoded[0]) == kindDeletion
+}
+
+type batchOp struct {
+	kind valueKind
+
+	// cf is the column family written to, which is nil for batches read back from the log, and
+	// family its name.
+	cf     *ColumnFamily
+	family string
+
+	key   []byte
+	value []byte
+}
+
+// WriteBatch holds a sequence of puts and deletes, possibly across several column families, that
+// are applied atomically by Database.Write.
+type WriteBatch struct {
+	ops []batchOp
+}
+
+func NewWriteBatch() *WriteBatch {
+	return &WriteBatch{}
+}
+
+// Put records setting the value for the given key in the column family.
+func (b *WriteBatch) Put(cf *ColumnFamily, key, value []byte) {
+	b.ops = append(b.ops, batchOp{kind: kindValue, cf: cf, family: cf.name, key: key, value: value})
+}
+
+// Delete records deleting the given key from the column family.
+func (b *WriteBatch) Delete(cf *ColumnFamily, key []byte) {
+	b.ops = append(b.ops, batchOp{kind: kindDeletion, cf: cf, family: cf.name, key: key})
+}
+
+// Len returns the number of operations in the batch.
+func (b *WriteBatch) Len() int {
+	return len(b.ops)
+}
+
+// Reset removes all operations from the batch so it can be reused.
+func (b *WriteBatch) Reset() {
+	b.ops = b.ops[:0]
+}
+
+func appendBytes(buf, b []byte) []byte {
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(b)))
+	return append(buf, b...)
+}
+
+func (b *WriteBatch) encode() []byte {
+	buf := binary.LittleEndian.AppendUint32(nil, uint32(len(b.ops)))
+
+	for _, op := range b.ops {
+		buf = append(buf, byte(op.kind))
+		buf = appendBytes(buf, []byte(op.family))
+		buf = appendBytes(buf, op.key)
+		buf = appendBytes(buf, op.value)
+	}
+
+	return buf
+}
+
+func readBytes(buf []byte) (b, rest []byte, err error) {
+	if len(buf) < 4 {
+		return nil, nil, fmt.Errorf("corrupted batch: truncated length")
+	}
+
+	n := binary.LittleEndian.Uint32(buf)
+	buf = buf[4:]
+
+	if uint64(n) > uint64(len(buf)) {
+		return nil, nil, fmt.Errorf("corrupted batch: length %d exceeds remaining %d bytes", n, len(buf))
+	}
+
+	return buf[:n], buf[n:], nil
+}
+
+func decodeBatch(buf []byte) (*WriteBatch, error) {
+	if len(buf) < 4 {
+		return nil, fmt.Errorf("corrupted batch: truncated count")
+	}
+
+	count := binary.LittleEndian.Uint32(buf)
+	buf = buf[4:]
+
+	b := &WriteBatch{}
+
+	for i := uint32(0); i < count; i++ {
+		if len(buf) < 1 {
+			return nil, fmt.Errorf("corrupted batch: truncated operation %d", i)
+		}
+
+		op := batchOp{kind: valueKind(buf[0])}
+		if op.kind != kindValue && op.kind != kindDeletion {
+			return nil, fmt.Errorf("corrupted batch: unknown kind %d", op.kind)
+		}
+
+		family, rest, err := readBytes(buf[1:])
+		if err != nil {
+			return nil, err
+		}
+
+		op.key, rest, err = readBytes(rest)
+		if err != nil {
+			return nil, err
+		}
+
+		op.value, buf, err = readBytes(rest)
+		if err != nil {
+			return nil, err
+		}
+
+		op.family = string(family)
+		b.ops = append(b.ops, op)
+	}
+
+	if len(buf) != 0 {
+		return nil, fmt.Errorf("corrupted batch: %d trailing bytes", len(buf))
+	}
+
+	return b, nil
+}
@@ -0,0 +1,194 @@
+package levels
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/savarin/levels/internal/bench/fixture"
+)
+
+func openBenchDatabase(b *testing.B) (*Database, *ColumnFamily) {
+	db, err := OpenDatabase(b.TempDir(), DatabaseOptions{})
+	if err != nil {
+		b.Fatalf("unexpected error when opening database: %s", err)
+	}
+
+	b.Cleanup(func() { db.Close() })
+
+	cf, err := db.ColumnFamily(DefaultColumnFamily)
+	if err != nil {
+		b.Fatalf("unexpected error when opening column family: %s", err)
+	}
+
+	return db, cf
+}
+
+// fill puts every key, then flushes them to an SSTable if flush is set.
+func fill(b *testing.B, d fixture.Data, db *Database, cf *ColumnFamily, flush bool) {
+	for _, i := range d.Order {
+		err := db.Put(cf, d.Keys[i], d.Value)
+		if err != nil {
+			b.Fatalf("unexpected error when putting: %s", err)
+		}
+	}
+
+	if flush {
+		err := db.Flush()
+		if err != nil {
+			b.Fatalf("unexpected error when flushing: %s", err)
+		}
+	}
+}
+
+// runDatabases runs f for every key count and value size, with the keys in the memtable and
+// flushed to an SSTable.
+func runDatabases(b *testing.B, f func(b *testing.B, d fixture.Data, flush bool)) {
+	for _, flush := range []bool{false, true} {
+		location := "memtable"
+		if flush {
+			location = "table"
+		}
+
+		for _, n := range fixture.KeyCounts {
+			for _, size := range fixture.ValueSizes {
+				b.Run(fmt.Sprintf("%s/keys=%d/value=%d", location, n, size), func(b *testing.B) {
+					d := fixture.New(n, size)
+
+					b.ReportAllocs()
+					f(b, d, flush)
+				})
+			}
+		}
+	}
+}
+
+// BenchmarkDatabasePut puts keys in random order, so that the memtable is flushed as it fills.
+func BenchmarkDatabasePut(b *testing.B) {
+	for _, size := range fixture.ValueSizes {
+		b.Run(fmt.Sprintf("value=%d", size), func(b *testing.B) {
+			d := fixture.New(10000, size)
+			db, cf := openBenchDatabase(b)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				err := db.Put(cf, d.Keys[d.Order[i%len(d.Keys)]], d.Value)
+				if err != nil {
+					b.Fatalf("unexpected error when putting: %s", err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkDatabaseGet(b *testing.B) {
+	b.Run("hit", func(b *testing.B) {
+		runDatabases(b, func(b *testing.B, d fixture.Data, flush bool) {
+			db, cf := openBenchDatabase(b)
+			fill(b, d, db, cf, flush)
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				_, err := db.Get(cf, d.Keys[d.Order[i%len(d.Keys)]])
+				if err != nil {
+					b.Fatalf("unexpected error when getting: %s", err)
+				}
+			}
+		})
+	})
+
+	b.Run("miss", func(b *testing.B) {
+		runDatabases(b, func(b *testing.B, d fixture.Data, flush bool) {
+			db, cf := openBenchDatabase(b)
+			fill(b, d, db, cf, flush)
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				_, err := db.Get(cf, d.Misses[d.Order[i%len(d.Keys)]])
+				if err == nil {
+					b.Fatalf("expected error when getting missing key")
+				}
+			}
+		})
+	})
+}
+
+// BenchmarkDatabaseDelete writes deletions for keys in random order, which like puts are appended
+// to the write-ahead log and memtable.
+func BenchmarkDatabaseDelete(b *testing.B) {
+	runDatabases(b, func(b *testing.B, d fixture.Data, flush bool) {
+		db, cf := openBenchDatabase(b)
+		fill(b, d, db, cf, flush)
+		b.ResetTimer()
+
+		for i := 0; i < b.N; i++ {
+			err := db.Delete(cf, d.Keys[d.Order[i%len(d.Keys)]])
+			if err != nil {
+				b.Fatalf("unexpected error when deleting: %s", err)
+			}
+		}
+	})
+}
+
+// BenchmarkDatabaseRangeScan scans ranges of each width from random starting keys, reading every
+// pair.
+func BenchmarkDatabaseRangeScan(b *testing.B) {
+	for _, width := range fixture.ScanWidths {
+		b.Run(fmt.Sprintf("width=%d", width), func(b *testing.B) {
+			runDatabases(b, func(b *testing.B, d fixture.Data, flush bool) {
+				if width >= len(d.Keys) {
+					b.Skip("range wider than the database")
+				}
+
+				db, cf := openBenchDatabase(b)
+				fill(b, d, db, cf, flush)
+				b.ResetTimer()
+
+				for i := 0; i < b.N; i++ {
+					start := d.Order[i%len(d.Keys)] % (len(d.Keys) - width)
+
+					iter, err := db.RangeScan(cf, d.Keys[start], d.Keys[start+width])
+					if err != nil {
+						b.Fatalf("unexpected error when scanning: %s", err)
+					}
+
+					for key := iter.Key(); key != nil; key = iter.Key() {
+						iter.Value()
+
+						if !iter.Next() {
+							break
+						}
+					}
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkDatabaseFlush flushes a memtable of each key count to an SSTable, filling it again in
+// between.
+func BenchmarkDatabaseFlush(b *testing.B) {
+	for _, n := range fixture.KeyCounts {
+		for _, size := range fixture.ValueSizes {
+			b.Run(fmt.Sprintf("keys=%d/value=%d", n, size), func(b *testing.B) {
+				d := fixture.New(n, size)
+				db, cf := openBenchDatabase(b)
+
+				b.ReportAllocs()
+				b.ResetTimer()
+
+				for i := 0; i < b.N; i++ {
+					b.StopTimer()
+					fill(b, d, db, cf, false)
+					b.StartTimer()
+
+					err := db.Flush()
+					if err != nil {
+						b.Fatalf("unexpected error when flushing: %s", err)
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,355 @@
+// Command dbbench runs named workloads over synthetic keys against each DB implementation and
+// reports their throughput and latency, in the manner of LevelDB's db_bench.
+//
+// Usage:
+//
+//	dbbench [-benchmarks fillseq,readrandom,...] [-db simple,skiplist,...] [-num n] [-reads n]
+//	        [-key_size n] [-value_size n] [-seek_nexts n] [-seed n] [-dir path] [-sync]
+//
+// Benchmarks run in the order given, each against the state left by the ones before it, except
+// that fillseq and fillrandom start from an empty DB. They are:
+//
+//	fillseq       put num keys in ascending order
+//	fillrandom    put num keys in random order
+//	overwrite     put num random keys, replacing existing values
+//	readrandom    get reads random keys
+//	readseq       scan reads pairs from the start of the keys
+//	seekrandom    scan seek_nexts pairs from reads random keys
+//	deleterandom  delete num random keys
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/savarin/levels"
+	"github.com/savarin/levels/internal/bench"
+)
+
+type config struct {
+	num       int
+	reads     int
+	keySize   int
+	valueSize int
+	seekNexts int
+	seed      int64
+	dir       string
+	sync      bool
+}
+
+// runner times the operations of a single benchmark.
+type runner struct {
+	store  bench.Store
+	cfg    config
+	rng    *rand.Rand
+	values *bench.Values
+
+	hist  bench.Histogram
+	bytes int64
+	found int
+}
+
+// time runs and records a single operation.
+func (r *runner) time(op func() error) error {
+	start := time.Now()
+	err := op()
+	r.hist.Record(time.Since(start))
+	return err
+}
+
+func (r *runner) key(n int) []byte {
+	return bench.Key(uint64(n), r.cfg.keySize)
+}
+
+func (r *runner) randomKey() []byte {
+	return r.key(r.rng.Intn(r.cfg.num))
+}
+
+func (r *runner) put(key []byte) error {
+	value := r.values.Next()
+	r.bytes += int64(len(key) + len(value))
+
+	return r.time(func() error { return r.store.Put(key, value) })
+}
+
+func fill(random bool) func(r *runner) error {
+	return func(r *runner) error {
+		for i := 0; i < r.cfg.num; i++ {
+			key := r.key(i)
+			if random {
+				key = r.randomKey()
+			}
+
+			err := r.put(key)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+func readRandom(r *runner) error {
+	for i := 0; i < r.cfg.reads; i++ {
+		key := r.randomKey()
+
+		err := r.time(func() error {
+			value, err := r.store.Get(key)
+			if errors.Is(err, levels.KeyError) {
+				return nil
+			}
+
+			if err == nil {
+				r.found++
+				r.bytes += int64(len(key) + len(value))
+			}
+
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readSeq records the time to read each pair, with the first including the time to start the scan.
+func readSeq(r *runner) error {
+	start := time.Now()
+
+	iter, err := r.store.RangeScan(nil, nil)
+	if err != nil {
+		return err
+	}
+
+	for key := iter.Key(); key != nil && r.found < r.cfg.reads; key = iter.Key() {
+		r.bytes += int64(len(key) + len(iter.Value()))
+		r.found++
+
+		more := iter.Next()
+
+		r.hist.Record(time.Since(start))
+		start = time.Now()
+
+		if !more {
+			break
+		}
+	}
+
+	return iter.Error()
+}
+
+func seekRandom(r *runner) error {
+	for i := 0; i < r.cfg.reads; i++ {
+		n := r.rng.Intn(r.cfg.num)
+		target := r.key(n)
+
+		err := r.time(func() error {
+			iter, err := r.store.RangeScan(target, r.key(n+r.cfg.seekNexts))
+			if err != nil {
+				return err
+			}
+
+			if string(iter.Key()) == string(target) {
+				r.found++
+			}
+
+			for key := iter.Key(); key != nil; key = iter.Key() {
+				r.bytes += int64(len(key) + len(iter.Value()))
+
+				if !iter.Next() {
+					break
+				}
+			}
+
+			return iter.Error()
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func deleteRandom(r *runner) error {
+	for i := 0; i < r.cfg.num; i++ {
+		key := r.randomKey()
+
+		err := r.time(func() error { return r.store.Delete(key) })
+		if err != nil && !errors.Is(err, levels.KeyError) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+var benchmarks = map[string]struct {
+	// fresh benchmarks start from an empty DB.
+	fresh bool
+	reads bool
+	run   func(r *runner) error
+}{
+	"fillseq":      {fresh: true, run: fill(false)},
+	"fillrandom":   {fresh: true, run: fill(true)},
+	"overwrite":    {run: fill(true)},
+	"readrandom":   {reads: true, run: readRandom},
+	"readseq":      {reads: true, run: readSeq},
+	"seekrandom":   {reads: true, run: seekRandom},
+	"deleterandom": {run: deleteRandom},
+}
+
+func report(w io.Writer, name string, r *runner, elapsed time.Duration) {
+	ops := r.hist.Count()
+	seconds := elapsed.Seconds()
+
+	var micros, opsPerSec, mbPerSec float64
+	if ops > 0 && seconds > 0 {
+		micros = seconds * 1e6 / float64(ops)
+		opsPerSec = float64(ops) / seconds
+		mbPerSec = float64(r.bytes) / (1 << 20) / seconds
+	}
+
+	fmt.Fprintf(w, "  %-12s : %10.3f micros/op %10.0f ops/sec %8.1f MB/s  %s", name, micros, opsPerSec, mbPerSec, &r.hist)
+
+	if benchmarks[name].reads && name != "readseq" {
+		fmt.Fprintf(w, "  (%d of %d found)", r.found, ops)
+	}
+
+	fmt.Fprintln(w)
+}
+
+// runImplementation runs the benchmarks in order against one DB implementation.
+func runImplementation(w io.Writer, names []string, impl bench.Implementation, cfg config) error {
+	var s bench.Store
+	defer func() {
+		if s != nil {
+			s.Close()
+		}
+	}()
+
+	for i, name := range names {
+		b := benchmarks[name]
+
+		if s == nil || b.fresh {
+			if s != nil {
+				err := s.Close()
+				if err != nil {
+					return err
+				}
+			}
+
+			var err error
+			s, err = impl.Open(cfg.dir, cfg.sync)
+			if err != nil {
+				return err
+			}
+		}
+
+		seed := cfg.seed + int64(i)
+		r := &runner{
+			store:  s,
+			cfg:    cfg,
+			rng:    rand.New(rand.NewSource(seed)),
+			values: bench.NewValues(seed, cfg.valueSize),
+		}
+
+		start := time.Now()
+
+		err := b.run(r)
+		if err != nil {
+			return fmt.Errorf("running %s: %w", name, err)
+		}
+
+		report(w, name, r, time.Since(start))
+	}
+
+	return nil
+}
+
+func run(args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("dbbench", flag.ContinueOnError)
+	fs.SetOutput(w)
+
+	names := fs.String("benchmarks", "fillseq,fillrandom,overwrite,readrandom,readseq,seekrandom,deleterandom", "comma-separated benchmarks to run")
+	dbs := fs.String("db", "simple,linkedlist,skiplist,database", "comma-separated DB implementations to benchmark")
+
+	var cfg config
+	fs.IntVar(&cfg.num, "num", 10000, "number of keys")
+	fs.IntVar(&cfg.reads, "reads", -1, "number of reads, or num if negative")
+	fs.IntVar(&cfg.keySize, "key_size", 16, "size of each key")
+	fs.IntVar(&cfg.valueSize, "value_size", 100, "size of each value")
+	fs.IntVar(&cfg.seekNexts, "seek_nexts", 10, "number of keys covered by each seekrandom scan")
+	fs.Int64Var(&cfg.seed, "seed", 301, "seed for random keys and values")
+	fs.StringVar(&cfg.dir, "dir", "", "directory for database benchmarks, defaulting to the temporary directory")
+	fs.BoolVar(&cfg.sync, "sync", false, "sync the write-ahead log on every database write")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if cfg.num <= 0 || cfg.keySize <= 0 || cfg.valueSize < 0 || cfg.seekNexts <= 0 {
+		return fmt.Errorf("num, key_size and seek_nexts must be positive and value_size not negative")
+	}
+
+	// Scans stop at the key seek_nexts past the last one, so it must fit in key_size too.
+	if minKeySize := bench.MinKeySize(uint64(cfg.num + cfg.seekNexts)); cfg.keySize < minKeySize {
+		return fmt.Errorf("key_size %d is too small for %d keys, which need %d bytes", cfg.keySize, cfg.num, minKeySize)
+	}
+
+	if cfg.reads < 0 {
+		cfg.reads = cfg.num
+	}
+
+	benchmarkNames := strings.Split(*names, ",")
+	for _, name := range benchmarkNames {
+		if _, ok := benchmarks[name]; !ok {
+			return fmt.Errorf("unknown benchmark %q", name)
+		}
+	}
+
+	var selected []bench.Implementation
+	for _, name := range strings.Split(*dbs, ",") {
+		impl, ok := bench.FindImplementation(name)
+		if !ok {
+			return fmt.Errorf("unknown DB implementation %q", name)
+		}
+
+		selected = append(selected, impl)
+	}
+
+	fmt.Fprintf(w, "keys: %d bytes each, values: %d bytes each, entries: %d, reads: %d\n", cfg.keySize, cfg.valueSize, cfg.num, cfg.reads)
+
+	for _, impl := range selected {
+		fmt.Fprintf(w, "%s:\n", impl.Name)
+
+		err := runImplementation(w, benchmarkNames, impl, cfg)
+		if err != nil {
+			return fmt.Errorf("benchmarking %s: %w", impl.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func main() {
+	err := run(os.Args[1:], os.Stdout)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "dbbench: %s\n", err)
+		os.Exit(1)
+	}
+}
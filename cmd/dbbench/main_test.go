@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/savarin/levels/internal/bench"
+)
+
+func TestRun(t *testing.T) {
+	var buf bytes.Buffer
+
+	err := run([]string{"-num", "100", "-dir", t.TempDir()}, &buf)
+	if err != nil {
+		t.Fatalf("unexpected error when running benchmarks: %s", err)
+	}
+
+	for _, impl := range bench.Implementations {
+		if !strings.Contains(buf.String(), impl.Name+":\n") {
+			t.Fatalf("expected results for %s in output:\n%s", impl.Name, buf.String())
+		}
+	}
+
+	for name := range benchmarks {
+		if strings.Count(buf.String(), "  "+name+" ") != len(bench.Implementations) {
+			t.Fatalf("expected results for %s in output:\n%s", name, buf.String())
+		}
+	}
+
+	err = run([]string{"-benchmarks", "fillsideways"}, &buf)
+	if err == nil {
+		t.Fatalf("expected error when running unknown benchmark")
+	}
+
+	// Keys numbered up to 109 need three digits.
+	err = run([]string{"-num", "100", "-key_size", "2"}, &buf)
+	if err == nil || !strings.Contains(err.Error(), "key_size") {
+		t.Fatalf("expected error when keys do not fit in key_size, got %v", err)
+	}
+}
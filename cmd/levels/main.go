@@ -0,0 +1,694 @@
+// Command levels inspects and edits an SSTable written by Flush, or a database directory.
+//
+// Usage:
+//
+//	levels get [-cf name] [-hex] <path> <key>
+//	levels put [-cf name] <dir> <key> <value>
+//	levels delete [-cf name] <dir> <key>
+//	levels scan [-cf name] [-hex] [-start key] [-limit key] [-prefix prefix] <path>
+//	levels dump [-hex] <path>
+//	levels stats <path>
+//	levels verify <path>
+//	levels repair <table> <output>
+//
+// Commands that only read a database directory open it read-only: its write-ahead log is replayed
+// in memory, and the directory is left exactly as it was. Only put and delete change it.
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/savarin/levels"
+	"github.com/savarin/levels/iterator"
+	"github.com/savarin/levels/table"
+)
+
+var (
+	usageError = errors.New("Invalid usage")
+)
+
+const usage = `usage: levels <command> [flags] <path> [args]
+
+commands:
+  get     print the value of a key
+  put     set the value of a key in a database
+  delete  delete a key from a database
+  scan    print the pairs in a range of keys
+  dump    print every pair, of every column family of a database
+  stats   print the properties of an SSTable, or the tables of a database
+  verify  check every block of a table, or every table and pair of a database
+  repair  copy the readable pairs of a damaged table into a new table
+`
+
+func main() {
+	err := run(os.Args[1:], os.Stdout)
+
+	if errors.Is(err, usageError) {
+		fmt.Fprint(os.Stderr, usage)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "levels: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, w io.Writer) error {
+	if len(args) == 0 {
+		return usageError
+	}
+
+	commands := map[string]func(args []string, w io.Writer) error{
+		"get":    get,
+		"put":    put,
+		"delete": del,
+		"scan":   scan,
+		"dump":   dump,
+		"stats":  stats,
+		"verify": verify,
+		"repair": repair,
+	}
+
+	command, ok := commands[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown command %q: %w", args[0], usageError)
+	}
+
+	return command(args[1:], w)
+}
+
+// parse parses the flags of a command, and checks that it is given the expected number of
+// positional arguments.
+func parse(fs *flag.FlagSet, args []string, positional ...string) ([]string, error) {
+	fs.SetOutput(io.Discard)
+
+	err := fs.Parse(args)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s: %w", fs.Name(), err, usageError)
+	}
+
+	if fs.NArg() != len(positional) {
+		return nil, fmt.Errorf("%s expects %v: %w", fs.Name(), positional, usageError)
+	}
+
+	return fs.Args(), nil
+}
+
+// source is an SSTable or a column family of a database, opened for reading.
+type source struct {
+	levels.ImmutableDB
+
+	table *table.Table
+	db    *levels.Database
+	file  *os.File
+}
+
+func (s *source) Close() error {
+	if s.db != nil {
+		return s.db.Close()
+	}
+
+	return s.file.Close()
+}
+
+// open opens the SSTable or database at path for reading. For a database, cf names the column
+// family to read.
+func open(path, cf string) (*source, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.IsDir() {
+		db, err := levels.OpenDatabase(path, levels.DatabaseOptions{ReadOnly: true})
+		if err != nil {
+			return nil, err
+		}
+
+		family, err := db.ColumnFamily(cf)
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("opening column family %q: %w", cf, err)
+		}
+
+		return &source{ImmutableDB: db.Reader(family), db: db}, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := table.OpenWithOptions(f, table.Options{})
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("opening table %s: %w", path, err)
+	}
+
+	return &source{ImmutableDB: t, table: t.(*table.Table), file: f}, nil
+}
+
+// openDatabase opens the existing database in dir, along with the column family named cf.
+func openDatabase(dir, cf string) (*levels.Database, *levels.ColumnFamily, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !info.IsDir() {
+		return nil, nil, fmt.Errorf("%s is an SSTable, which cannot be modified", dir)
+	}
+
+	db, err := levels.OpenDatabase(dir, levels.DatabaseOptions{SyncWrites: true})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	family, err := db.ColumnFamily(cf)
+	if err != nil {
+		db.Close()
+		return nil, nil, fmt.Errorf("opening column family %q: %w", cf, err)
+	}
+
+	return db, family, nil
+}
+
+// format returns b as hex, or as text if it is printable and quoted otherwise.
+func format(b []byte, asHex bool) string {
+	if asHex {
+		return hex.EncodeToString(b)
+	}
+
+	if utf8.Valid(b) {
+		printable := true
+
+		for _, r := range string(b) {
+			if !strconv.IsPrint(r) {
+				printable = false
+				break
+			}
+		}
+
+		if printable {
+			return string(b)
+		}
+	}
+
+	return strconv.Quote(string(b))
+}
+
+func get(args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("get", flag.ContinueOnError)
+	cf := fs.String("cf", levels.DefaultColumnFamily, "column family of a database")
+	asHex := fs.Bool("hex", false, "print the value as hex")
+
+	args, err := parse(fs, args, "path", "key")
+	if err != nil {
+		return err
+	}
+
+	s, err := open(args[0], *cf)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	value, err := s.Get([]byte(args[1]))
+	if err != nil {
+		return fmt.Errorf("getting %q: %w", args[1], err)
+	}
+
+	fmt.Fprintln(w, format(value, *asHex))
+	return nil
+}
+
+func put(args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("put", flag.ContinueOnError)
+	cf := fs.String("cf", levels.DefaultColumnFamily, "column family")
+
+	args, err := parse(fs, args, "dir", "key", "value")
+	if err != nil {
+		return err
+	}
+
+	db, family, err := openDatabase(args[0], *cf)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.Put(family, []byte(args[1]), []byte(args[2]))
+}
+
+func del(args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("delete", flag.ContinueOnError)
+	cf := fs.String("cf", levels.DefaultColumnFamily, "column family")
+
+	args, err := parse(fs, args, "dir", "key")
+	if err != nil {
+		return err
+	}
+
+	db, family, err := openDatabase(args[0], *cf)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.Delete(family, []byte(args[1]))
+}
+
+// prefixLimit returns the smallest key greater than every key starting with prefix, or nil if
+// there is none.
+func prefixLimit(prefix []byte) []byte {
+	limit := append([]byte(nil), prefix...)
+
+	for i := len(limit) - 1; i >= 0; i-- {
+		if limit[i] < 0xff {
+			limit[i]++
+			return limit[:i+1]
+		}
+	}
+
+	return nil
+}
+
+// each calls f for every pair in the given range of db.
+func each(db levels.ImmutableDB, start, limit []byte, f func(iter iterator.Iterator)) error {
+	iter, err := db.RangeScan(start, limit)
+	if err != nil {
+		return err
+	}
+
+	for key := iter.Key(); key != nil; key = iter.Key() {
+		if len(limit) > 0 && bytes.Compare(key, limit) >= 0 {
+			break
+		}
+
+		f(iter)
+
+		if !iter.Next() {
+			break
+		}
+	}
+
+	return iter.Error()
+}
+
+func scan(args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("scan", flag.ContinueOnError)
+	cf := fs.String("cf", levels.DefaultColumnFamily, "column family of a database")
+	asHex := fs.Bool("hex", false, "print keys and values as hex")
+	start := fs.String("start", "", "first key to print")
+	limit := fs.String("limit", "", "key to stop before")
+	prefix := fs.String("prefix", "", "only print keys starting with prefix")
+
+	args, err := parse(fs, args, "path")
+	if err != nil {
+		return err
+	}
+
+	startKey, limitKey := []byte(*start), []byte(*limit)
+
+	if *prefix != "" {
+		if *prefix > *start {
+			startKey = []byte(*prefix)
+		}
+
+		end := prefixLimit([]byte(*prefix))
+		if end != nil && (len(limitKey) == 0 || bytes.Compare(end, limitKey) < 0) {
+			limitKey = end
+		}
+	}
+
+	if len(limitKey) > 0 && bytes.Compare(startKey, limitKey) >= 0 {
+		return nil
+	}
+
+	s, err := open(args[0], *cf)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	return each(s, startKey, limitKey, func(iter iterator.Iterator) {
+		if *prefix == "" || bytes.HasPrefix(iter.Key(), []byte(*prefix)) {
+			fmt.Fprintf(w, "%s => %s\n", format(iter.Key(), *asHex), format(iter.Value(), *asHex))
+		}
+	})
+}
+
+func dump(args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("dump", flag.ContinueOnError)
+	asHex := fs.Bool("hex", false, "print a hex dump of every key and value")
+
+	args, err := parse(fs, args, "path")
+	if err != nil {
+		return err
+	}
+
+	s, err := open(args[0], levels.DefaultColumnFamily)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	print := func(iter iterator.Iterator) {
+		if *asHex {
+			fmt.Fprintf(w, "key:\n%svalue:\n%s", hex.Dump(iter.Key()), hex.Dump(iter.Value()))
+			return
+		}
+
+		fmt.Fprintf(w, "%s => %s", format(iter.Key(), false), format(iter.Value(), false))
+
+		if expiry := iterator.Expiry(iter); expiry != 0 {
+			fmt.Fprintf(w, " (expires %s)", time.Unix(0, expiry).UTC().Format(time.RFC3339))
+		}
+
+		fmt.Fprintln(w)
+	}
+
+	if s.db == nil {
+		return each(s, nil, nil, print)
+	}
+
+	for _, name := range s.db.ListColumnFamilies() {
+		cf, err := s.db.ColumnFamily(name)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(w, "[%s]\n", name)
+
+		err = each(s.db.Reader(cf), nil, nil, print)
+		if err != nil {
+			return fmt.Errorf("dumping column family %q: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+func printProperties(w io.Writer, indent string, p *table.Properties) {
+	fmt.Fprintf(w, "%sentries: %d\n", indent, p.NumEntries)
+	fmt.Fprintf(w, "%sdeletions: %d\n", indent, p.NumDeletions)
+	fmt.Fprintf(w, "%sraw key size: %d\n", indent, p.RawKeySize)
+	fmt.Fprintf(w, "%sraw value size: %d\n", indent, p.RawValueSize)
+	fmt.Fprintf(w, "%sdata size: %d\n", indent, p.DataSize)
+	fmt.Fprintf(w, "%sindex size: %d\n", indent, p.IndexSize)
+	fmt.Fprintf(w, "%ssmallest key: %s\n", indent, format(p.SmallestKey, false))
+	fmt.Fprintf(w, "%slargest key: %s\n", indent, format(p.LargestKey, false))
+	fmt.Fprintf(w, "%screated: %s\n", indent, p.CreationTime.UTC().Format(time.RFC3339))
+	fmt.Fprintf(w, "%scomparator: %s\n", indent, p.Comparator)
+	fmt.Fprintf(w, "%scompression: %s\n", indent, p.Compression)
+	fmt.Fprintf(w, "%sformat version: %d\n", indent, p.FormatVersion)
+
+	names := make([]string, 0, len(p.User))
+	for name := range p.User {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(w, "%s%s: %s\n", indent, name, format(p.User[name], false))
+	}
+}
+
+func stats(args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("stats", flag.ContinueOnError)
+
+	args, err := parse(fs, args, "path")
+	if err != nil {
+		return err
+	}
+
+	s, err := open(args[0], levels.DefaultColumnFamily)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	if s.db == nil {
+		info, err := s.file.Stat()
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(w, "file size: %d\n", info.Size())
+		fmt.Fprintf(w, "format version: %d\n", s.table.FormatVersion())
+
+		p, err := s.table.Properties()
+		if errors.Is(err, levels.KeyError) {
+			fmt.Fprintln(w, "no properties")
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		printProperties(w, "", p)
+		return nil
+	}
+
+	for _, name := range s.db.ListColumnFamilies() {
+		cf, err := s.db.ColumnFamily(name)
+		if err != nil {
+			return err
+		}
+
+		props, err := s.db.TableProperties(cf)
+		if err != nil {
+			return err
+		}
+
+		var entries, size uint64
+		for _, p := range props {
+			entries += p.NumEntries
+			size += p.DataSize + p.IndexSize
+		}
+
+		fmt.Fprintf(w, "column family %s: %d tables, %d entries, %d bytes\n", name, len(props), entries, size)
+
+		for i, p := range props {
+			fmt.Fprintf(w, "  table %d:\n", i)
+			printProperties(w, "    ", p)
+		}
+	}
+
+	return nil
+}
+
+// verifyTable walks every block of the table at path, printing each corruption found.
+func verifyTable(w io.Writer, path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	result, err := table.Verify(f)
+	if err != nil {
+		return false, fmt.Errorf("verifying table %s: %w", path, err)
+	}
+
+	printResult(w, path, result)
+	return result.OK(), nil
+}
+
+// printResult prints the corruptions found in the table at path, and what could be read.
+func printResult(w io.Writer, path string, result *table.VerifyResult) {
+	for _, p := range result.Problems {
+		fmt.Fprintf(w, "%s: %s\n", path, p)
+	}
+
+	if result.OK() {
+		fmt.Fprintf(w, "%s: ok, %d entries in %d blocks\n", path, result.Entries, result.Blocks)
+		return
+	}
+
+	fmt.Fprintf(w, "%s: %d entries readable, %d out of order, %d bytes of data lost", path, result.Entries, result.Skipped, result.LostBytes)
+
+	if p := result.Properties; p != nil && p.NumEntries > uint64(result.Entries) {
+		fmt.Fprintf(w, ", %d of %d recorded entries missing", p.NumEntries-uint64(result.Entries), p.NumEntries)
+	}
+
+	fmt.Fprintln(w)
+}
+
+func repair(args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("repair", flag.ContinueOnError)
+
+	args, err := parse(fs, args, "table", "output")
+	if err != nil {
+		return err
+	}
+
+	in, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(args[1], os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+
+	result, err := table.Repair(in, out, table.BuilderOptions{})
+	if err == nil {
+		err = out.Sync()
+	}
+
+	closeErr := out.Close()
+	if err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(args[1])
+		return fmt.Errorf("repairing table %s: %w", args[0], err)
+	}
+
+	printResult(w, args[0], result)
+	fmt.Fprintf(w, "%s: wrote %d entries\n", args[1], result.Entries)
+	return nil
+}
+
+// verifyPairs checks that the pairs of db are in strictly increasing order and that each can be read
+// back with Get, returning the number of pairs.
+func verifyPairs(db levels.ImmutableDB) (int, error) {
+	var last []byte
+	n := 0
+
+	var failure error
+
+	err := each(db, nil, nil, func(iter iterator.Iterator) {
+		key, value := iter.Key(), iter.Value()
+
+		if failure != nil {
+			return
+		}
+
+		if last != nil && bytes.Compare(key, last) <= 0 {
+			failure = fmt.Errorf("key %q out of order after %q", key, last)
+			return
+		}
+
+		v, err := db.Get(key)
+		if err != nil || !bytes.Equal(v, value) {
+			failure = fmt.Errorf("key %q read back as %q: %v", key, v, err)
+			return
+		}
+
+		last = append(last[:0], key...)
+		n++
+	})
+
+	if err != nil {
+		return n, err
+	}
+
+	return n, failure
+}
+
+func verify(args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("verify", flag.ContinueOnError)
+
+	args, err := parse(fs, args, "path")
+	if err != nil {
+		return err
+	}
+
+	path := args[0]
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	if !info.IsDir() {
+		ok, err := verifyTable(w, path)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			return fmt.Errorf("table %s is corrupted", path)
+		}
+
+		return nil
+	}
+
+	// Tables are checked block by block before the database is opened, which reads them through
+	// the index.
+	paths, err := levels.TableFiles(path)
+	if err != nil {
+		return err
+	}
+
+	corrupted := 0
+	for _, p := range paths {
+		ok, err := verifyTable(w, p)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			corrupted++
+		}
+	}
+
+	if corrupted > 0 {
+		return fmt.Errorf("%d tables are corrupted", corrupted)
+	}
+
+	dropped := 0
+	db, err := levels.OpenDatabase(path, levels.DatabaseOptions{
+		ReadOnly: true,
+		ReportCorruption: func(bytes int, err error) {
+			dropped += bytes
+			fmt.Fprintf(w, "write-ahead log: dropped %d bytes: %s\n", bytes, err)
+		},
+	})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	for _, name := range db.ListColumnFamilies() {
+		cf, err := db.ColumnFamily(name)
+		if err != nil {
+			return err
+		}
+
+		_, err = db.TableProperties(cf)
+		if err != nil {
+			return fmt.Errorf("verifying column family %q: %w", name, err)
+		}
+
+		n, err := verifyPairs(db.Reader(cf))
+		if err != nil {
+			return fmt.Errorf("verifying column family %q: %w", name, err)
+		}
+
+		fmt.Fprintf(w, "column family %s: ok, %d keys\n", name, n)
+	}
+
+	if dropped > 0 {
+		return fmt.Errorf("write-ahead log had %d corrupted bytes", dropped)
+	}
+
+	return nil
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/savarin/levels"
+	"github.com/savarin/levels/memtable"
+)
+
+func runCommand(t *testing.T, args ...string) string {
+	var buf bytes.Buffer
+
+	err := run(args, &buf)
+	if err != nil {
+		t.Fatalf("unexpected error when running %v: %s", args, err)
+	}
+
+	return buf.String()
+}
+
+func TestDatabaseCommands(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "db")
+
+	db, err := levels.OpenDatabase(dir, levels.DatabaseOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error when opening database: %s", err)
+	}
+
+	err = db.Close()
+	if err != nil {
+		t.Fatalf("unexpected error when closing database: %s", err)
+	}
+
+	for _, pair := range [][2]string{{"apple", "red"}, {"banana", "yellow"}, {"blueberry", "blue"}, {"cherry", "dark red"}} {
+		runCommand(t, "put", dir, pair[0], pair[1])
+	}
+
+	runCommand(t, "delete", dir, "banana")
+
+	log, err := os.ReadFile(filepath.Join(dir, "wal.log"))
+	if err != nil {
+		t.Fatalf("unexpected error when reading log: %s", err)
+	}
+
+	if out := runCommand(t, "get", dir, "apple"); out != "red\n" {
+		t.Fatalf("unexpected output from get %q", out)
+	}
+
+	if out := runCommand(t, "get", "-hex", dir, "apple"); out != "726564\n" {
+		t.Fatalf("unexpected output from get -hex %q", out)
+	}
+
+	err = run([]string{"get", dir, "banana"}, &bytes.Buffer{})
+	if !errors.Is(err, levels.KeyError) {
+		t.Fatalf("expected key error when getting deleted key, got %v", err)
+	}
+
+	if out := runCommand(t, "scan", "-prefix", "b", dir); out != "blueberry => blue\n" {
+		t.Fatalf("unexpected output from scan -prefix %q", out)
+	}
+
+	if out := runCommand(t, "scan", "-start", "b", dir); out != "blueberry => blue\ncherry => dark red\n" {
+		t.Fatalf("unexpected output from scan -start %q", out)
+	}
+
+	if out := runCommand(t, "dump", dir); !strings.HasPrefix(out, "[default]\napple => red\n") {
+		t.Fatalf("unexpected output from dump %q", out)
+	}
+
+	if out := runCommand(t, "verify", dir); !strings.Contains(out, "ok, 3 keys") {
+		t.Fatalf("unexpected output from verify %q", out)
+	}
+
+	runCommand(t, "stats", dir)
+
+	// Reading a database replays its log without flushing or truncating it.
+	if after, _ := os.ReadFile(filepath.Join(dir, "wal.log")); !bytes.Equal(after, log) {
+		t.Fatalf("expected read-only commands to leave the log unchanged")
+	}
+
+	empty := t.TempDir()
+
+	err = run([]string{"scan", empty}, &bytes.Buffer{})
+	if err == nil {
+		t.Fatalf("expected error when scanning directory without a database")
+	}
+
+	if entries, _ := os.ReadDir(empty); len(entries) != 0 {
+		t.Fatalf("expected scan to create nothing, got %d entries", len(entries))
+	}
+
+	err = run([]string{"put", dir, "key"}, &bytes.Buffer{})
+	if !errors.Is(err, usageError) {
+		t.Fatalf("expected usage error when missing value, got %v", err)
+	}
+
+	err = run([]string{"get", filepath.Join(dir, "missing"), "key"}, &bytes.Buffer{})
+	if err == nil {
+		t.Fatalf("expected error when opening missing path")
+	}
+}
+
+func TestTableCommands(t *testing.T) {
+	db := memtable.NewSimpleDB()
+
+	for _, pair := range [][2]string{{"a", "alpha"}, {"b", "bravo"}, {"c", "\x00\x01"}} {
+		err := db.Put([]byte(pair[0]), []byte(pair[1]))
+		if err != nil {
+			t.Fatalf("unexpected error when putting key %q: %s", pair[0], err)
+		}
+	}
+
+	var buf bytes.Buffer
+	err := db.Flush(&buf)
+	if err != nil {
+		t.Fatalf("unexpected error when flushing: %s", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "table.sst")
+
+	err = os.WriteFile(path, buf.Bytes(), 0o644)
+	if err != nil {
+		t.Fatalf("unexpected error when writing table: %s", err)
+	}
+
+	if out := runCommand(t, "scan", "-limit", "c", path); out != "a => alpha\nb => bravo\n" {
+		t.Fatalf("unexpected output from scan %q", out)
+	}
+
+	if out := runCommand(t, "get", path, "c"); out != "\"\\x00\\x01\"\n" {
+		t.Fatalf("unexpected output from get %q", out)
+	}
+
+	if out := runCommand(t, "stats", path); !strings.Contains(out, "entries: 3\n") {
+		t.Fatalf("unexpected output from stats %q", out)
+	}
+
+	if out := runCommand(t, "verify", path); !strings.Contains(out, "ok, 3 entries") {
+		t.Fatalf("unexpected output from verify %q", out)
+	}
+
+	err = run([]string{"put", path, "d", "delta"}, &bytes.Buffer{})
+	if err == nil {
+		t.Fatalf("expected error when putting into a table")
+	}
+
+	// Damage the key length of the second entry so that it runs past the end of the data.
+	data := append([]byte(nil), buf.Bytes()...)
+	data[len("\x01a\x05alpha")+8] = 0x7f
+
+	damaged := filepath.Join(t.TempDir(), "damaged.sst")
+
+	err = os.WriteFile(damaged, data, 0o644)
+	if err != nil {
+		t.Fatalf("unexpected error when writing table: %s", err)
+	}
+
+	err = run([]string{"verify", damaged}, &bytes.Buffer{})
+	if err == nil {
+		t.Fatalf("expected error when verifying damaged table")
+	}
+
+	repaired := filepath.Join(t.TempDir(), "repaired.sst")
+
+	if out := runCommand(t, "repair", damaged, repaired); !strings.Contains(out, "wrote 2 entries") {
+		t.Fatalf("unexpected output from repair %q", out)
+	}
+
+	if out := runCommand(t, "verify", repaired); !strings.Contains(out, "ok, 2 entries") {
+		t.Fatalf("unexpected output from verify %q", out)
+	}
+}
@@ -0,0 +1,139 @@
+// Command ycsb runs the core workloads of the Yahoo! Cloud Serving Benchmark against each DB
+// implementation, reporting throughput and latency histograms for every operation.
+//
+// Usage:
+//
+//	ycsb [-workloads a,b,...] [-db simple,skiplist,...] [-records n] [-operations n] [-threads n]
+//	     [-value_size n] [-distribution uniform|zipfian|latest] [-seed n] [-dir path] [-sync]
+//
+// Each workload runs against a fresh DB, loaded with the given number of records beforehand.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/savarin/levels/internal/bench"
+	"github.com/savarin/levels/internal/ycsb"
+)
+
+func report(w io.Writer, phase string, result *ycsb.Result) {
+	fmt.Fprintf(w, "  %s: %d operations in %s, %.0f ops/sec", phase, result.Operations(), result.Elapsed, result.Throughput())
+
+	if result.NotFound > 0 {
+		fmt.Fprintf(w, ", %d reads not found", result.NotFound)
+	}
+
+	fmt.Fprintln(w)
+
+	for _, op := range ycsb.Operations {
+		h, ok := result.Latencies[op]
+		if !ok {
+			continue
+		}
+
+		fmt.Fprintf(w, "    %-18s %8d ops  mean %s  %s\n", op, h.Count(), h.Mean(), h)
+	}
+}
+
+// runWorkload loads a fresh store of the given implementation and runs the workload against it.
+func runWorkload(w io.Writer, impl bench.Implementation, workload ycsb.Workload, opts ycsb.Options, dir string, sync bool) error {
+	store, err := impl.Open(dir, sync)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	result, err := ycsb.Load(store, opts)
+	if err != nil {
+		return fmt.Errorf("loading: %w", err)
+	}
+
+	report(w, "load", result)
+
+	result, err = ycsb.Run(store, workload, opts)
+	if err != nil {
+		return fmt.Errorf("running workload %s: %w", workload.Name, err)
+	}
+
+	report(w, "run", result)
+	return nil
+}
+
+func run(args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("ycsb", flag.ContinueOnError)
+	fs.SetOutput(w)
+
+	workloads := fs.String("workloads", "a,b,c,d,e,f", "comma-separated core workloads to run")
+	dbs := fs.String("db", "simple,skiplist,database", "comma-separated DB implementations to benchmark")
+	distribution := fs.String("distribution", "", "request distribution overriding the workload's: uniform, zipfian or latest")
+	dir := fs.String("dir", "", "directory for database benchmarks, defaulting to the temporary directory")
+	sync := fs.Bool("sync", false, "sync the write-ahead log on every database write")
+
+	var opts ycsb.Options
+	fs.IntVar(&opts.RecordCount, "records", 1000, "number of records loaded before each workload")
+	fs.IntVar(&opts.OperationCount, "operations", 1000, "number of operations made by each workload")
+	fs.IntVar(&opts.Threads, "threads", 1, "number of goroutines making operations")
+	fs.IntVar(&opts.ValueSize, "value_size", 100, "size of each value")
+	fs.Int64Var(&opts.Seed, "seed", 301, "seed for the random choices of each goroutine")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	var selectedWorkloads []ycsb.Workload
+	for _, name := range strings.Split(*workloads, ",") {
+		workload, ok := ycsb.FindWorkload(name)
+		if !ok {
+			return fmt.Errorf("unknown workload %q", name)
+		}
+
+		if *distribution != "" {
+			workload.RequestDistribution = *distribution
+		}
+
+		selectedWorkloads = append(selectedWorkloads, workload)
+	}
+
+	var selected []bench.Implementation
+	for _, name := range strings.Split(*dbs, ",") {
+		impl, ok := bench.FindImplementation(name)
+		if !ok {
+			return fmt.Errorf("unknown DB implementation %q", name)
+		}
+
+		selected = append(selected, impl)
+	}
+
+	fmt.Fprintf(w, "records: %d, operations: %d, threads: %d, values: %d bytes each\n", opts.RecordCount, opts.OperationCount, opts.Threads, opts.ValueSize)
+
+	for _, impl := range selected {
+		for _, workload := range selectedWorkloads {
+			fmt.Fprintf(w, "%s, workload %s (%s):\n", impl.Name, workload.Name, workload.RequestDistribution)
+
+			err := runWorkload(w, impl, workload, opts, *dir, *sync)
+			if err != nil {
+				return fmt.Errorf("benchmarking %s: %w", impl.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func main() {
+	err := run(os.Args[1:], os.Stdout)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ycsb: %s\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	var buf bytes.Buffer
+
+	err := run([]string{"-records", "100", "-operations", "100", "-threads", "2", "-db", "skiplist,database", "-dir", t.TempDir()}, &buf)
+	if err != nil {
+		t.Fatalf("unexpected error when running workloads: %s", err)
+	}
+
+	for _, header := range []string{"skiplist, workload a (zipfian):", "database, workload d (latest):", "database, workload e (zipfian):"} {
+		if !strings.Contains(buf.String(), header) {
+			t.Fatalf("expected results for %q in output:\n%s", header, buf.String())
+		}
+	}
+
+	err = run([]string{"-workloads", "g"}, &buf)
+	if err == nil {
+		t.Fatalf("expected error when running unknown workload")
+	}
+
+	err = run([]string{"-workloads", "a", "-db", "skiplist", "-distribution", "pareto"}, &buf)
+	if err == nil {
+		t.Fatalf("expected error when running unknown distribution")
+	}
+}
@@ -0,0 +1,348 @@
+package levels
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/savarin/levels/vfs"
+)
+
+// crashFamilies are the column families a crash workload creates, writes to and drops, besides the
+// default column family.
+var crashFamilies = []string{"a", "b", "c"}
+
+// crashWrite is a put, or a deletion if value is empty, within a batch of a crash workload.
+type crashWrite struct {
+	family     string
+	key, value string
+}
+
+// crashOp is one step of a crash workload: creating or dropping a column family, a batch of
+// writes, or a flush if there is none of those.
+type crashOp struct {
+	create, drop string
+	batch        []crashWrite
+}
+
+// crashState maps the column families that exist to their keys and values.
+type crashState map[string]map[string]string
+
+func newCrashState() crashState {
+	return crashState{DefaultColumnFamily: make(map[string]string)}
+}
+
+func (s crashState) apply(op crashOp) {
+	switch {
+	case op.create != "":
+		s[op.create] = make(map[string]string)
+	case op.drop != "":
+		delete(s, op.drop)
+	}
+
+	for _, w := range op.batch {
+		if w.value == "" {
+			delete(s[w.family], w.key)
+		} else {
+			s[w.family][w.key] = w.value
+		}
+	}
+}
+
+// crashPoint is the filesystem as a power loss would leave it at a sync boundary, along with the
+// operations that must have survived: every operation before durable, and none from issued on.
+type crashPoint struct {
+	fs              *vfs.MemFS
+	durable, issued int
+}
+
+// newCrashWorkload returns random batches over a small keyspace in the column families that exist,
+// with column families created and dropped and a flush every so often. Every value is unique, so
+// that a value from nowhere can be told apart.
+func newCrashWorkload(rng *rand.Rand, n int) []crashOp {
+	ops := make([]crashOp, n)
+	existing := []string{DefaultColumnFamily}
+
+	for i := range ops {
+		switch op := rng.Intn(20); {
+		case op == 0:
+			continue
+		case op == 1:
+			var missing []string
+			for _, name := range crashFamilies {
+				if !contains(existing, name) {
+					missing = append(missing, name)
+				}
+			}
+
+			if len(missing) > 0 {
+				ops[i].create = missing[rng.Intn(len(missing))]
+				existing = append(existing, ops[i].create)
+				continue
+			}
+		case op == 2 && len(existing) > 1:
+			j := 1 + rng.Intn(len(existing)-1)
+			ops[i].drop = existing[j]
+			existing = append(existing[:j], existing[j+1:]...)
+			continue
+		}
+
+		size := 1 + rng.Intn(3)
+		for j := 0; j < size; j++ {
+			w := crashWrite{
+				family: existing[rng.Intn(len(existing))],
+				key:    fmt.Sprintf("key%02d", rng.Intn(20)),
+			}
+
+			if rng.Intn(4) > 0 {
+				w.value = fmt.Sprintf("value%d.%d", i, j)
+			}
+
+			ops[i].batch = append(ops[i].batch, w)
+		}
+	}
+
+	return ops
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// runCrashWorkload runs the workload against a database on a MemFS, recording a crash point
+// before every sync of a file or directory and another once the workload is done.
+func runCrashWorkload(t *testing.T, ops []crashOp, sync bool) []crashPoint {
+	mem := vfs.NewMem()
+	mem.MkdirAll("db", 0755)
+	mem.SyncDir("/")
+
+	var points []crashPoint
+	var durable, issued int
+
+	fs := vfs.NewFaultFS(mem, func(op vfs.Op, name string) error {
+		if op == vfs.OpSync || op == vfs.OpSyncDir {
+			points = append(points, crashPoint{fs: mem.CrashClone(), durable: durable, issued: issued})
+		}
+
+		return nil
+	})
+
+	opts := DatabaseOptions{FS: fs, SyncWrites: sync, MemtableSize: 256}
+
+	db, err := OpenDatabase("db", opts)
+	if err != nil {
+		t.Fatalf("unexpected error when opening database: %s", err)
+	}
+
+	for i, op := range ops {
+		issued = i + 1
+
+		switch {
+		case op.create != "":
+			_, err = db.CreateColumnFamily(op.create, ColumnFamilyOptions{})
+			if err != nil {
+				t.Fatalf("unexpected error when creating column family %q: %s", op.create, err)
+			}
+
+			// Creating a column family syncs the write-ahead log before it, along with its directory.
+			durable = issued
+			continue
+		case op.drop != "":
+			cf, err := db.ColumnFamily(op.drop)
+			if err != nil {
+				t.Fatalf("unexpected error when getting column family: %s", err)
+			}
+
+			// Dropping a column family flushes every other one first.
+			err = db.DropColumnFamily(cf)
+			if err != nil {
+				t.Fatalf("unexpected error when dropping column family %q: %s", op.drop, err)
+			}
+
+			durable = issued
+			continue
+		case len(op.batch) == 0:
+			err = db.Flush()
+			if err != nil {
+				t.Fatalf("unexpected error when flushing: %s", err)
+			}
+
+			durable = issued
+			continue
+		}
+
+		b := NewWriteBatch()
+		for _, w := range op.batch {
+			cf, err := db.ColumnFamily(w.family)
+			if err != nil {
+				t.Fatalf("unexpected error when getting column family: %s", err)
+			}
+
+			if w.value == "" {
+				b.Delete(cf, []byte(w.key))
+			} else {
+				b.Put(cf, []byte(w.key), []byte(w.value))
+			}
+		}
+
+		err = db.Write(b)
+		if err != nil {
+			t.Fatalf("unexpected error when writing batch %d: %s", i, err)
+		}
+
+		// Writes are acknowledged as durable only once the write-ahead log is synced for them.
+		if sync {
+			durable = issued
+		}
+	}
+
+	points = append(points, crashPoint{fs: mem.CrashClone(), durable: durable, issued: issued})
+
+	err = db.Close()
+	if err != nil {
+		t.Fatalf("unexpected error when closing database: %s", err)
+	}
+
+	return points
+}
+
+// recoveredState reads every column family, and every pair in it, from the database.
+func recoveredState(t *testing.T, db *Database) crashState {
+	state := make(crashState)
+
+	for _, name := range db.ListColumnFamilies() {
+		cf, err := db.ColumnFamily(name)
+		if err != nil {
+			t.Fatalf("unexpected error when getting column family: %s", err)
+		}
+
+		state[name] = make(map[string]string)
+
+		iter, err := db.RangeScan(cf, nil, nil)
+		if err != nil {
+			t.Fatalf("unexpected error when scanning column family %q: %s", name, err)
+		}
+
+		for key := iter.Key(); key != nil; key = iter.Key() {
+			state[name][string(key)] = string(iter.Value())
+
+			if !iter.Next() {
+				break
+			}
+		}
+	}
+
+	return state
+}
+
+// checkCrashPoint reopens the database as the crash left it, and checks that it holds the state
+// after some prefix of the workload that takes in every durable operation. A database in any
+// other state has lost an acknowledged write, applied part of a batch or made up data.
+func checkCrashPoint(t *testing.T, i int, point crashPoint, ops []crashOp) {
+	db, err := OpenDatabase("db", DatabaseOptions{FS: point.fs, MemtableSize: 256})
+	if err != nil {
+		t.Fatalf("crash point %d: unexpected error when recovering: %s", i, err)
+	}
+
+	got := recoveredState(t, db)
+
+	model := newCrashState()
+	matched := -1
+
+	for k := 0; k <= point.issued; k++ {
+		if k > 0 {
+			model.apply(ops[k-1])
+		}
+
+		if k >= point.durable && reflect.DeepEqual(got, model) {
+			matched = k
+			break
+		}
+	}
+
+	if matched < 0 {
+		expected := newCrashState()
+		for _, op := range ops[:point.durable] {
+			expected.apply(op)
+		}
+
+		t.Fatalf("crash point %d: recovered state matches no state from operation %d to %d\ngot:     %s\ndurable: %s", i, point.durable, point.issued, describe(got), describe(expected))
+	}
+
+	// The recovered database takes new writes, which survive it being closed and opened again.
+	cf, _ := db.ColumnFamily(DefaultColumnFamily)
+
+	err = db.Put(cf, []byte("recovered"), []byte("yes"))
+	if err != nil {
+		t.Fatalf("crash point %d: unexpected error when writing after recovery: %s", i, err)
+	}
+
+	db.Close()
+
+	db, err = OpenDatabase("db", DatabaseOptions{FS: point.fs})
+	if err != nil {
+		t.Fatalf("crash point %d: unexpected error when reopening after recovery: %s", i, err)
+	}
+	defer db.Close()
+
+	cf, _ = db.ColumnFamily(DefaultColumnFamily)
+
+	if v, err := db.Get(cf, []byte("recovered")); err != nil || string(v) != "yes" {
+		t.Fatalf("crash point %d: expected write after recovery to survive reopening, got %q: %v", i, v, err)
+	}
+}
+
+// describe formats a state with its column families and keys in order.
+func describe(s crashState) string {
+	families := make([]string, 0, len(s))
+	for family := range s {
+		families = append(families, family)
+	}
+
+	sort.Strings(families)
+
+	var parts []string
+	for _, family := range families {
+		keys := make([]string, 0, len(s[family]))
+		for key := range s[family] {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+
+		part := family + ":"
+		for _, key := range keys {
+			part += fmt.Sprintf(" %s=%s", key, s[family][key])
+		}
+
+		parts = append(parts, part)
+	}
+
+	return strings.Join(parts, "; ")
+}
+
+// TestCrashConsistency runs random workloads on an in-memory filesystem, and crashes them at every
+// sync boundary by dropping whatever was not yet synced, checking that each recovers.
+func TestCrashConsistency(t *testing.T) {
+	for _, sync := range []bool{true, false} {
+		for seed := int64(1); seed <= 4; seed++ {
+			t.Run(fmt.Sprintf("sync=%t/seed=%d", sync, seed), func(t *testing.T) {
+				ops := newCrashWorkload(rand.New(rand.NewSource(seed)), 150)
+				points := runCrashWorkload(t, ops, sync)
+
+				for i, point := range points {
+					checkCrashPoint(t, i, point, ops)
+				}
+			})
+		}
+	}
+}
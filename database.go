@@ -0,0 +1,1227 @@
+package levels
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/savarin/levels/internal/base"
+	"github.com/savarin/levels/iterator"
+	"github.com/savarin/levels/memtable"
+	"github.com/savarin/levels/table"
+	"github.com/savarin/levels/vfs"
+)
+
+const (
+	DefaultColumnFamily = "default"
+
+	defaultMemtableSize = 4 << 20
+	logFileName         = "wal.log"
+	lockFileName        = "LOCK"
+	familiesDirName     = "families"
+	tableFileSuffix     = ".sst"
+)
+
+var (
+	ColumnFamilyError       = errors.New("Column family not found")
+	ColumnFamilyExistsError = errors.New("Column family already exists")
+	ReadOnlyError           = errors.New("Database opened read-only")
+)
+
+// ColumnFamilyOptions configures a single column family.
+type ColumnFamilyOptions struct {
+	// NewMemtable creates the in-memory collection that buffers writes to the column family before
+	// they are flushed. It defaults to memtable.NewSkipListDB.
+	NewMemtable func() DB
+
+	// PropertiesCollectors create the collectors run over every SSTable flushed for the column
+	// family. They see the live values written with Put, and not deletions.
+	PropertiesCollectors []func() table.PropertiesCollector
+}
+
+// DatabaseOptions configures a Database.
+type DatabaseOptions struct {
+	// MemtableSize is the approximate number of bytes buffered across all column families before
+	// they are flushed to SSTables. It defaults to 4 MiB.
+	MemtableSize int
+
+	// SyncWrites syncs the write-ahead log before Write returns, so that acknowledged writes survive
+	// a machine crash and not just a process crash.
+	SyncWrites bool
+
+	// MaxOpenTables is the number of SSTables kept open at once. It defaults to 1000.
+	MaxOpenTables int
+
+	// BlockCache, if set, caches blocks read from the SSTables of every column family.
+	BlockCache *table.BlockCache
+
+	// ReportCorruption, if set, is called for every corrupted part of the write-ahead log skipped
+	// during recovery, with the number of bytes dropped.
+	ReportCorruption func(bytes int, err error)
+
+	// ColumnFamilies holds the options for column families that already exist on disk. Column
+	// families without an entry use the default options.
+	ColumnFamilies map[string]ColumnFamilyOptions
+
+	// FS is the filesystem the database is stored in. It defaults to vfs.Default, the operating
+	// system's filesystem.
+	FS vfs.FS
+
+	// ReadOnly opens an existing database without changing its directory, for inspecting it. The
+	// write-ahead log is replayed into the memtables and left as it is, the directory is not locked,
+	// and writes, flushes and column family changes fail with ReadOnlyError.
+	ReadOnly bool
+}
+
+// ColumnFamily is a handle to a logically separate keyspace within a Database. Each column family
+// has its own memtable and SSTables, while all of them share the write-ahead log.
+type ColumnFamily struct {
+	name    string
+	opts    ColumnFamilyOptions
+	dir     string
+	dropped bool
+
+	memtable DB
+	tables   []*familyTable
+}
+
+// Name returns the name of the column family.
+func (cf *ColumnFamily) Name() string {
+	return cf.name
+}
+
+type familyTable struct {
+	number int
+	path   string
+}
+
+// Database is a persistent key/value store made up of named column families. Writes are recorded
+// in a write-ahead log before being applied to the memtables, which are flushed to SSTables once
+// they grow past DatabaseOptions.MemtableSize.
+type Database struct {
+	mu   sync.RWMutex
+	dir  string
+	opts DatabaseOptions
+	fs   vfs.FS
+	lock io.Closer
+
+	log     *logWriter
+	logFile vfs.File
+	tables  *table.Cache
+
+	families       map[string]*ColumnFamily
+	nextFileNumber int
+	memtableBytes  int
+
+	// flushErr is the error from a flush started by a write that filled the memtables, which the
+	// next write or Close reports.
+	flushErr error
+	closed   bool
+}
+
+func validateFamilyName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsRune(name, os.PathSeparator) {
+		return fmt.Errorf("invalid column family name %q: %w", name, ValueError)
+	}
+
+	return nil
+}
+
+// OpenDatabase opens the database in dir, creating it if it does not exist, and replays the
+// write-ahead log into the memtables. The directory is locked until the database is closed, so
+// that only one Database uses it at a time. With DatabaseOptions.ReadOnly, dir must already hold a
+// database, and is neither created nor locked.
+func OpenDatabase(dir string, opts DatabaseOptions) (*Database, error) {
+	if opts.MemtableSize <= 0 {
+		opts.MemtableSize = defaultMemtableSize
+	}
+
+	fs := opts.FS
+	if fs == nil {
+		fs = vfs.Default
+	}
+
+	lock, err := lockDatabase(fs, dir, opts.ReadOnly)
+	if err != nil {
+		return nil, err
+	}
+
+	open := func(name string) (table.File, error) { return fs.Open(name) }
+
+	db := &Database{
+		dir:            dir,
+		opts:           opts,
+		fs:             fs,
+		lock:           lock,
+		families:       make(map[string]*ColumnFamily),
+		nextFileNumber: 1,
+		tables:         table.NewCache(opts.MaxOpenTables, open, table.Options{BlockCache: opts.BlockCache}),
+	}
+
+	err = db.loadFamilies()
+	if err != nil {
+		db.release()
+		return nil, err
+	}
+
+	if _, ok := db.families[DefaultColumnFamily]; !ok {
+		_, err = db.createFamily(DefaultColumnFamily, opts.ColumnFamilies[DefaultColumnFamily])
+		if err != nil {
+			db.release()
+			return nil, err
+		}
+	}
+
+	err = db.recover()
+	if err != nil {
+		db.release()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// lockDatabase creates the database directory in dir if needed and locks it. A database opened
+// read-only is not locked, and must already exist, since nothing is created for it.
+func lockDatabase(fs vfs.FS, dir string, readOnly bool) (io.Closer, error) {
+	if readOnly {
+		info, err := fs.Stat(filepath.Join(dir, familiesDirName))
+		if err != nil {
+			return nil, fmt.Errorf("%s is not a database: %w", dir, err)
+		}
+
+		if !info.IsDir() {
+			return nil, fmt.Errorf("%s is not a database: %s is not a directory", dir, familiesDirName)
+		}
+
+		return nil, nil
+	}
+
+	err := fs.MkdirAll(filepath.Join(dir, familiesDirName), 0755)
+	if err != nil {
+		return nil, fmt.Errorf("creating database directory: %w", err)
+	}
+
+	lock, err := fs.Lock(filepath.Join(dir, lockFileName))
+	if err != nil {
+		return nil, fmt.Errorf("locking database directory: %w", err)
+	}
+
+	return lock, nil
+}
+
+func newFamily(name, dir string, opts ColumnFamilyOptions) *ColumnFamily {
+	if opts.NewMemtable == nil {
+		opts.NewMemtable = func() DB { return memtable.NewSkipListDB() }
+	}
+
+	return &ColumnFamily{
+		name:     name,
+		opts:     opts,
+		dir:      dir,
+		memtable: opts.NewMemtable(),
+	}
+}
+
+// TableFiles returns the paths of the SSTables of every column family of the database in dir,
+// without opening it, so that they can be checked before the database reads them.
+func TableFiles(dir string) ([]string, error) {
+	names, err := listFamilies(vfs.Default, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, name := range names {
+		tables, err := listTables(vfs.Default, filepath.Join(dir, familiesDirName, name))
+		if err != nil {
+			return nil, fmt.Errorf("listing tables of column family %q: %w", name, err)
+		}
+
+		for _, t := range tables {
+			paths = append(paths, t.path)
+		}
+	}
+
+	return paths, nil
+}
+
+// listFamilies returns the names of the column families in the families directory of the database
+// in dir.
+func listFamilies(fs vfs.FS, dir string) ([]string, error) {
+	familiesDir := filepath.Join(dir, familiesDirName)
+
+	entries, err := fs.List(familiesDir)
+	if err != nil {
+		return nil, fmt.Errorf("listing column families: %w", err)
+	}
+
+	var names []string
+	for _, name := range entries {
+		info, err := fs.Stat(filepath.Join(familiesDir, name))
+		if err != nil {
+			return nil, fmt.Errorf("listing column families: %w", err)
+		}
+
+		if info.IsDir() {
+			names = append(names, name)
+		}
+	}
+
+	return names, nil
+}
+
+// listTables returns the tables in the directory of a column family, ordered by file number.
+// Files not named after a number are ignored.
+func listTables(fs vfs.FS, dir string) ([]*familyTable, error) {
+	names, err := fs.List(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var tables []*familyTable
+	for _, fileName := range names {
+		if !strings.HasSuffix(fileName, tableFileSuffix) {
+			continue
+		}
+
+		number, err := strconv.Atoi(strings.TrimSuffix(fileName, tableFileSuffix))
+		if err != nil {
+			continue
+		}
+
+		tables = append(tables, &familyTable{number: number, path: filepath.Join(dir, fileName)})
+	}
+
+	sort.Slice(tables, func(i, j int) bool { return tables[i].number < tables[j].number })
+	return tables, nil
+}
+
+// loadFamilies loads every column family found in the families directory.
+func (db *Database) loadFamilies() error {
+	names, err := listFamilies(db.fs, db.dir)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		err = db.loadFamily(name, db.opts.ColumnFamilies[name])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (db *Database) loadFamily(name string, opts ColumnFamilyOptions) error {
+	cf := newFamily(name, filepath.Join(db.dir, familiesDirName, name), opts)
+
+	tables, err := listTables(db.fs, cf.dir)
+	if err != nil {
+		return fmt.Errorf("listing tables of column family %q: %w", name, err)
+	}
+
+	for _, t := range tables {
+		if t.number >= db.nextFileNumber {
+			db.nextFileNumber = t.number + 1
+		}
+	}
+
+	cf.tables = tables
+	db.families[name] = cf
+	return nil
+}
+
+// recover replays the write-ahead log into the memtables and truncates any torn record at its end
+// before reopening it for appending.
+func (db *Database) recover() error {
+	path := filepath.Join(db.dir, logFileName)
+
+	if db.opts.ReadOnly {
+		return db.recoverReadOnly(path)
+	}
+
+	f, err := db.fs.OpenReadWrite(path)
+	if err != nil {
+		return fmt.Errorf("opening write-ahead log: %w", err)
+	}
+
+	// The log and families directory may have just been created, and writes acknowledged once the
+	// log is synced would be lost along with them if their directory entries were not durable.
+	err = db.fs.SyncDir(db.dir)
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("syncing database directory: %w", err)
+	}
+
+	validOffset, dropped, err := db.replay(f)
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	db.logFile = f
+
+	// Records written after a corrupted region would be dropped along with it, so the recovered
+	// writes are flushed and the log starts over.
+	if dropped > 0 {
+		db.log = newLogWriter(f, 0)
+
+		err = db.flushLocked()
+		if err != nil {
+			f.Close()
+			return err
+		}
+
+		return nil
+	}
+
+	err = f.Truncate(validOffset)
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("truncating write-ahead log: %w", err)
+	}
+
+	_, err = f.Seek(validOffset, io.SeekStart)
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("seeking to end of write-ahead log: %w", err)
+	}
+
+	db.log = newLogWriter(f, validOffset)
+	return nil
+}
+
+// recoverReadOnly replays the write-ahead log at path into the memtables, leaving the log as it is,
+// torn or corrupted records included.
+func (db *Database) recoverReadOnly(path string) error {
+	f, err := db.fs.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("opening write-ahead log: %w", err)
+	}
+	defer f.Close()
+
+	_, _, err = db.replay(f)
+	return err
+}
+
+// replay applies the records of the write-ahead log read from r to the memtables. It returns the
+// offset just past the last intact record and the number of corrupted bytes skipped.
+func (db *Database) replay(r io.Reader) (validOffset int64, dropped int, err error) {
+	records, validOffset, dropped, err := readLog(r, db.opts.ReportCorruption)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for _, record := range records {
+		b, err := decodeBatch(record)
+		if err != nil {
+			return 0, 0, fmt.Errorf("replaying write-ahead log: %w", err)
+		}
+
+		err = db.apply(b)
+		if err != nil {
+			return 0, 0, fmt.Errorf("replaying write-ahead log: %w", err)
+		}
+	}
+
+	return validOffset, dropped, nil
+}
+
+// apply inserts the operations of b into the memtables. Operations on column families that no
+// longer exist are skipped.
+func (db *Database) apply(b *WriteBatch) error {
+	for _, op := range b.ops {
+		cf, ok := db.families[op.family]
+		if !ok {
+			continue
+		}
+
+		err := cf.memtable.Put(op.key, encodeValue(op.kind, op.value))
+		if err != nil {
+			return fmt.Errorf("applying write to column family %q: %w", op.family, err)
+		}
+
+		db.memtableBytes += len(op.key) + len(op.value) + 1
+	}
+
+	return nil
+}
+
+// Write atomically applies every operation in the batch, logging the batch as a single record. A
+// flush that failed after an earlier write is retried first, and fails the write if it fails again.
+func (db *Database) Write(b *WriteBatch) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.opts.ReadOnly {
+		return ReadOnlyError
+	}
+
+	if db.log == nil {
+		return fmt.Errorf("writing to closed database")
+	}
+
+	if db.flushErr != nil {
+		err := db.flushLocked()
+		if err != nil {
+			return fmt.Errorf("retrying failed flush: %w", err)
+		}
+	}
+
+	for _, op := range b.ops {
+		// Checking the handle rather than the name keeps a handle to a dropped column family from
+		// writing to one created since under the same name.
+		err := db.lookupFamily(op.cf)
+		if err != nil {
+			return fmt.Errorf("writing to column family %q: %w", op.family, err)
+		}
+
+		err = base.CheckSize(op.key, op.value)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := db.log.Append(b.encode())
+	if err != nil {
+		return err
+	}
+
+	if db.opts.SyncWrites {
+		err = db.logFile.Sync()
+		if err != nil {
+			return fmt.Errorf("syncing write-ahead log: %w", err)
+		}
+	}
+
+	err = db.apply(b)
+	if err != nil {
+		return err
+	}
+
+	// The batch is logged and applied whether or not the flush succeeds, so a failed flush is left
+	// for the next write or Close to report rather than failing this write.
+	if db.memtableBytes >= db.opts.MemtableSize {
+		db.flushErr = db.flushLocked()
+	}
+
+	return nil
+}
+
+func (db *Database) Put(cf *ColumnFamily, key, value []byte) error {
+	b := NewWriteBatch()
+	b.Put(cf, key, value)
+	return db.Write(b)
+}
+
+func (db *Database) Delete(cf *ColumnFamily, key []byte) error {
+	b := NewWriteBatch()
+	b.Delete(cf, key)
+	return db.Write(b)
+}
+
+func (db *Database) lookupFamily(cf *ColumnFamily) error {
+	if cf == nil || cf.dropped || db.families[cf.name] != cf {
+		return ColumnFamilyError
+	}
+
+	return nil
+}
+
+func (db *Database) Get(cf *ColumnFamily, key []byte) (value []byte, err error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	err = db.lookupFamily(cf)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, err := cf.memtable.Get(key)
+	for i := len(cf.tables) - 1; errors.Is(err, KeyError) && i >= 0; i-- {
+		encoded, err = db.tableGet(cf.tables[i], key)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	kind, v, err := decodeValue(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("getting key %q: %w", key, err)
+	}
+
+	if kind == kindDeletion {
+		return nil, KeyError
+	}
+
+	return v, nil
+}
+
+func (db *Database) tableGet(t *familyTable, key []byte) (value []byte, err error) {
+	h, err := db.tables.Acquire(t.path)
+	if err != nil {
+		return nil, err
+	}
+	defer h.Release()
+
+	return h.Table().Get(key)
+}
+
+func (db *Database) Has(cf *ColumnFamily, key []byte) (ret bool, err error) {
+	_, err = db.Get(cf, key)
+
+	if errors.Is(err, KeyError) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// RangeScan returns an Iterator over the live key/value pairs of the column family in the given
+// range, merging the memtable with every SSTable of the column family. The memtable's pairs are
+// copied when the scan starts, while the SSTables are read as the Iterator advances.
+func (db *Database) RangeScan(cf *ColumnFamily, start, limit []byte) (Iterator, error) {
+	if len(limit) > 0 && string(start) > string(limit) {
+		return nil, ValueError
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	err := db.lookupFamily(cf)
+	if err != nil {
+		return nil, err
+	}
+
+	memtableIter, err := scanMemtable(cf.memtable, start, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	cursors := []*cursor{newCursor(memtableIter, start, limit)}
+
+	for i := len(cf.tables) - 1; i >= 0; i-- {
+		tableIter, err := db.newTableIterator(cf.tables[i], start, limit)
+		if err != nil {
+			return nil, err
+		}
+
+		cursors = append(cursors, newCursor(tableIter, start, limit))
+	}
+
+	iter := &mergingIterator{cursors: cursors}
+
+	if !iter.settle() && iter.Error() != nil {
+		return nil, iter.Error()
+	}
+
+	return iter, nil
+}
+
+// scanMemtable returns an Iterator over a copy of the pairs of memtable in the given range, so
+// that the scan does not race with later writes.
+func scanMemtable(memtable DB, start, limit []byte) (Iterator, error) {
+	iter, err := memtable.RangeScan(start, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([][]byte, 0)
+	values := make([][]byte, 0)
+
+	for key := iter.Key(); key != nil; key = iter.Key() {
+		if string(key) >= string(start) && (len(limit) == 0 || string(key) < string(limit)) {
+			keys = append(keys, key)
+			values = append(values, iter.Value())
+		}
+
+		if !iter.Next() {
+			break
+		}
+	}
+
+	err = iter.Error()
+	if err != nil {
+		return nil, err
+	}
+
+	return iterator.New(keys, values), nil
+}
+
+// tableChunkSize is the number of pairs a tableIterator reads from its SSTable at a time.
+const tableChunkSize = 256
+
+// tableIterator iterates over the pairs of an SSTable in a range. It reads the pairs in chunks,
+// acquiring the table from the cache only while a chunk is read, so that an open scan neither pins
+// the table nor holds all of its pairs in memory.
+type tableIterator struct {
+	db    *Database
+	path  string
+	limit []byte
+
+	chunk *iterator.SimpleIterator
+	more  bool
+	err   error
+}
+
+func (db *Database) newTableIterator(t *familyTable, start, limit []byte) (*tableIterator, error) {
+	iter := &tableIterator{db: db, path: t.path, limit: limit}
+
+	chunk, err := iter.read(start)
+	if err != nil {
+		return nil, err
+	}
+
+	iter.chunk = chunk
+	return iter, nil
+}
+
+// read returns the next chunk of pairs from start, recording whether any pairs follow it.
+func (iter *tableIterator) read(start []byte) (*iterator.SimpleIterator, error) {
+	h, err := iter.db.tables.Acquire(iter.path)
+	if err != nil {
+		return nil, err
+	}
+	defer h.Release()
+
+	scan, err := h.Table().RangeScan(start, iter.limit)
+	if err != nil {
+		return nil, err
+	}
+
+	iter.more = false
+	keys := make([][]byte, 0)
+	values := make([][]byte, 0)
+
+	for key := scan.Key(); key != nil; key = scan.Key() {
+		if len(keys) == tableChunkSize {
+			iter.more = true
+			break
+		}
+
+		keys = append(keys, key)
+		values = append(values, scan.Value())
+
+		if !scan.Next() {
+			break
+		}
+	}
+
+	err = scan.Error()
+	if err != nil {
+		return nil, err
+	}
+
+	return iterator.New(keys, values), nil
+}
+
+func (iter *tableIterator) Next() bool {
+	if iter.chunk.Next() {
+		return true
+	}
+
+	if !iter.more {
+		return false
+	}
+
+	// Resume just after the last key read, which is the smallest key greater than it.
+	start := append(append([]byte(nil), iter.chunk.Key()...), 0)
+
+	chunk, err := iter.read(start)
+	if err != nil {
+		iter.err = err
+		iter.more = false
+		return false
+	}
+
+	if chunk.Key() == nil {
+		return false
+	}
+
+	iter.chunk = chunk
+	return true
+}
+
+func (iter *tableIterator) Error() error {
+	return iter.err
+}
+
+func (iter *tableIterator) Key() []byte {
+	return iter.chunk.Key()
+}
+
+func (iter *tableIterator) Value() []byte {
+	return iter.chunk.Value()
+}
+
+// cursor tracks whether an Iterator is positioned on a pair within its range. Iterators leave
+// themselves on their last pair once exhausted, and some step one pair past their limit.
+type cursor struct {
+	iter         Iterator
+	start, limit []byte
+	valid        bool
+}
+
+func newCursor(iter Iterator, start, limit []byte) *cursor {
+	c := &cursor{iter: iter, start: start, limit: limit}
+	c.valid = c.inRange()
+
+	for c.valid && bytes.Compare(iter.Key(), start) < 0 {
+		c.advance()
+	}
+
+	return c
+}
+
+func (c *cursor) inRange() bool {
+	key := c.iter.Key()
+	return key != nil && (len(c.limit) == 0 || bytes.Compare(key, c.limit) < 0)
+}
+
+func (c *cursor) advance() {
+	c.valid = c.iter.Next() && c.inRange()
+}
+
+// mergingIterator merges the live pairs of a memtable and SSTables, whose cursors are ordered from
+// newest to oldest. Where several have the same key the newest wins, and deletions hide the pairs
+// of older sources.
+type mergingIterator struct {
+	cursors    []*cursor
+	key, value []byte
+	err        error
+}
+
+// settle moves the iterator to the next live pair. It returns false, leaving the iterator on its
+// current pair, if there is none.
+func (iter *mergingIterator) settle() bool {
+	for {
+		var newest *cursor
+
+		for _, c := range iter.cursors {
+			if c.valid && (newest == nil || bytes.Compare(c.iter.Key(), newest.iter.Key()) < 0) {
+				newest = c
+			}
+		}
+
+		if newest == nil {
+			return false
+		}
+
+		key, encoded := newest.iter.Key(), newest.iter.Value()
+
+		for _, c := range iter.cursors {
+			if c.valid && bytes.Equal(c.iter.Key(), key) {
+				c.advance()
+			}
+		}
+
+		kind, value, err := decodeValue(encoded)
+		if err != nil {
+			iter.err = fmt.Errorf("scanning key %q: %w", key, err)
+			return false
+		}
+
+		if kind == kindDeletion {
+			continue
+		}
+
+		iter.key, iter.value = key, value
+		return true
+	}
+}
+
+func (iter *mergingIterator) Next() bool {
+	if iter.key == nil || iter.err != nil {
+		return false
+	}
+
+	return iter.settle()
+}
+
+func (iter *mergingIterator) Error() error {
+	if iter.err != nil {
+		return iter.err
+	}
+
+	for _, c := range iter.cursors {
+		err := c.iter.Error()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (iter *mergingIterator) Key() []byte {
+	return iter.key
+}
+
+func (iter *mergingIterator) Value() []byte {
+	return iter.value
+}
+
+type familyReader struct {
+	db *Database
+	cf *ColumnFamily
+}
+
+func (r familyReader) Get(key []byte) (value []byte, err error) {
+	return r.db.Get(r.cf, key)
+}
+
+func (r familyReader) Has(key []byte) (ret bool, err error) {
+	return r.db.Has(r.cf, key)
+}
+
+func (r familyReader) RangeScan(start, limit []byte) (Iterator, error) {
+	return r.db.RangeScan(r.cf, start, limit)
+}
+
+// Reader returns an ImmutableDB that reads from the given column family, for use where a DB is
+// expected such as the base of an IndexedBatch.
+func (db *Database) Reader(cf *ColumnFamily) ImmutableDB {
+	return familyReader{db: db, cf: cf}
+}
+
+// ColumnFamily returns the handle of the column family with the given name.
+func (db *Database) ColumnFamily(name string) (*ColumnFamily, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	cf, ok := db.families[name]
+	if !ok {
+		return nil, fmt.Errorf("column family %q: %w", name, ColumnFamilyError)
+	}
+
+	return cf, nil
+}
+
+// ListColumnFamilies returns the names of all column families, sorted.
+func (db *Database) ListColumnFamilies() []string {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	names := make([]string, 0, len(db.families))
+	for name := range db.families {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// CreateColumnFamily creates a new, empty column family.
+func (db *Database) CreateColumnFamily(name string, opts ColumnFamilyOptions) (*ColumnFamily, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	// The new column family's directory is durable once createFamily returns, so the writes logged
+	// before it must be too: a crash would otherwise keep the column family but lose earlier writes.
+	if db.logFile != nil {
+		err := db.logFile.Sync()
+		if err != nil {
+			return nil, fmt.Errorf("syncing write-ahead log: %w", err)
+		}
+	}
+
+	return db.createFamily(name, opts)
+}
+
+func (db *Database) createFamily(name string, opts ColumnFamilyOptions) (*ColumnFamily, error) {
+	if db.opts.ReadOnly {
+		return nil, ReadOnlyError
+	}
+
+	err := validateFamilyName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := db.families[name]; ok {
+		return nil, fmt.Errorf("column family %q: %w", name, ColumnFamilyExistsError)
+	}
+
+	cf := newFamily(name, filepath.Join(db.dir, familiesDirName, name), opts)
+
+	err = db.fs.MkdirAll(cf.dir, 0755)
+	if err != nil {
+		return nil, fmt.Errorf("creating column family %q: %w", name, err)
+	}
+
+	err = db.fs.SyncDir(filepath.Join(db.dir, familiesDirName))
+	if err != nil {
+		return nil, fmt.Errorf("syncing column families directory: %w", err)
+	}
+
+	db.families[name] = cf
+	return cf, nil
+}
+
+// DropColumnFamily deletes the column family and all of its data. The default column family cannot
+// be dropped.
+func (db *Database) DropColumnFamily(cf *ColumnFamily) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	err := db.lookupFamily(cf)
+	if err != nil {
+		return err
+	}
+
+	if cf.name == DefaultColumnFamily {
+		return fmt.Errorf("dropping default column family: %w", ValueError)
+	}
+
+	// Flushing empties the write-ahead log, so a column family recreated under the same name
+	// cannot pick up the dropped column family's writes on recovery.
+	err = db.flushLocked()
+	if err != nil {
+		return err
+	}
+
+	for _, t := range cf.tables {
+		db.tables.Evict(t.path)
+	}
+
+	err = db.fs.RemoveAll(cf.dir)
+	if err != nil {
+		return fmt.Errorf("removing column family %q: %w", cf.name, err)
+	}
+
+	// Without syncing its parent, the removed directory would reappear after a crash, bringing the
+	// dropped column family back with it.
+	err = db.fs.SyncDir(filepath.Join(db.dir, familiesDirName))
+	if err != nil {
+		return fmt.Errorf("syncing column families directory: %w", err)
+	}
+
+	cf.dropped = true
+	cf.tables = nil
+	delete(db.families, cf.name)
+	return nil
+}
+
+// Flush writes every non-empty memtable to a new SSTable and empties the write-ahead log.
+func (db *Database) Flush() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return db.flushLocked()
+}
+
+func (db *Database) flushLocked() error {
+	if db.opts.ReadOnly {
+		return ReadOnlyError
+	}
+
+	if db.log == nil {
+		return fmt.Errorf("flushing closed database")
+	}
+
+	// Column families are flushed one at a time, so the log must hold every write until they all
+	// are: a crash part-way through would otherwise leave only some of a batch's writes in tables.
+	err := db.logFile.Sync()
+	if err != nil {
+		return fmt.Errorf("syncing write-ahead log: %w", err)
+	}
+
+	for _, cf := range db.families {
+		empty, err := memtableIsEmpty(cf.memtable)
+		if err != nil {
+			return err
+		}
+
+		if empty {
+			continue
+		}
+
+		t, err := db.writeTable(cf)
+		if err != nil {
+			return err
+		}
+
+		cf.tables = append(cf.tables, t)
+		cf.memtable = cf.opts.NewMemtable()
+	}
+
+	// Every write in the log is now in an SSTable, so the log can start over.
+	err = db.logFile.Truncate(0)
+	if err != nil {
+		return fmt.Errorf("truncating write-ahead log: %w", err)
+	}
+
+	_, err = db.logFile.Seek(0, io.SeekStart)
+	if err != nil {
+		return fmt.Errorf("seeking to start of write-ahead log: %w", err)
+	}
+
+	err = db.logFile.Sync()
+	if err != nil {
+		return fmt.Errorf("syncing write-ahead log: %w", err)
+	}
+
+	db.log = newLogWriter(db.logFile, 0)
+	db.memtableBytes = 0
+	db.flushErr = nil
+	return nil
+}
+
+func memtableIsEmpty(memtable DB) (bool, error) {
+	iter, err := memtable.RangeScan([]byte{}, []byte{})
+	if err != nil {
+		return false, err
+	}
+
+	return iter.Key() == nil, nil
+}
+
+func (db *Database) writeTable(cf *ColumnFamily) (*familyTable, error) {
+	number := db.nextFileNumber
+	db.nextFileNumber++
+
+	path := filepath.Join(cf.dir, fmt.Sprintf("%06d%s", number, tableFileSuffix))
+	tmpPath := path + ".tmp"
+
+	f, err := db.fs.Create(tmpPath)
+	if err != nil {
+		return nil, fmt.Errorf("creating table for column family %q: %w", cf.name, err)
+	}
+
+	opts := table.BuilderOptions{IsDeletion: isDeletion}
+	for _, newCollector := range cf.opts.PropertiesCollectors {
+		newCollector := newCollector
+		opts.PropertiesCollectors = append(opts.PropertiesCollectors, func() table.PropertiesCollector {
+			return liveValueCollector{newCollector()}
+		})
+	}
+
+	err = table.FlushWithOptions(cf.memtable, f, opts)
+	if err == nil {
+		err = f.Sync()
+	}
+
+	closeErr := f.Close()
+	if err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		db.fs.Remove(tmpPath)
+		return nil, fmt.Errorf("flushing column family %q: %w", cf.name, err)
+	}
+
+	err = db.fs.Rename(tmpPath, path)
+	if err != nil {
+		return nil, fmt.Errorf("renaming table for column family %q: %w", cf.name, err)
+	}
+
+	err = db.fs.SyncDir(cf.dir)
+	if err != nil {
+		return nil, fmt.Errorf("syncing directory of column family %q: %w", cf.name, err)
+	}
+
+	return &familyTable{number: number, path: path}, nil
+}
+
+// liveValueCollector passes the values of a column family's SSTables to a table.PropertiesCollector
+// without their kind, skipping deletions.
+type liveValueCollector struct {
+	table.PropertiesCollector
+}
+
+func (c liveValueCollector) Add(key, encoded []byte) error {
+	kind, value, err := decodeValue(encoded)
+	if err != nil {
+		return err
+	}
+
+	if kind == kindDeletion {
+		return nil
+	}
+
+	return c.PropertiesCollector.Add(key, value)
+}
+
+// TableProperties returns the properties of every SSTable of the column family, from oldest to
+// newest. Writes still in the memtable are not covered.
+func (db *Database) TableProperties(cf *ColumnFamily) ([]*table.Properties, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	err := db.lookupFamily(cf)
+	if err != nil {
+		return nil, err
+	}
+
+	props := make([]*table.Properties, 0, len(cf.tables))
+
+	for _, t := range cf.tables {
+		h, err := db.tables.Acquire(t.path)
+		if err != nil {
+			return nil, err
+		}
+
+		p, err := h.Table().Properties()
+		h.Release()
+
+		if err != nil {
+			return nil, fmt.Errorf("reading properties of %s: %w", t.path, err)
+		}
+
+		props = append(props, p)
+	}
+
+	return props, nil
+}
+
+// release closes every open table and unlocks the database directory.
+func (db *Database) release() {
+	db.tables.Close()
+
+	if db.lock != nil {
+		db.lock.Close()
+	}
+}
+
+// Close closes the write-ahead log and every open table, and unlocks the database directory.
+// Unflushed writes remain in the log and are replayed by the next OpenDatabase.
+func (db *Database) Close() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.closed {
+		return nil
+	}
+
+	db.closed = true
+
+	var err error
+	if db.logFile != nil {
+		err = db.logFile.Close()
+	}
+
+	if db.flushErr != nil {
+		err = fmt.Errorf("flushing memtables: %w", db.flushErr)
+	}
+
+	db.release()
+
+	db.log = nil
+	db.logFile = nil
+	return err
+}
@@ -0,0 +1,804 @@
+package levels
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/savarin/levels/memtable"
+	"github.com/savarin/levels/table"
+	"github.com/savarin/levels/vfs"
+)
+
+type entry struct {
+	Key   []byte
+	Value []byte
+}
+
+var (
+	A = entry{Key: []byte("a"), Value: []byte("alpha")}
+	B = entry{Key: []byte("b"), Value: []byte("bravo")}
+	C = entry{Key: []byte("c"), Value: []byte("charlie")}
+)
+
+func TestColumnFamilies(t *testing.T) {
+	dir := t.TempDir()
+
+	db, err := OpenDatabase(dir, DatabaseOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error when opening database: %s", err)
+	}
+
+	users, err := db.CreateColumnFamily("users", ColumnFamilyOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error when creating column family: %s", err)
+	}
+
+	events, err := db.CreateColumnFamily("events", ColumnFamilyOptions{NewMemtable: func() DB { return memtable.NewSimpleDB() }})
+	if err != nil {
+		t.Fatalf("unexpected error when creating column family: %s", err)
+	}
+
+	_, err = db.CreateColumnFamily("users", ColumnFamilyOptions{})
+	if !errors.Is(err, ColumnFamilyExistsError) {
+		t.Fatalf("expected error when recreating column family, got %v", err)
+	}
+
+	b := NewWriteBatch()
+	b.Put(users, A.Key, A.Value)
+	b.Put(events, A.Key, B.Value)
+	b.Put(users, C.Key, C.Value)
+
+	err = db.Write(b)
+	if err != nil {
+		t.Fatalf("unexpected error when writing batch: %s", err)
+	}
+
+	err = db.Flush()
+	if err != nil {
+		t.Fatalf("unexpected error when flushing: %s", err)
+	}
+
+	err = db.Delete(users, C.Key)
+	if err != nil {
+		t.Fatalf("unexpected error when deleting key %q: %s", C.Key, err)
+	}
+
+	err = db.Put(users, B.Key, B.Value)
+	if err != nil {
+		t.Fatalf("unexpected error when putting key %q: %s", B.Key, err)
+	}
+
+	err = db.Close()
+	if err != nil {
+		t.Fatalf("unexpected error when closing database: %s", err)
+	}
+
+	paths, err := TableFiles(dir)
+	if err != nil {
+		t.Fatalf("unexpected error when listing tables: %s", err)
+	}
+
+	var families []string
+	for _, p := range paths {
+		families = append(families, filepath.Base(filepath.Dir(p)))
+	}
+
+	if !reflect.DeepEqual(families, []string{"events", "users"}) {
+		t.Fatalf("expected one table each for events and users, got %q", paths)
+	}
+
+	db, err = OpenDatabase(dir, DatabaseOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error when reopening database: %s", err)
+	}
+	defer db.Close()
+
+	names := db.ListColumnFamilies()
+	if !reflect.DeepEqual(names, []string{"default", "events", "users"}) {
+		t.Fatalf("unexpected column families %q", names)
+	}
+
+	users, _ = db.ColumnFamily("users")
+	events, _ = db.ColumnFamily("events")
+
+	v, err := db.Get(events, A.Key)
+	if err != nil || string(v) != string(B.Value) {
+		t.Fatalf("expected %q got %q: %v", B.Value, v, err)
+	}
+
+	_, err = db.Get(users, C.Key)
+	if !errors.Is(err, KeyError) {
+		t.Fatalf("expected deleted key %q to be missing, got %v", C.Key, err)
+	}
+
+	iter, err := db.RangeScan(users, []byte{}, []byte{})
+	if err != nil {
+		t.Fatalf("unexpected error when scanning: %s", err)
+	}
+
+	for _, e := range []entry{A, B} {
+		if string(iter.Key()) != string(e.Key) || string(iter.Value()) != string(e.Value) {
+			t.Fatalf("expected %q=%q in scan got %q=%q", e.Key, e.Value, iter.Key(), iter.Value())
+		}
+
+		iter.Next()
+	}
+
+	err = db.DropColumnFamily(events)
+	if err != nil {
+		t.Fatalf("unexpected error when dropping column family: %s", err)
+	}
+
+	_, err = db.Get(events, A.Key)
+	if !errors.Is(err, ColumnFamilyError) {
+		t.Fatalf("expected error when reading dropped column family, got %v", err)
+	}
+
+	dropped := events
+
+	events, err = db.CreateColumnFamily("events", ColumnFamilyOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error when recreating column family: %s", err)
+	}
+
+	// A handle to the dropped column family does not write to the one recreated under its name.
+	err = db.Put(dropped, A.Key, A.Value)
+	if !errors.Is(err, ColumnFamilyError) {
+		t.Fatalf("expected error when writing to dropped column family, got %v", err)
+	}
+
+	_, err = db.Get(events, A.Key)
+	if !errors.Is(err, KeyError) {
+		t.Fatalf("expected recreated column family to be empty, got %v", err)
+	}
+}
+
+func TestDatabaseFS(t *testing.T) {
+	fs := vfs.NewMem()
+
+	db, err := OpenDatabase("db", DatabaseOptions{FS: fs})
+	if err != nil {
+		t.Fatalf("unexpected error when opening database: %s", err)
+	}
+
+	_, err = OpenDatabase("db", DatabaseOptions{FS: fs})
+	if err == nil {
+		t.Fatalf("expected error when opening a database that is already open")
+	}
+
+	cf, _ := db.ColumnFamily(DefaultColumnFamily)
+
+	for _, e := range []entry{A, B} {
+		err = db.Put(cf, e.Key, e.Value)
+		if err != nil {
+			t.Fatalf("unexpected error when putting key %q: %s", e.Key, err)
+		}
+	}
+
+	err = db.Flush()
+	if err != nil {
+		t.Fatalf("unexpected error when flushing: %s", err)
+	}
+
+	err = db.Put(cf, C.Key, C.Value)
+	if err != nil {
+		t.Fatalf("unexpected error when putting key %q: %s", C.Key, err)
+	}
+
+	db.Close()
+
+	names, err := fs.List("db/families/default")
+	if err != nil || !reflect.DeepEqual(names, []string{"000001.sst"}) {
+		t.Fatalf("expected a single table in memory, got %q: %v", names, err)
+	}
+
+	db, err = OpenDatabase("db", DatabaseOptions{FS: fs})
+	if err != nil {
+		t.Fatalf("unexpected error when reopening database: %s", err)
+	}
+
+	cf, _ = db.ColumnFamily(DefaultColumnFamily)
+
+	for _, e := range []entry{A, B, C} {
+		v, err := db.Get(cf, e.Key)
+		if err != nil || string(v) != string(e.Value) {
+			t.Fatalf("unexpected value %q for key %q after reopening: %v", v, e.Key, err)
+		}
+	}
+
+	db.Close()
+
+	// A failed sync of the write-ahead log fails the write.
+	faulty := vfs.NewFaultFS(fs, vfs.FailNth(vfs.OpSync, 1))
+
+	db, err = OpenDatabase("db", DatabaseOptions{FS: faulty, SyncWrites: true})
+	if err != nil {
+		t.Fatalf("unexpected error when reopening database: %s", err)
+	}
+	defer db.Close()
+
+	cf, _ = db.ColumnFamily(DefaultColumnFamily)
+
+	err = db.Put(cf, A.Key, B.Value)
+	if !errors.Is(err, vfs.InjectedError) {
+		t.Fatalf("expected InjectedError when putting with a failed sync, got %v", err)
+	}
+}
+
+func TestDatabaseCrash(t *testing.T) {
+	// A synced write to a new database survives a crash, along with the log that holds it.
+	t.Run("new log", func(t *testing.T) {
+		mem := vfs.NewMem()
+		mem.MkdirAll("db", 0755)
+		mem.SyncDir("/")
+
+		db, err := OpenDatabase("db", DatabaseOptions{FS: mem, SyncWrites: true})
+		if err != nil {
+			t.Fatalf("unexpected error when opening database: %s", err)
+		}
+		defer db.Close()
+
+		cf, _ := db.ColumnFamily(DefaultColumnFamily)
+
+		err = db.Put(cf, A.Key, A.Value)
+		if err != nil {
+			t.Fatalf("unexpected error when putting key %q: %s", A.Key, err)
+		}
+
+		crashed, err := OpenDatabase("db", DatabaseOptions{FS: mem.CrashClone()})
+		if err != nil {
+			t.Fatalf("unexpected error when recovering database: %s", err)
+		}
+		defer crashed.Close()
+
+		cf, _ = crashed.ColumnFamily(DefaultColumnFamily)
+
+		v, err := crashed.Get(cf, A.Key)
+		if err != nil || string(v) != string(A.Value) {
+			t.Fatalf("expected synced write to survive crash, got %q: %v", v, err)
+		}
+	})
+
+	// A crash between the tables of two column families being flushed keeps all of a batch or none.
+	t.Run("flush", func(t *testing.T) {
+		mem := vfs.NewMem()
+		mem.MkdirAll("db", 0755)
+		mem.SyncDir("/")
+
+		var points []*vfs.MemFS
+		fs := vfs.NewFaultFS(mem, func(op vfs.Op, name string) error {
+			if op == vfs.OpSyncDir {
+				points = append(points, mem.CrashClone())
+			}
+
+			return nil
+		})
+
+		db, err := OpenDatabase("db", DatabaseOptions{FS: fs})
+		if err != nil {
+			t.Fatalf("unexpected error when opening database: %s", err)
+		}
+		defer db.Close()
+
+		other, err := db.CreateColumnFamily("other", ColumnFamilyOptions{})
+		if err != nil {
+			t.Fatalf("unexpected error when creating column family: %s", err)
+		}
+
+		cf, _ := db.ColumnFamily(DefaultColumnFamily)
+
+		b := NewWriteBatch()
+		b.Put(cf, A.Key, A.Value)
+		b.Put(other, B.Key, B.Value)
+
+		err = db.Write(b)
+		if err != nil {
+			t.Fatalf("unexpected error when writing batch: %s", err)
+		}
+
+		points = points[:0]
+
+		err = db.Flush()
+		if err != nil {
+			t.Fatalf("unexpected error when flushing: %s", err)
+		}
+
+		for i, point := range points {
+			crashed, err := OpenDatabase("db", DatabaseOptions{FS: point})
+			if err != nil {
+				t.Fatalf("crash point %d: unexpected error when recovering database: %s", i, err)
+			}
+
+			cf, _ := crashed.ColumnFamily(DefaultColumnFamily)
+			other, _ := crashed.ColumnFamily("other")
+
+			_, errA := crashed.Get(cf, A.Key)
+			_, errB := crashed.Get(other, B.Key)
+			crashed.Close()
+
+			if (errA == nil) != (errB == nil) {
+				t.Fatalf("crash point %d: expected all of a batch or none, got errors %v and %v", i, errA, errB)
+			}
+		}
+	})
+
+	// A dropped column family stays dropped once a later write is synced.
+	t.Run("drop", func(t *testing.T) {
+		mem := vfs.NewMem()
+		mem.MkdirAll("db", 0755)
+		mem.SyncDir("/")
+
+		db, err := OpenDatabase("db", DatabaseOptions{FS: mem, SyncWrites: true})
+		if err != nil {
+			t.Fatalf("unexpected error when opening database: %s", err)
+		}
+		defer db.Close()
+
+		x, err := db.CreateColumnFamily("x", ColumnFamilyOptions{})
+		if err != nil {
+			t.Fatalf("unexpected error when creating column family: %s", err)
+		}
+
+		err = db.Put(x, A.Key, A.Value)
+		if err != nil {
+			t.Fatalf("unexpected error when putting key %q: %s", A.Key, err)
+		}
+
+		err = db.DropColumnFamily(x)
+		if err != nil {
+			t.Fatalf("unexpected error when dropping column family: %s", err)
+		}
+
+		cf, _ := db.ColumnFamily(DefaultColumnFamily)
+
+		err = db.Put(cf, B.Key, B.Value)
+		if err != nil {
+			t.Fatalf("unexpected error when putting key %q: %s", B.Key, err)
+		}
+
+		crashed, err := OpenDatabase("db", DatabaseOptions{FS: mem.CrashClone()})
+		if err != nil {
+			t.Fatalf("unexpected error when recovering database: %s", err)
+		}
+		defer crashed.Close()
+
+		names := crashed.ListColumnFamilies()
+		if !reflect.DeepEqual(names, []string{DefaultColumnFamily}) {
+			t.Fatalf("expected dropped column family to stay dropped, got %q", names)
+		}
+	})
+}
+
+func TestDatabaseFlushError(t *testing.T) {
+	mem := vfs.NewMem()
+	fs := vfs.NewFaultFS(mem, vfs.FailNth(vfs.OpCreate, 1))
+
+	db, err := OpenDatabase("db", DatabaseOptions{FS: fs, MemtableSize: 1})
+	if err != nil {
+		t.Fatalf("unexpected error when opening database: %s", err)
+	}
+
+	cf, _ := db.ColumnFamily(DefaultColumnFamily)
+
+	// The write that fills the memtable succeeds even though the flush it starts fails.
+	err = db.Put(cf, A.Key, A.Value)
+	if err != nil {
+		t.Fatalf("unexpected error when putting key with failed flush: %s", err)
+	}
+
+	v, err := db.Get(cf, A.Key)
+	if err != nil || string(v) != string(A.Value) {
+		t.Fatalf("expected %q after failed flush, got %q: %v", A.Value, v, err)
+	}
+
+	// The next write retries the flush.
+	err = db.Put(cf, B.Key, B.Value)
+	if err != nil {
+		t.Fatalf("unexpected error when putting key after failed flush: %s", err)
+	}
+
+	names, _ := mem.List("db/families/default")
+	if len(names) == 0 {
+		t.Fatalf("expected the flush to be retried")
+	}
+
+	// A flush that keeps failing fails the next write, and Close reports it.
+	fs.SetInjector(func(op vfs.Op, name string) error {
+		if op == vfs.OpCreate {
+			return vfs.InjectedError
+		}
+
+		return nil
+	})
+
+	err = db.Put(cf, C.Key, C.Value)
+	if err != nil {
+		t.Fatalf("unexpected error when putting key with failed flush: %s", err)
+	}
+
+	err = db.Put(cf, C.Key, B.Value)
+	if !errors.Is(err, vfs.InjectedError) {
+		t.Fatalf("expected InjectedError when writing after failed flush, got %v", err)
+	}
+
+	err = db.Close()
+	if !errors.Is(err, vfs.InjectedError) {
+		t.Fatalf("expected InjectedError when closing after failed flush, got %v", err)
+	}
+
+	db, err = OpenDatabase("db", DatabaseOptions{FS: mem})
+	if err != nil {
+		t.Fatalf("unexpected error when reopening database: %s", err)
+	}
+	defer db.Close()
+
+	cf, _ = db.ColumnFamily(DefaultColumnFamily)
+
+	for _, e := range []entry{A, B, C} {
+		v, err := db.Get(cf, e.Key)
+		if err != nil || string(v) != string(e.Value) {
+			t.Fatalf("unexpected value %q for key %q after reopening: %v", v, e.Key, err)
+		}
+	}
+}
+
+func TestDatabaseRangeScan(t *testing.T) {
+	db, err := OpenDatabase(t.TempDir(), DatabaseOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error when opening database: %s", err)
+	}
+	defer db.Close()
+
+	cf, _ := db.ColumnFamily(DefaultColumnFamily)
+	expected := make(map[string]string)
+
+	// Each round overwrites or deletes some keys of the rounds before, in SSTables and then the
+	// memtable, with enough keys per table to read them in several chunks.
+	for round := 0; round < 4; round++ {
+		for i := round; i < 1000; i += round + 1 {
+			key := fmt.Sprintf("key%04d", i)
+
+			if i%7 == round {
+				err = db.Delete(cf, []byte(key))
+				delete(expected, key)
+			} else {
+				value := fmt.Sprintf("value%d-%d", i, round)
+				err = db.Put(cf, []byte(key), []byte(value))
+				expected[key] = value
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error when writing key %q: %s", key, err)
+			}
+		}
+
+		if round < 3 {
+			err = db.Flush()
+			if err != nil {
+				t.Fatalf("unexpected error when flushing: %s", err)
+			}
+		}
+	}
+
+	start, limit := "key0100", "key0900"
+
+	iter, err := db.RangeScan(cf, []byte(start), []byte(limit))
+	if err != nil {
+		t.Fatalf("unexpected error when scanning: %s", err)
+	}
+
+	// Writes after the scan starts, even once flushed, are not seen by it.
+	err = db.Put(cf, []byte("key0500"), []byte("later"))
+	if err != nil {
+		t.Fatalf("unexpected error when putting key: %s", err)
+	}
+
+	err = db.Flush()
+	if err != nil {
+		t.Fatalf("unexpected error when flushing: %s", err)
+	}
+
+	scanned := make(map[string]string)
+	var previous string
+
+	for key := iter.Key(); key != nil; key = iter.Key() {
+		if string(key) <= previous || string(key) < start || string(key) >= limit {
+			t.Fatalf("unexpected key %q in scan after %q", key, previous)
+		}
+
+		previous = string(key)
+		scanned[string(key)] = string(iter.Value())
+
+		if !iter.Next() {
+			break
+		}
+	}
+
+	if err := iter.Error(); err != nil {
+		t.Fatalf("unexpected error when scanning: %s", err)
+	}
+
+	for key, value := range expected {
+		if key >= start && key < limit && scanned[key] != value {
+			t.Fatalf("expected %q=%q in scan got %q", key, value, scanned[key])
+		}
+	}
+
+	for key := range scanned {
+		if _, ok := expected[key]; !ok {
+			t.Fatalf("unexpected deleted key %q in scan", key)
+		}
+	}
+}
+
+// snapshotDir returns the contents of every file under dir, keyed by path.
+func snapshotDir(t *testing.T, dir string) map[string]string {
+	files := make(map[string]string)
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		data, err := os.ReadFile(path)
+		files[path] = string(data)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("unexpected error when reading directory: %s", err)
+	}
+
+	return files
+}
+
+func TestDatabaseReadOnly(t *testing.T) {
+	dir := t.TempDir()
+
+	db, err := OpenDatabase(dir, DatabaseOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error when opening database: %s", err)
+	}
+
+	cf, _ := db.ColumnFamily(DefaultColumnFamily)
+
+	for i, e := range []entry{A, B, C} {
+		err = db.Put(cf, e.Key, e.Value)
+		if err != nil {
+			t.Fatalf("unexpected error when putting key %q: %s", e.Key, err)
+		}
+
+		if i == 0 {
+			err = db.Flush()
+			if err != nil {
+				t.Fatalf("unexpected error when flushing: %s", err)
+			}
+		}
+	}
+
+	// The database is left open, so opening it read-only must not take its lock. A torn record at
+	// the end of the log must be left in place.
+	defer db.Close()
+
+	log, err := os.OpenFile(filepath.Join(dir, logFileName), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("unexpected error when opening log: %s", err)
+	}
+
+	_, err = log.Write([]byte{1, 2, 3})
+	log.Close()
+	if err != nil {
+		t.Fatalf("unexpected error when tearing log: %s", err)
+	}
+
+	before := snapshotDir(t, dir)
+
+	readOnly, err := OpenDatabase(dir, DatabaseOptions{ReadOnly: true})
+	if err != nil {
+		t.Fatalf("unexpected error when opening database read-only: %s", err)
+	}
+
+	cf, _ = readOnly.ColumnFamily(DefaultColumnFamily)
+
+	for _, e := range []entry{A, B, C} {
+		v, err := readOnly.Get(cf, e.Key)
+		if err != nil || string(v) != string(e.Value) {
+			t.Fatalf("unexpected value %q for key %q: %v", v, e.Key, err)
+		}
+	}
+
+	if err := readOnly.Put(cf, A.Key, B.Value); !errors.Is(err, ReadOnlyError) {
+		t.Fatalf("expected ReadOnlyError when writing, got %v", err)
+	}
+
+	if err := readOnly.Flush(); !errors.Is(err, ReadOnlyError) {
+		t.Fatalf("expected ReadOnlyError when flushing, got %v", err)
+	}
+
+	if _, err := readOnly.CreateColumnFamily("users", ColumnFamilyOptions{}); !errors.Is(err, ReadOnlyError) {
+		t.Fatalf("expected ReadOnlyError when creating column family, got %v", err)
+	}
+
+	err = readOnly.Close()
+	if err != nil {
+		t.Fatalf("unexpected error when closing database: %s", err)
+	}
+
+	if after := snapshotDir(t, dir); !reflect.DeepEqual(before, after) {
+		t.Fatalf("expected read-only database to leave directory unchanged")
+	}
+
+	empty := t.TempDir()
+
+	_, err = OpenDatabase(empty, DatabaseOptions{ReadOnly: true})
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected error when opening directory without a database, got %v", err)
+	}
+
+	if names, _ := os.ReadDir(empty); len(names) != 0 {
+		t.Fatalf("expected read-only open to create nothing, got %d entries", len(names))
+	}
+}
+
+func TestIndexedBatch(t *testing.T) {
+	db, err := OpenDatabase(t.TempDir(), DatabaseOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error when opening database: %s", err)
+	}
+	defer db.Close()
+
+	cf, _ := db.ColumnFamily(DefaultColumnFamily)
+
+	for _, e := range []entry{A, B} {
+		err := db.Put(cf, e.Key, e.Value)
+		if err != nil {
+			t.Fatalf("unexpected error when putting key %q: %s", e.Key, err)
+		}
+	}
+
+	b := NewIndexedBatch()
+	b.Delete(cf, A.Key)
+	b.Put(cf, B.Key, []byte("beta"))
+	b.Put(cf, C.Key, C.Value)
+
+	base := db.Reader(cf)
+
+	_, err = b.Get(base, cf, A.Key)
+	if !errors.Is(err, KeyError) {
+		t.Fatalf("expected batched delete to hide key %q, got %v", A.Key, err)
+	}
+
+	iter, err := b.RangeScan(base, cf, []byte{}, []byte{})
+	if err != nil {
+		t.Fatalf("unexpected error when scanning: %s", err)
+	}
+
+	expected := []entry{{Key: B.Key, Value: []byte("beta")}, C}
+	for i, e := range expected {
+		if string(iter.Key()) != string(e.Key) || string(iter.Value()) != string(e.Value) {
+			t.Fatalf("expected %q=%q in scan got %q=%q", e.Key, e.Value, iter.Key(), iter.Value())
+		}
+
+		if iter.Next() != (i < len(expected)-1) {
+			t.Fatalf("unexpected end of scan after key %q", e.Key)
+		}
+	}
+
+	if _, err := db.Get(cf, C.Key); !errors.Is(err, KeyError) {
+		t.Fatalf("expected batch to be uncommitted, got %v", err)
+	}
+
+	err = db.Write(&b.WriteBatch)
+	if err != nil {
+		t.Fatalf("unexpected error when writing batch: %s", err)
+	}
+
+	v, err := db.Get(cf, B.Key)
+	if err != nil || string(v) != "beta" {
+		t.Fatalf("expected %q got %q: %v", "beta", v, err)
+	}
+}
+
+// timestampCollector records the smallest and largest timestamps, stored as decimal values.
+type timestampCollector struct {
+	min, max int64
+	seen     bool
+}
+
+func (c *timestampCollector) Name() string {
+	return "timestamps"
+}
+
+func (c *timestampCollector) Add(key, value []byte) error {
+	ts, err := strconv.ParseInt(string(value), 10, 64)
+	if err != nil {
+		return err
+	}
+
+	if !c.seen || ts < c.min {
+		c.min = ts
+	}
+
+	if !c.seen || ts > c.max {
+		c.max = ts
+	}
+
+	c.seen = true
+	return nil
+}
+
+func (c *timestampCollector) Finish() (map[string][]byte, error) {
+	return map[string][]byte{
+		"app.min.timestamp": []byte(strconv.FormatInt(c.min, 10)),
+		"app.max.timestamp": []byte(strconv.FormatInt(c.max, 10)),
+	}, nil
+}
+
+func TestPropertiesCollector(t *testing.T) {
+	dir := t.TempDir()
+	opts := ColumnFamilyOptions{
+		PropertiesCollectors: []func() table.PropertiesCollector{
+			func() table.PropertiesCollector { return &timestampCollector{} },
+		},
+	}
+
+	db, err := OpenDatabase(dir, DatabaseOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error when opening database: %s", err)
+	}
+
+	events, err := db.CreateColumnFamily("events", opts)
+	if err != nil {
+		t.Fatalf("unexpected error when creating column family: %s", err)
+	}
+
+	for i, ts := range []string{"300", "100", "200"} {
+		err = db.Put(events, []byte(fmt.Sprintf("event%d", i)), []byte(ts))
+		if err != nil {
+			t.Fatalf("unexpected error when putting: %s", err)
+		}
+	}
+
+	// Deletions carry no value, so the collector must not see them.
+	err = db.Delete(events, []byte("event9"))
+	if err != nil {
+		t.Fatalf("unexpected error when deleting: %s", err)
+	}
+
+	err = db.Flush()
+	if err != nil {
+		t.Fatalf("unexpected error when flushing: %s", err)
+	}
+
+	db.Close()
+
+	db, err = OpenDatabase(dir, DatabaseOptions{ColumnFamilies: map[string]ColumnFamilyOptions{"events": opts}})
+	if err != nil {
+		t.Fatalf("unexpected error when reopening database: %s", err)
+	}
+	defer db.Close()
+
+	events, err = db.ColumnFamily("events")
+	if err != nil {
+		t.Fatalf("unexpected error when looking up column family: %s", err)
+	}
+
+	props, err := db.TableProperties(events)
+	if err != nil || len(props) != 1 {
+		t.Fatalf("expected properties of one table, got %d: %v", len(props), err)
+	}
+
+	if props[0].NumEntries != 4 || props[0].NumDeletions != 1 {
+		t.Fatalf("expected 4 entries and 1 deletion, got %d and %d", props[0].NumEntries, props[0].NumDeletions)
+	}
+
+	min, max := props[0].User["app.min.timestamp"], props[0].User["app.max.timestamp"]
+	if string(min) != "100" || string(max) != "300" {
+		t.Fatalf("expected timestamps from 100 to 300, got %q to %q", min, max)
+	}
+}
@@ -0,0 +1,439 @@
+// Package dbtest implements a conformance suite for implementations of levels.DB: edge cases of
+// every operation, and random sequences of operations checked against a map, including after the
+// DB is flushed to an SSTable and opened again.
+package dbtest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/savarin/levels/internal/base"
+	"github.com/savarin/levels/table"
+)
+
+// TestDB runs the conformance suite against the DB implementation created by newDB, which must
+// return a new, empty DB on every call. The suite holds implementations to the contract of the
+// in-memory collections: a nil key is the empty key, Get of a missing key and Delete of a missing
+// key return KeyError, RangeScan excludes its limit and treats an empty one as unbounded, and
+// RangeScan returns ValueError if start is after a non-empty limit.
+func TestDB(t *testing.T, newDB func() base.DB) {
+	t.Run("PutGet", func(t *testing.T) { testPutGet(t, newDB()) })
+	t.Run("Overwrite", func(t *testing.T) { testOverwrite(t, newDB()) })
+	t.Run("EmptyKey", func(t *testing.T) { testEmptyKey(t, newDB()) })
+	t.Run("NilValue", func(t *testing.T) { testNilValue(t, newDB()) })
+	t.Run("Delete", func(t *testing.T) { testDelete(t, newDB()) })
+	t.Run("Ranges", func(t *testing.T) { testRanges(t, newDB()) })
+	t.Run("Flush", func(t *testing.T) { testFlush(t, newDB()) })
+	t.Run("Random", func(t *testing.T) {
+		// Each seed runs as its own subtest, so that a failure names the seed that reproduces it.
+		for seed := int64(1); seed <= randomSeeds; seed++ {
+			t.Run(fmt.Sprintf("seed=%d", seed), func(t *testing.T) { testRandom(t, newDB, seed) })
+		}
+	})
+}
+
+// Model is the reference a DB is checked against: a map from keys to values.
+type Model map[string][]byte
+
+func (m Model) sortedKeys() []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// scan returns the pairs of the model in [start, limit), or from start onwards if limit is empty.
+func (m Model) scan(start, limit []byte) [][2]string {
+	var pairs [][2]string
+	for _, k := range m.sortedKeys() {
+		if k >= string(start) && (len(limit) == 0 || k < string(limit)) {
+			pairs = append(pairs, [2]string{k, string(m[k])})
+		}
+	}
+
+	return pairs
+}
+
+// Scan returns the pairs read from db in [start, limit), following the consumer loop every
+// Iterator supports.
+func Scan(db base.ImmutableDB, start, limit []byte) ([][2]string, error) {
+	iter, err := db.RangeScan(start, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	var pairs [][2]string
+	for key := iter.Key(); key != nil; key = iter.Key() {
+		pairs = append(pairs, [2]string{string(key), string(iter.Value())})
+
+		if !iter.Next() {
+			break
+		}
+	}
+
+	return pairs, iter.Error()
+}
+
+// Check compares every key of keyspace, and ranges between them, in db with the model.
+func Check(t testing.TB, db base.ImmutableDB, model Model, keyspace [][]byte) {
+	t.Helper()
+
+	for _, key := range keyspace {
+		want, ok := model[string(key)]
+
+		v, err := db.Get(key)
+		if ok && (err != nil || !bytes.Equal(v, want)) {
+			t.Fatalf("expected value %q for key %q, got %q: %v", want, key, v, err)
+		}
+
+		if !ok && !errors.Is(err, base.KeyError) {
+			t.Fatalf("expected KeyError for missing key %q, got %q: %v", key, v, err)
+		}
+
+		has, err := db.Has(key)
+		if err != nil || has != ok {
+			t.Fatalf("expected Has to report %t for key %q, got %t: %v", ok, key, has, err)
+		}
+	}
+
+	checkScan(t, db, model, nil, nil)
+
+	for i, start := range keyspace {
+		for _, limit := range [][]byte{nil, keyspace[(i+1)%len(keyspace)], keyspace[(i+len(keyspace)/2)%len(keyspace)]} {
+			checkScan(t, db, model, start, limit)
+		}
+	}
+}
+
+func checkScan(t testing.TB, db base.ImmutableDB, model Model, start, limit []byte) {
+	t.Helper()
+
+	got, err := Scan(db, start, limit)
+
+	if len(limit) > 0 && string(start) > string(limit) {
+		if !errors.Is(err, base.ValueError) {
+			t.Fatalf("expected ValueError when scanning from %q to %q, got %v", start, limit, err)
+		}
+
+		return
+	}
+
+	if err != nil {
+		t.Fatalf("unexpected error when scanning from %q to %q: %s", start, limit, err)
+	}
+
+	want := model.scan(start, limit)
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("expected %q when scanning from %q to %q, got %q", want, start, limit, got)
+	}
+}
+
+// flush writes db to an SSTable and opens it.
+func flush(t testing.TB, db base.DB) base.ImmutableDB {
+	t.Helper()
+
+	var buf bytes.Buffer
+	err := db.Flush(&buf)
+	if err != nil {
+		t.Fatalf("unexpected error when flushing: %s", err)
+	}
+
+	sst, err := table.Open(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("unexpected error when opening flushed table: %s", err)
+	}
+
+	return sst
+}
+
+func put(t testing.TB, db base.DB, model Model, key, value []byte) {
+	t.Helper()
+
+	err := db.Put(key, value)
+	if err != nil {
+		t.Fatalf("unexpected error when putting key %q with value %q: %s", key, value, err)
+	}
+
+	model[string(key)] = value
+}
+
+func keys(names ...string) [][]byte {
+	keys := make([][]byte, len(names))
+	for i, name := range names {
+		keys[i] = []byte(name)
+	}
+
+	return keys
+}
+
+func testPutGet(t *testing.T, db base.DB) {
+	model := Model{}
+	keyspace := keys("a", "b", "c", "d")
+
+	Check(t, db, model, keyspace)
+
+	for _, key := range keyspace[:3] {
+		put(t, db, model, key, append([]byte("value-"), key...))
+	}
+
+	Check(t, db, model, keyspace)
+}
+
+func testOverwrite(t *testing.T, db base.DB) {
+	model := Model{}
+	keyspace := keys("a", "b")
+
+	put(t, db, model, keyspace[0], []byte("first"))
+	put(t, db, model, keyspace[0], []byte("second"))
+	put(t, db, model, keyspace[1], []byte("third"))
+	put(t, db, model, keyspace[0], []byte("fourth"))
+
+	Check(t, db, model, keyspace)
+}
+
+func testEmptyKey(t *testing.T, db base.DB) {
+	model := Model{}
+	keyspace := keys("", "a")
+
+	// A nil key is the same key as an empty one.
+	put(t, db, model, nil, []byte("nil"))
+	put(t, db, model, keyspace[1], []byte("alpha"))
+
+	Check(t, db, model, keyspace)
+	Check(t, flush(t, db), model, keyspace)
+
+	put(t, db, model, []byte{}, []byte("empty"))
+	Check(t, db, model, keyspace)
+
+	err := db.Delete(nil)
+	if err != nil {
+		t.Fatalf("unexpected error when deleting empty key: %s", err)
+	}
+
+	delete(model, "")
+	Check(t, db, model, keyspace)
+}
+
+func testNilValue(t *testing.T, db base.DB) {
+	model := Model{}
+	keyspace := keys("a", "b", "c")
+
+	put(t, db, model, keyspace[0], nil)
+	put(t, db, model, keyspace[1], []byte{})
+	put(t, db, model, keyspace[2], []byte("charlie"))
+
+	Check(t, db, model, keyspace)
+	Check(t, flush(t, db), model, keyspace)
+}
+
+func testDelete(t *testing.T, db base.DB) {
+	model := Model{}
+	keyspace := keys("a", "b", "c")
+
+	err := db.Delete(keyspace[0])
+	if !errors.Is(err, base.KeyError) {
+		t.Fatalf("expected KeyError when deleting from empty DB, got %v", err)
+	}
+
+	put(t, db, model, keyspace[0], []byte("alpha"))
+	put(t, db, model, keyspace[1], []byte("bravo"))
+
+	err = db.Delete(keyspace[0])
+	if err != nil {
+		t.Fatalf("unexpected error when deleting key %q: %s", keyspace[0], err)
+	}
+
+	delete(model, string(keyspace[0]))
+
+	for _, key := range [][]byte{keyspace[0], keyspace[2]} {
+		err = db.Delete(key)
+		if !errors.Is(err, base.KeyError) {
+			t.Fatalf("expected KeyError when deleting missing key %q, got %v", key, err)
+		}
+	}
+
+	Check(t, db, model, keyspace)
+
+	// A deleted key can be put again.
+	put(t, db, model, keyspace[0], []byte("again"))
+	Check(t, db, model, keyspace)
+}
+
+func testRanges(t *testing.T, db base.DB) {
+	model := Model{}
+	keyspace := keys("", "a", "aa", "ab", "b", "ba", "c", "d", "\xff")
+
+	for _, key := range keys("a", "ab", "b", "c") {
+		put(t, db, model, key, append([]byte("value-"), key...))
+	}
+
+	for _, tt := range []struct {
+		start, limit string
+		want         string
+	}{
+		{"", "", "[a ab b c]"},
+		{"a", "c", "[a ab b]"},
+		{"a", "b", "[a ab]"},
+		{"aa", "ab", "[]"},
+		{"ab", "ab", "[]"},
+		{"b", "b", "[]"},
+		{"b", "", "[b c]"},
+		{"d", "", "[]"},
+		{"", "a", "[]"},
+		{"", "\xff", "[a ab b c]"},
+	} {
+		pairs, err := Scan(db, []byte(tt.start), []byte(tt.limit))
+		if err != nil {
+			t.Fatalf("unexpected error when scanning from %q to %q: %s", tt.start, tt.limit, err)
+		}
+
+		var got []string
+		for _, p := range pairs {
+			got = append(got, p[0])
+		}
+
+		if fmt.Sprint(got) != tt.want {
+			t.Fatalf("expected keys %s when scanning from %q to %q, got %q", tt.want, tt.start, tt.limit, got)
+		}
+	}
+
+	_, err := db.RangeScan([]byte("c"), []byte("a"))
+	if !errors.Is(err, base.ValueError) {
+		t.Fatalf("expected ValueError when scanning from after the limit, got %v", err)
+	}
+
+	Check(t, db, model, keyspace)
+}
+
+func testFlush(t *testing.T, db base.DB) {
+	model := Model{}
+	keyspace := make([][]byte, 0, 200)
+
+	for i := 0; i < 200; i++ {
+		keyspace = append(keyspace, []byte(fmt.Sprintf("key%04d", i)))
+	}
+
+	for i := 0; i < len(keyspace); i += 2 {
+		put(t, db, model, keyspace[i], bytes.Repeat([]byte{byte(i)}, i%64))
+	}
+
+	Check(t, flush(t, db), model, keyspace)
+
+	// The DB is unchanged by flushing, and can be flushed again after further writes.
+	Check(t, db, model, keyspace)
+
+	for i := 0; i < len(keyspace); i += 4 {
+		err := db.Delete(keyspace[i])
+		if err != nil {
+			t.Fatalf("unexpected error when deleting key %q: %s", keyspace[i], err)
+		}
+
+		delete(model, string(keyspace[i]))
+	}
+
+	Check(t, flush(t, db), model, keyspace)
+
+	empty := flush(t, emptied(t, db, model))
+	Check(t, empty, Model{}, keyspace)
+}
+
+// emptied deletes every key of the model from db.
+func emptied(t *testing.T, db base.DB, model Model) base.DB {
+	for _, key := range model.sortedKeys() {
+		err := db.Delete([]byte(key))
+		if err != nil {
+			t.Fatalf("unexpected error when deleting key %q: %s", key, err)
+		}
+
+		delete(model, key)
+	}
+
+	return db
+}
+
+// randomKeyspace returns keys that share prefixes, so that ranges between them are interesting,
+// along with the empty key.
+func randomKeyspace(rng *rand.Rand, n int) [][]byte {
+	seen := map[string]bool{"": true}
+	keyspace := [][]byte{{}}
+
+	for len(keyspace) < n {
+		key := make([]byte, 1+rng.Intn(4))
+		for i := range key {
+			key[i] = "abc\x00\xff"[rng.Intn(5)]
+		}
+
+		if !seen[string(key)] {
+			seen[string(key)] = true
+			keyspace = append(keyspace, key)
+		}
+	}
+
+	return keyspace
+}
+
+// randomSeeds is the number of seeds the random operations are run with, each giving a different
+// keyspace and sequence of operations.
+const randomSeeds = 4
+
+// testRandom runs random operations against the DB and the model, checking every read as it goes
+// and the whole DB, along with an SSTable flushed from it, every so often.
+func testRandom(t *testing.T, newDB func() base.DB, seed int64) {
+	rng := rand.New(rand.NewSource(seed))
+	keyspace := randomKeyspace(rng, 40)
+
+	db := newDB()
+	model := Model{}
+
+	for i := 0; i < 5000; i++ {
+		key := keyspace[rng.Intn(len(keyspace))]
+
+		switch op := rng.Intn(10); {
+		case op < 4:
+			var value []byte
+			if rng.Intn(10) > 0 {
+				value = make([]byte, rng.Intn(16))
+				rng.Read(value)
+			}
+
+			put(t, db, model, key, value)
+		case op < 6:
+			_, ok := model[string(key)]
+
+			err := db.Delete(key)
+			if ok && err != nil {
+				t.Fatalf("operation %d: unexpected error when deleting key %q: %s", i, key, err)
+			}
+
+			if !ok && !errors.Is(err, base.KeyError) {
+				t.Fatalf("operation %d: expected KeyError when deleting missing key %q, got %v", i, key, err)
+			}
+
+			delete(model, string(key))
+		case op < 8:
+			want, ok := model[string(key)]
+
+			v, err := db.Get(key)
+			if ok != (err == nil) || !bytes.Equal(v, want) {
+				t.Fatalf("operation %d: expected value %q for key %q, got %q: %v", i, want, key, v, err)
+			}
+		case op < 9:
+			checkScan(t, db, model, key, keyspace[rng.Intn(len(keyspace))])
+		default:
+			if rng.Intn(20) == 0 {
+				Check(t, db, model, keyspace)
+				Check(t, flush(t, db), model, keyspace)
+			}
+		}
+	}
+
+	Check(t, db, model, keyspace)
+	Check(t, flush(t, db), model, keyspace)
+}
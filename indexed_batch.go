@@ -0,0 +1,169 @@
+package levels
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/savarin/levels/memtable"
+)
+
+// IndexedBatch is a WriteBatch that also indexes its writes in a skip list per column family, so
+// that pending puts and deletes can be read back merged with a base DB before the batch is written.
+type IndexedBatch struct {
+	WriteBatch
+	index map[string]*memtable.SkipListDB
+}
+
+func NewIndexedBatch() *IndexedBatch {
+	return &IndexedBatch{
+		index: make(map[string]*memtable.SkipListDB),
+	}
+}
+
+func (b *IndexedBatch) familyIndex(cf *ColumnFamily) *memtable.SkipListDB {
+	index, ok := b.index[cf.name]
+	if !ok {
+		index = memtable.NewSkipListDB()
+		b.index[cf.name] = index
+	}
+
+	return index
+}
+
+// Put records setting the value for the given key in the column family.
+func (b *IndexedBatch) Put(cf *ColumnFamily, key, value []byte) {
+	b.WriteBatch.Put(cf, key, value)
+	b.familyIndex(cf).Put(key, encodeValue(kindValue, value))
+}
+
+// Delete records deleting the given key from the column family.
+func (b *IndexedBatch) Delete(cf *ColumnFamily, key []byte) {
+	b.WriteBatch.Delete(cf, key)
+	b.familyIndex(cf).Put(key, encodeValue(kindDeletion, nil))
+}
+
+// Reset removes all operations from the batch so it can be reused.
+func (b *IndexedBatch) Reset() {
+	b.WriteBatch.Reset()
+	b.index = make(map[string]*memtable.SkipListDB)
+}
+
+// Get returns the value for the given key as if the batch had been applied to base, which should
+// read from the same column family as cf.
+func (b *IndexedBatch) Get(base ImmutableDB, cf *ColumnFamily, key []byte) (value []byte, err error) {
+	encoded, err := b.familyIndex(cf).Get(key)
+	if errors.Is(err, KeyError) {
+		return base.Get(key)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	kind, v, err := decodeValue(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	if kind == kindDeletion {
+		return nil, KeyError
+	}
+
+	return v, nil
+}
+
+// RangeScan returns an Iterator over the key/value pairs in the given range as if the batch had been
+// applied to base, which should read from the same column family as cf.
+func (b *IndexedBatch) RangeScan(base ImmutableDB, cf *ColumnFamily, start, limit []byte) (Iterator, error) {
+	baseIter, err := base.RangeScan(start, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	batchIter, err := b.familyIndex(cf).RangeScan(start, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	iter := &batchIterator{
+		base:  newCursor(baseIter, start, limit),
+		batch: newCursor(batchIter, start, limit),
+	}
+
+	iter.settle()
+	return iter, nil
+}
+
+// batchIterator merges the pending writes of an IndexedBatch with the pairs of a base Iterator.
+// Where both have the same key, the batch wins, and batched deletes hide the base pair.
+type batchIterator struct {
+	base, batch *cursor
+	key, value  []byte
+	err         error
+}
+
+// settle moves the iterator to the next visible pair. It returns false, leaving the iterator on its
+// current pair, if there is none.
+func (iter *batchIterator) settle() bool {
+	for iter.base.valid || iter.batch.valid {
+		fromBatch := !iter.base.valid
+
+		if iter.base.valid && iter.batch.valid {
+			c := bytes.Compare(iter.base.iter.Key(), iter.batch.iter.Key())
+
+			if c == 0 {
+				iter.base.advance()
+			}
+
+			fromBatch = c >= 0
+		}
+
+		if !fromBatch {
+			iter.key, iter.value = iter.base.iter.Key(), iter.base.iter.Value()
+			iter.base.advance()
+			return true
+		}
+
+		key := iter.batch.iter.Key()
+		kind, value, err := decodeValue(iter.batch.iter.Value())
+		iter.batch.advance()
+
+		if err != nil {
+			iter.err = err
+			return false
+		}
+
+		if kind == kindDeletion {
+			continue
+		}
+
+		iter.key, iter.value = key, value
+		return true
+	}
+
+	return false
+}
+
+func (iter *batchIterator) Next() bool {
+	if iter.key == nil {
+		return false
+	}
+
+	return iter.settle()
+}
+
+func (iter *batchIterator) Error() error {
+	if iter.err != nil {
+		return iter.err
+	}
+
+	return iter.base.iter.Error()
+}
+
+func (iter *batchIterator) Key() []byte {
+	return iter.key
+}
+
+func (iter *batchIterator) Value() []byte {
+	return iter.value
+}
@@ -0,0 +1,131 @@
+// Package base holds the definitions shared by the memtables, the table format and the database,
+// which are re-exported by package levels.
+package base
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/savarin/levels/iterator"
+)
+
+const (
+	// MaxKeySize and MaxValueSize are the largest key and value, in bytes, that can be stored. Tables
+	// encode lengths as uvarints, but batches in the write-ahead log encode them in 32 bits, so
+	// MaxValueSize is the largest length a logged write can hold.
+	MaxKeySize   = 1 << 16
+	MaxValueSize = math.MaxUint32
+)
+
+var (
+	KeyError   = errors.New("Key not found")
+	ValueError = errors.New("Inappropriate value")
+)
+
+// SizeError is returned when a key or value is larger than MaxKeySize or MaxValueSize.
+type SizeError struct {
+	// Field is either "key" or "value".
+	Field string
+	Size  uint64
+	Limit uint64
+}
+
+func (e *SizeError) Error() string {
+	return fmt.Sprintf("%s of %d bytes exceeds limit of %d bytes", e.Field, e.Size, e.Limit)
+}
+
+// CheckSize returns a SizeError if the key or value is too large to store.
+func CheckSize(key, value []byte) error {
+	if len(key) > MaxKeySize {
+		return &SizeError{Field: "key", Size: uint64(len(key)), Limit: MaxKeySize}
+	}
+
+	// The length is widened before comparing, since MaxValueSize overflows int on 32-bit platforms.
+	if uint64(len(value)) > MaxValueSize {
+		return &SizeError{Field: "value", Size: uint64(len(value)), Limit: MaxValueSize}
+	}
+
+	return nil
+}
+
+type Item struct {
+	Key, Value []byte
+
+	// Expiry is the time, in Unix nanoseconds, after which the item is no longer visible. Zero means
+	// the item never expires.
+	Expiry int64
+}
+
+// Clock reports the current time. Collections and tables consult it to decide whether an item has
+// expired, so tests can substitute a clock they control.
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is the Clock reporting the actual time.
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// ExpiryFromTTL returns the expiry for an item written now that lives for ttl.
+func ExpiryFromTTL(clock Clock, ttl time.Duration) (int64, error) {
+	if ttl <= 0 {
+		return 0, ValueError
+	}
+
+	return clock.Now().Add(ttl).UnixNano(), nil
+}
+
+// IsExpired returns true if an item with the given expiry is no longer visible.
+func IsExpired(clock Clock, expiry int64) bool {
+	return expiry != 0 && expiry <= clock.Now().UnixNano()
+}
+
+type DB interface {
+	// Get gets the value for the given key. It returns an error if the DB does not contain the key.
+	Get(key []byte) (value []byte, err error)
+
+	// Has returns true if the DB contains the given key.
+	Has(key []byte) (ret bool, err error)
+
+	// Put sets the value for the given key. It overwrites any previous value for that key; a DB is
+	// not a multi-map.
+	Put(key, value []byte) error
+
+	// PutWithTTL sets the value for the given key like Put, but the key-value pair is hidden from
+	// Get, Has and RangeScan once ttl has elapsed and is dropped when the DB is flushed.
+	PutWithTTL(key, value []byte, ttl time.Duration) error
+
+	// Delete deletes the value for the given key.
+	Delete(key []byte) error
+
+	// RangeScan returns an Iterator for scanning through all key-value pairs in the
+	// given range, ordered by key ascending.
+	RangeScan(start, limit []byte) (iterator.Iterator, error)
+
+	// Flush the contents of the in-memory key/value database to `w` in the form of an SSTable.
+	Flush(w io.Writer) error
+}
+
+type ImmutableDB interface {
+	// Get gets the value for the given key. It returns an error if the DB does not contain the key.
+	Get(key []byte) (value []byte, err error)
+
+	// Has returns true if the DB contains the given key.
+	Has(key []byte) (ret bool, err error)
+
+	// RangeScan returns an Iterator for scanning through all key-value pairs in the
+	// given range, ordered by key ascending.
+	RangeScan(start, limit []byte) (iterator.Iterator, error)
+}
+
+type ReaderSeeker interface {
+	io.Reader
+	io.ReaderAt
+	io.Seeker
+}
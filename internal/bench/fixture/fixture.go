@@ -0,0 +1,46 @@
+// Package fixture generates the keys and values the Go benchmarks of the database, the memtables
+// and the table format run over. It is kept apart from package bench, which imports the database
+// and the memtables, so that their in-package benchmarks can import it without a cycle.
+package fixture
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+)
+
+var (
+	// KeyCounts are the numbers of keys the benchmarks are run with.
+	KeyCounts = []int{1000, 10000}
+	// ValueSizes are the sizes of the values the benchmarks are run with.
+	ValueSizes = []int{16, 256}
+	// ScanWidths are the numbers of keys the range scan benchmarks read.
+	ScanWidths = []int{10, 100, 1000}
+)
+
+// Data holds n keys in ascending order, keys between them that are never put, a random order to
+// visit the keys in, and a value.
+type Data struct {
+	Keys   [][]byte
+	Misses [][]byte
+	Order  []int
+	Value  []byte
+}
+
+// New returns n keys with values of valueSize bytes. The order is seeded by n, so that benchmarks
+// with the same key count visit the keys in the same order.
+func New(n, valueSize int) Data {
+	d := Data{
+		Keys:   make([][]byte, n),
+		Misses: make([][]byte, n),
+		Order:  rand.New(rand.NewSource(int64(n))).Perm(n),
+		Value:  bytes.Repeat([]byte{'v'}, valueSize),
+	}
+
+	for i := range d.Keys {
+		d.Keys[i] = []byte(fmt.Sprintf("key%08d", i))
+		d.Misses[i] = []byte(fmt.Sprintf("key%08d.", i))
+	}
+
+	return d
+}
@@ -0,0 +1,143 @@
+// Package bench holds what the benchmark commands share: a latency histogram and generators for
+// synthetic keys and values.
+package bench
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"time"
+)
+
+const (
+	// subBucketBits is the number of bits below the leading one kept when bucketing a latency, so
+	// that each power of two is split into 16 buckets and a percentile is off by at most 1/16th.
+	subBucketBits  = 4
+	subBucketCount = 1 << subBucketBits
+	bucketCount    = (64 - subBucketBits) * subBucketCount
+)
+
+// Histogram records latencies in log-linear buckets, using a fixed amount of memory however many
+// are recorded. The zero value is empty and ready to use. A Histogram is not safe for concurrent
+// use; concurrent workers should record into their own and Merge them.
+type Histogram struct {
+	buckets  [bucketCount]uint64
+	count    uint64
+	sum      float64
+	min, max time.Duration
+}
+
+func bucketIndex(v uint64) int {
+	if v < 2*subBucketCount {
+		return int(v)
+	}
+
+	shift := bits.Len64(v) - subBucketBits - 1
+	return (shift+1)*subBucketCount + int(v>>shift) - subBucketCount
+}
+
+// bucketLimit returns the largest value falling into bucket i.
+func bucketLimit(i int) uint64 {
+	if i < 2*subBucketCount {
+		return uint64(i)
+	}
+
+	shift := i/subBucketCount - 1
+	mantissa := uint64(i%subBucketCount + subBucketCount)
+	return (mantissa+1)<<shift - 1
+}
+
+// Record adds a latency to the histogram. Negative latencies count as zero.
+func (h *Histogram) Record(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+
+	h.buckets[bucketIndex(uint64(d))]++
+
+	if h.count == 0 || d < h.min {
+		h.min = d
+	}
+
+	if d > h.max {
+		h.max = d
+	}
+
+	h.count++
+	h.sum += float64(d)
+}
+
+// Merge adds every latency recorded in other to the histogram.
+func (h *Histogram) Merge(other *Histogram) {
+	if other.count == 0 {
+		return
+	}
+
+	for i, n := range other.buckets {
+		h.buckets[i] += n
+	}
+
+	if h.count == 0 || other.min < h.min {
+		h.min = other.min
+	}
+
+	if other.max > h.max {
+		h.max = other.max
+	}
+
+	h.count += other.count
+	h.sum += other.sum
+}
+
+// Count returns the number of latencies recorded.
+func (h *Histogram) Count() uint64 {
+	return h.count
+}
+
+// Mean returns the average latency, or zero if none were recorded.
+func (h *Histogram) Mean() time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+
+	return time.Duration(h.sum / float64(h.count))
+}
+
+// Min returns the smallest latency recorded.
+func (h *Histogram) Min() time.Duration {
+	return h.min
+}
+
+// Max returns the largest latency recorded.
+func (h *Histogram) Max() time.Duration {
+	return h.max
+}
+
+// Percentile returns the latency below which p percent of the recorded latencies fall, rounded up
+// to the limit of its bucket but never above the largest latency recorded.
+func (h *Histogram) Percentile(p float64) time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+
+	rank := uint64(math.Ceil(p / 100 * float64(h.count)))
+	if rank == 0 {
+		rank = 1
+	}
+
+	var seen uint64
+	for i, n := range h.buckets {
+		seen += n
+
+		if seen >= rank {
+			return min(time.Duration(bucketLimit(i)), h.max)
+		}
+	}
+
+	return h.max
+}
+
+// String summarises the histogram on a single line.
+func (h *Histogram) String() string {
+	return fmt.Sprintf("p50 %s p95 %s p99 %s p99.9 %s max %s", h.Percentile(50), h.Percentile(95), h.Percentile(99), h.Percentile(99.9), h.max)
+}
@@ -0,0 +1,59 @@
+package bench
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHistogram(t *testing.T) {
+	var h Histogram
+
+	for i := 1; i <= 1000; i++ {
+		h.Record(time.Duration(i) * time.Microsecond)
+	}
+
+	if h.Count() != 1000 || h.Min() != time.Microsecond || h.Max() != time.Millisecond {
+		t.Fatalf("unexpected count %d, min %s or max %s", h.Count(), h.Min(), h.Max())
+	}
+
+	if h.Mean() != 500500*time.Nanosecond {
+		t.Fatalf("unexpected mean %s", h.Mean())
+	}
+
+	for _, p := range []float64{50, 90, 99, 99.9} {
+		want := time.Duration(p*10) * time.Microsecond
+		got := h.Percentile(p)
+
+		if got < want || got > want+want/subBucketCount {
+			t.Fatalf("expected p%v within a bucket of %s, got %s", p, want, got)
+		}
+	}
+
+	if h.Percentile(100) != time.Millisecond {
+		t.Fatalf("expected p100 to be the maximum, got %s", h.Percentile(100))
+	}
+
+	var other Histogram
+	other.Record(time.Second)
+	h.Merge(&other)
+
+	if h.Count() != 1001 || h.Max() != time.Second || h.Min() != time.Microsecond {
+		t.Fatalf("unexpected count %d, min %s or max %s after merge", h.Count(), h.Min(), h.Max())
+	}
+}
+
+func TestKey(t *testing.T) {
+	for _, tt := range []struct {
+		n    uint64
+		size int
+		want string
+	}{
+		{7, 4, "0007"},
+		{1234, 4, "1234"},
+		{12345, 4, "12345"},
+	} {
+		if got := string(Key(tt.n, tt.size)); got != tt.want {
+			t.Fatalf("expected key %q for %d, got %q", tt.want, tt.n, got)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package bench
+
+import (
+	"math/rand"
+	"strconv"
+)
+
+// valueBufferSize is the amount of random data values are sliced from.
+const valueBufferSize = 1 << 20
+
+// Key returns the key numbered n, zero-padded to size bytes so that keys sort in the order of their
+// numbers. Keys are longer than size when n has more digits, and then no longer sort in order, so
+// callers should check size against MinKeySize.
+func Key(n uint64, size int) []byte {
+	digits := strconv.AppendUint(nil, n, 10)
+	if len(digits) >= size {
+		return digits
+	}
+
+	key := make([]byte, size-len(digits), size)
+	for i := range key {
+		key[i] = '0'
+	}
+
+	return append(key, digits...)
+}
+
+// MinKeySize returns the smallest size for which Key returns keys of that size, sorted in order, for
+// every number below n.
+func MinKeySize(n uint64) int {
+	if n <= 1 {
+		return 1
+	}
+
+	return len(strconv.FormatUint(n-1, 10))
+}
+
+// Values generates pseudo-random values of a fixed size, sliced from a buffer of random data so
+// that generating them costs next to nothing.
+type Values struct {
+	data []byte
+	pos  int
+	size int
+}
+
+// NewValues returns a generator of values of the given size, seeded so that runs are repeatable.
+func NewValues(seed int64, size int) *Values {
+	n := max(valueBufferSize, size)
+	data := make([]byte, n)
+	rand.New(rand.NewSource(seed)).Read(data)
+
+	return &Values{data: data, size: size}
+}
+
+// Next returns the next value. Values share the generator's buffer, and must not be modified.
+func (v *Values) Next() []byte {
+	if v.pos+v.size > len(v.data) {
+		v.pos = 0
+	}
+
+	value := v.data[v.pos : v.pos+v.size]
+	v.pos += v.size
+	return value
+}
@@ -0,0 +1,187 @@
+package bench
+
+import (
+	"os"
+	"sync"
+
+	"github.com/savarin/levels"
+	"github.com/savarin/levels/memtable"
+)
+
+// Store is what the benchmarks need of a DB implementation. Stores are safe for concurrent use.
+type Store interface {
+	Get(key []byte) ([]byte, error)
+	Put(key, value []byte) error
+	Delete(key []byte) error
+	RangeScan(start, limit []byte) (levels.Iterator, error)
+	Close() error
+}
+
+// memtableStore serialises access to a memtable collection, none of which are safe for concurrent
+// use by themselves.
+type memtableStore struct {
+	mu sync.Mutex
+	db levels.DB
+}
+
+// Memtable returns a Store backed by an in-memory collection.
+func Memtable(db levels.DB) Store {
+	return &memtableStore{db: db}
+}
+
+func (s *memtableStore) Get(key []byte) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.db.Get(key)
+}
+
+func (s *memtableStore) Put(key, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.db.Put(key, value)
+}
+
+func (s *memtableStore) Delete(key []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.db.Delete(key)
+}
+
+func (s *memtableStore) RangeScan(start, limit []byte) (levels.Iterator, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	iter, err := s.db.RangeScan(start, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return &lockedIterator{mu: &s.mu, iter: iter}, nil
+}
+
+// lockedIterator holds the lock of a memtableStore while moving through the collection, as the
+// iterators of the linked and skip lists walk the live nodes.
+type lockedIterator struct {
+	mu   *sync.Mutex
+	iter levels.Iterator
+}
+
+func (i *lockedIterator) Next() bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	return i.iter.Next()
+}
+
+func (i *lockedIterator) Error() error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	return i.iter.Error()
+}
+
+func (i *lockedIterator) Key() []byte {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	return i.iter.Key()
+}
+
+func (i *lockedIterator) Value() []byte {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	return i.iter.Value()
+}
+
+func (s *memtableStore) Close() error {
+	return nil
+}
+
+// databaseStore runs benchmarks against the default column family of a Database in a directory of
+// its own, which is removed when the store is closed.
+type databaseStore struct {
+	db  *levels.Database
+	cf  *levels.ColumnFamily
+	dir string
+}
+
+// OpenDatabase returns a Store backed by a new Database in a temporary directory created within
+// dir, or within the default temporary directory if dir is empty.
+func OpenDatabase(dir string, sync bool) (Store, error) {
+	dir, err := os.MkdirTemp(dir, "levels-bench-")
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := levels.OpenDatabase(dir, levels.DatabaseOptions{SyncWrites: sync})
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	cf, err := db.ColumnFamily(levels.DefaultColumnFamily)
+	if err != nil {
+		db.Close()
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	return &databaseStore{db: db, cf: cf, dir: dir}, nil
+}
+
+func (s *databaseStore) Get(key []byte) ([]byte, error) {
+	return s.db.Get(s.cf, key)
+}
+
+func (s *databaseStore) Put(key, value []byte) error {
+	return s.db.Put(s.cf, key, value)
+}
+
+func (s *databaseStore) Delete(key []byte) error {
+	return s.db.Delete(s.cf, key)
+}
+
+func (s *databaseStore) RangeScan(start, limit []byte) (levels.Iterator, error) {
+	return s.db.RangeScan(s.cf, start, limit)
+}
+
+func (s *databaseStore) Close() error {
+	err := s.db.Close()
+
+	removeErr := os.RemoveAll(s.dir)
+	if err == nil {
+		err = removeErr
+	}
+
+	return err
+}
+
+// Implementation opens an empty Store of one of the DB implementations. Database stores are
+// created within dir, and sync their writes if sync is set.
+type Implementation struct {
+	Name string
+	Open func(dir string, sync bool) (Store, error)
+}
+
+// Implementations lists every DB implementation that can be benchmarked.
+var Implementations = []Implementation{
+	{"simple", func(string, bool) (Store, error) { return Memtable(memtable.NewSimpleDB()), nil }},
+	{"linkedlist", func(string, bool) (Store, error) { return Memtable(memtable.NewLinkedListDB()), nil }},
+	{"skiplist", func(string, bool) (Store, error) { return Memtable(memtable.NewSkipListDB()), nil }},
+	{"database", OpenDatabase},
+}
+
+// FindImplementation returns the implementation with the given name.
+func FindImplementation(name string) (Implementation, bool) {
+	for _, impl := range Implementations {
+		if impl.Name == name {
+			return impl, true
+		}
+	}
+
+	return Implementation{}, false
+}
@@ -0,0 +1,170 @@
+// Package ycsb implements the core workloads of the Yahoo! Cloud Serving Benchmark, along with its
+// key distributions and a driver running a workload against a bench.Store from many goroutines.
+package ycsb
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+)
+
+// Distribution chooses which of n records an operation targets. Distributions are safe for
+// concurrent use, as long as each goroutine passes its own rng.
+type Distribution interface {
+	// Next returns a record number in [0, n). n may grow between calls as records are inserted.
+	Next(rng *rand.Rand, n uint64) uint64
+}
+
+// Uniform chooses every record with the same probability.
+type Uniform struct{}
+
+func (Uniform) Next(rng *rand.Rand, n uint64) uint64 {
+	if n == 0 {
+		return 0
+	}
+
+	return uint64(rng.Int63n(int64(n)))
+}
+
+// zipfianConstant is the skew YCSB uses, under which a few records are far more popular than the
+// rest.
+const zipfianConstant = 0.99
+
+// zipfianState holds the constants of the zipfian distribution over n records.
+type zipfianState struct {
+	n     uint64
+	zetan float64
+	eta   float64
+}
+
+// Zipfian chooses low record numbers far more often than high ones, following the algorithm of
+// Gray et al., "Quickly Generating Billion-Record Synthetic Databases". Its constants are extended
+// incrementally as n grows, rather than recomputed.
+type Zipfian struct {
+	theta, alpha, zeta2 float64
+
+	mu    sync.Mutex
+	state atomic.Pointer[zipfianState]
+}
+
+// NewZipfian returns a zipfian distribution with YCSB's skew.
+func NewZipfian() *Zipfian {
+	z := &Zipfian{theta: zipfianConstant}
+	z.alpha = 1 / (1 - z.theta)
+	z.zeta2 = 1 + math.Pow(0.5, z.theta)
+	z.state.Store(&zipfianState{})
+	return z
+}
+
+// constants returns the state of the distribution over at least n records, extending it if n has
+// grown. A goroutine passing an n smaller than another's gets the state for the larger n.
+func (z *Zipfian) constants(n uint64) *zipfianState {
+	s := z.state.Load()
+	if s.n >= n {
+		return s
+	}
+
+	z.mu.Lock()
+	defer z.mu.Unlock()
+
+	s = z.state.Load()
+	if s.n >= n {
+		return s
+	}
+
+	s = z.compute(n, s.n, s.zetan)
+	z.state.Store(s)
+	return s
+}
+
+// compute returns the state over n records, given the zeta over the first from records.
+func (z *Zipfian) compute(n, from uint64, zeta float64) *zipfianState {
+	for i := from; i < n; i++ {
+		zeta += 1 / math.Pow(float64(i+1), z.theta)
+	}
+
+	eta := (1 - math.Pow(2/float64(n), 1-z.theta)) / (1 - z.zeta2/zeta)
+	return &zipfianState{n: n, zetan: zeta, eta: eta}
+}
+
+func (z *Zipfian) Next(rng *rand.Rand, n uint64) uint64 {
+	if n <= 1 {
+		return 0
+	}
+
+	s := z.constants(n)
+
+	// The state may cover more records than n, when another goroutine has seen inserts this one has
+	// not. Choices among the first n of those records follow the distribution over n records, so
+	// any beyond them are drawn again.
+	for {
+		v := z.choose(rng, s)
+		if v < n {
+			return v
+		}
+	}
+}
+
+// choose returns a record number drawn from the distribution over the s.n records of s.
+func (z *Zipfian) choose(rng *rand.Rand, s *zipfianState) uint64 {
+	u := rng.Float64()
+	uz := u * s.zetan
+
+	if uz < 1 {
+		return 0
+	}
+
+	if uz < 1+math.Pow(0.5, z.theta) {
+		return 1
+	}
+
+	return min(uint64(float64(s.n)*math.Pow(s.eta*u-s.eta+1, z.alpha)), s.n-1)
+}
+
+// ScrambledZipfian is a zipfian distribution with the popular records spread across the keyspace
+// by hashing, rather than clustered at the lowest record numbers.
+type ScrambledZipfian struct {
+	zipfian *Zipfian
+}
+
+func NewScrambledZipfian() *ScrambledZipfian {
+	return &ScrambledZipfian{zipfian: NewZipfian()}
+}
+
+func (s *ScrambledZipfian) Next(rng *rand.Rand, n uint64) uint64 {
+	if n == 0 {
+		return 0
+	}
+
+	return hash(s.zipfian.Next(rng, n)) % n
+}
+
+// Latest is a zipfian distribution favouring the most recently inserted records.
+type Latest struct {
+	zipfian *Zipfian
+}
+
+func NewLatest() *Latest {
+	return &Latest{zipfian: NewZipfian()}
+}
+
+func (l *Latest) Next(rng *rand.Rand, n uint64) uint64 {
+	if n == 0 {
+		return 0
+	}
+
+	return n - 1 - l.zipfian.Next(rng, n)
+}
+
+// hash returns the 64-bit FNV-1a hash of n.
+func hash(n uint64) uint64 {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], n)
+
+	h := fnv.New64a()
+	h.Write(buf[:])
+	return h.Sum64()
+}
@@ -0,0 +1,320 @@
+package ycsb
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/savarin/levels"
+	"github.com/savarin/levels/internal/bench"
+)
+
+// Options configures a load or run.
+type Options struct {
+	// RecordCount is the number of records loaded before a run. It defaults to 1000.
+	RecordCount int
+
+	// OperationCount is the number of operations a run makes. It defaults to 1000.
+	OperationCount int
+
+	// Threads is the number of goroutines making operations. It defaults to 1.
+	Threads int
+
+	// ValueSize is the size of each value written. It defaults to 100.
+	ValueSize int
+
+	// Seed seeds the random choices of every goroutine, so that runs are repeatable.
+	Seed int64
+}
+
+func (o Options) withDefaults() Options {
+	if o.RecordCount <= 0 {
+		o.RecordCount = 1000
+	}
+
+	if o.OperationCount <= 0 {
+		o.OperationCount = 1000
+	}
+
+	if o.Threads <= 0 {
+		o.Threads = 1
+	}
+
+	if o.ValueSize <= 0 {
+		o.ValueSize = 100
+	}
+
+	return o
+}
+
+// Result describes the operations made by a load or run.
+type Result struct {
+	Elapsed time.Duration
+
+	// Latencies holds a histogram for each kind of operation made.
+	Latencies map[Operation]*bench.Histogram
+
+	// NotFound counts the reads of records that could not be found, which are not otherwise errors.
+	NotFound int
+}
+
+// Operations returns the total number of operations made.
+func (r *Result) Operations() uint64 {
+	var n uint64
+	for _, h := range r.Latencies {
+		n += h.Count()
+	}
+
+	return n
+}
+
+// Throughput returns the number of operations made per second.
+func (r *Result) Throughput() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+
+	return float64(r.Operations()) / r.Elapsed.Seconds()
+}
+
+// Key returns the key of the n-th record. Record numbers are hashed, as YCSB does, so that records
+// inserted in order are spread across the keyspace.
+func Key(n uint64) []byte {
+	return strconv.AppendUint([]byte("user"), hash(n), 10)
+}
+
+// acknowledged tracks the inserted records that operations may target: every record numbered
+// below limit has been written, even though inserts from different goroutines complete out of
+// order.
+type acknowledged struct {
+	mu    sync.Mutex
+	limit atomic.Uint64
+	done  map[uint64]bool
+}
+
+func (a *acknowledged) ack(n uint64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.done[n] = true
+
+	limit := a.limit.Load()
+	for a.done[limit] {
+		delete(a.done, limit)
+		limit++
+	}
+
+	a.limit.Store(limit)
+}
+
+// driver holds the state shared by the goroutines of a run.
+type driver struct {
+	store        bench.Store
+	workload     Workload
+	distribution Distribution
+	opts         Options
+
+	next         atomic.Uint64
+	acknowledged acknowledged
+}
+
+// worker makes the operations of a single goroutine.
+type worker struct {
+	*driver
+
+	rng       *rand.Rand
+	values    *bench.Values
+	latencies map[Operation]*bench.Histogram
+	notFound  int
+}
+
+func (w *worker) choose() []byte {
+	return Key(w.distribution.Next(w.rng, w.acknowledged.limit.Load()))
+}
+
+func (w *worker) read(key []byte) error {
+	_, err := w.store.Get(key)
+	if errors.Is(err, levels.KeyError) {
+		w.notFound++
+		return nil
+	}
+
+	return err
+}
+
+func (w *worker) insert() error {
+	n := w.next.Add(1) - 1
+
+	err := w.store.Put(Key(n), w.values.Next())
+	if err != nil {
+		return err
+	}
+
+	w.acknowledged.ack(n)
+	return nil
+}
+
+func (w *worker) scan() error {
+	length := 1 + w.rng.Intn(max(w.workload.MaxScanLength, 1))
+
+	iter, err := w.store.RangeScan(w.choose(), nil)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < length && iter.Key() != nil; i++ {
+		iter.Value()
+
+		if !iter.Next() {
+			break
+		}
+	}
+
+	return iter.Error()
+}
+
+func (w *worker) do(op Operation) error {
+	switch op {
+	case Read:
+		return w.read(w.choose())
+	case Update:
+		return w.store.Put(w.choose(), w.values.Next())
+	case Insert:
+		return w.insert()
+	case Scan:
+		return w.scan()
+	case ReadModifyWrite:
+		key := w.choose()
+
+		err := w.read(key)
+		if err != nil {
+			return err
+		}
+
+		return w.store.Put(key, w.values.Next())
+	}
+
+	return fmt.Errorf("unknown operation %q", op)
+}
+
+// record runs and times op.
+func (w *worker) record(op Operation) error {
+	start := time.Now()
+
+	err := w.do(op)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	h, ok := w.latencies[op]
+	if !ok {
+		h = &bench.Histogram{}
+		w.latencies[op] = h
+	}
+
+	h.Record(time.Since(start))
+	return nil
+}
+
+// execute runs count operations from each of opts.Threads goroutines, with ops choosing the
+// operation each makes, and merges their results.
+func (d *driver) execute(count func(thread int) int, op func(w *worker) Operation) (*Result, error) {
+	workers := make([]*worker, d.opts.Threads)
+	errs := make([]error, d.opts.Threads)
+
+	var wg sync.WaitGroup
+	start := time.Now()
+
+	for i := range workers {
+		seed := d.opts.Seed + int64(i)
+		w := &worker{
+			driver:    d,
+			rng:       rand.New(rand.NewSource(seed)),
+			values:    bench.NewValues(seed, d.opts.ValueSize),
+			latencies: make(map[Operation]*bench.Histogram),
+		}
+		workers[i] = w
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			for j := 0; j < count(i); j++ {
+				err := w.record(op(w))
+				if err != nil {
+					errs[i] = err
+					return
+				}
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	result := &Result{Elapsed: time.Since(start), Latencies: make(map[Operation]*bench.Histogram)}
+
+	for _, w := range workers {
+		for op, h := range w.latencies {
+			merged, ok := result.Latencies[op]
+			if !ok {
+				merged = &bench.Histogram{}
+				result.Latencies[op] = merged
+			}
+
+			merged.Merge(h)
+		}
+
+		result.NotFound += w.notFound
+	}
+
+	return result, errors.Join(errs...)
+}
+
+// share returns the number of n operations made by the given one of threads goroutines.
+func share(n, threads, thread int) int {
+	count := n / threads
+	if thread < n%threads {
+		count++
+	}
+
+	return count
+}
+
+// Load inserts opts.RecordCount records into an empty store, as the load phase of YCSB does before
+// a workload is run.
+func Load(store bench.Store, opts Options) (*Result, error) {
+	opts = opts.withDefaults()
+
+	d := &driver{store: store, opts: opts}
+	d.acknowledged.done = make(map[uint64]bool)
+
+	return d.execute(
+		func(thread int) int { return share(opts.RecordCount, opts.Threads, thread) },
+		func(*worker) Operation { return Insert },
+	)
+}
+
+// Run makes opts.OperationCount operations of the workload against a store holding the
+// opts.RecordCount records written by Load.
+func Run(store bench.Store, workload Workload, opts Options) (*Result, error) {
+	opts = opts.withDefaults()
+
+	distribution, err := workload.distribution()
+	if err != nil {
+		return nil, err
+	}
+
+	d := &driver{store: store, workload: workload, distribution: distribution, opts: opts}
+	d.acknowledged.done = make(map[uint64]bool)
+	d.acknowledged.limit.Store(uint64(opts.RecordCount))
+	d.next.Store(uint64(opts.RecordCount))
+
+	return d.execute(
+		func(thread int) int { return share(opts.OperationCount, opts.Threads, thread) },
+		func(w *worker) Operation { return workload.choose(w.rng.Float64()) },
+	)
+}
@@ -0,0 +1,127 @@
+package ycsb
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Operation is one of the kinds of request a workload makes.
+type Operation string
+
+const (
+	Read            Operation = "READ"
+	Update          Operation = "UPDATE"
+	Insert          Operation = "INSERT"
+	Scan            Operation = "SCAN"
+	ReadModifyWrite Operation = "READ-MODIFY-WRITE"
+)
+
+// Operations lists every operation, in the order results are reported.
+var Operations = []Operation{Read, Update, Insert, Scan, ReadModifyWrite}
+
+// Names of the request distributions.
+const (
+	UniformDistribution = "uniform"
+	ZipfianDistribution = "zipfian"
+	LatestDistribution  = "latest"
+)
+
+// Workload describes the mix of operations a run makes and which records they target.
+type Workload struct {
+	Name string
+
+	// The proportions of each operation, which should add up to 1.
+	ReadProportion            float64
+	UpdateProportion          float64
+	InsertProportion          float64
+	ScanProportion            float64
+	ReadModifyWriteProportion float64
+
+	// RequestDistribution is the distribution of the records read, updated and scanned from:
+	// uniform, zipfian or latest.
+	RequestDistribution string
+
+	// MaxScanLength is the largest number of records a scan reads, each reading a uniformly chosen
+	// number up to it.
+	MaxScanLength int
+}
+
+// The core workloads, as defined by YCSB.
+var (
+	// WorkloadA is an update heavy workload, like a session store recording recent actions.
+	WorkloadA = Workload{Name: "a", ReadProportion: 0.5, UpdateProportion: 0.5, RequestDistribution: ZipfianDistribution}
+
+	// WorkloadB is a read mostly workload, like photo tagging.
+	WorkloadB = Workload{Name: "b", ReadProportion: 0.95, UpdateProportion: 0.05, RequestDistribution: ZipfianDistribution}
+
+	// WorkloadC is a read only workload, like a user profile cache.
+	WorkloadC = Workload{Name: "c", ReadProportion: 1, RequestDistribution: ZipfianDistribution}
+
+	// WorkloadD reads the latest records, like user status updates.
+	WorkloadD = Workload{Name: "d", ReadProportion: 0.95, InsertProportion: 0.05, RequestDistribution: LatestDistribution}
+
+	// WorkloadE scans short ranges, like threaded conversations.
+	WorkloadE = Workload{Name: "e", ScanProportion: 0.95, InsertProportion: 0.05, RequestDistribution: ZipfianDistribution, MaxScanLength: 100}
+
+	// WorkloadF reads records and writes them back modified, like a user database.
+	WorkloadF = Workload{Name: "f", ReadProportion: 0.5, ReadModifyWriteProportion: 0.5, RequestDistribution: ZipfianDistribution}
+)
+
+// Workloads lists the core workloads.
+var Workloads = []Workload{WorkloadA, WorkloadB, WorkloadC, WorkloadD, WorkloadE, WorkloadF}
+
+// FindWorkload returns the core workload with the given name, ignoring case.
+func FindWorkload(name string) (Workload, bool) {
+	for _, w := range Workloads {
+		if strings.EqualFold(w.Name, name) {
+			return w, true
+		}
+	}
+
+	return Workload{}, false
+}
+
+func (w Workload) proportions() []float64 {
+	return []float64{w.ReadProportion, w.UpdateProportion, w.InsertProportion, w.ScanProportion, w.ReadModifyWriteProportion}
+}
+
+// distribution returns a new distribution of the records requests target.
+func (w Workload) distribution() (Distribution, error) {
+	switch w.RequestDistribution {
+	case UniformDistribution:
+		return Uniform{}, nil
+	case ZipfianDistribution:
+		return NewScrambledZipfian(), nil
+	case LatestDistribution:
+		return NewLatest(), nil
+	}
+
+	return nil, fmt.Errorf("unknown request distribution %q", w.RequestDistribution)
+}
+
+// choose returns the operation whose share of the proportions u in [0, 1) falls in.
+func (w Workload) choose(u float64) Operation {
+	proportions := w.proportions()
+
+	total := 0.0
+	for _, p := range proportions {
+		total += p
+	}
+
+	u *= total
+	for i, p := range proportions {
+		if u < p {
+			return Operations[i]
+		}
+
+		u -= p
+	}
+
+	for i := len(proportions) - 1; i >= 0; i-- {
+		if proportions[i] > 0 {
+			return Operations[i]
+		}
+	}
+
+	return Read
+}
@@ -0,0 +1,120 @@
+package ycsb
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/savarin/levels/internal/bench"
+	"github.com/savarin/levels/memtable"
+)
+
+func TestDistributions(t *testing.T) {
+	const n = 1000
+
+	tests := []struct {
+		name         string
+		distribution Distribution
+		popular      uint64
+	}{
+		{"zipfian", NewZipfian(), 0},
+		{"latest", NewLatest(), n - 1},
+	}
+
+	for _, tt := range tests {
+		rng := rand.New(rand.NewSource(1))
+		counts := make(map[uint64]int)
+
+		for i := 0; i < 10000; i++ {
+			v := tt.distribution.Next(rng, n)
+			if v >= n {
+				t.Fatalf("%s: expected record below %d, got %d", tt.name, n, v)
+			}
+
+			counts[v]++
+		}
+
+		for v, c := range counts {
+			if c > counts[tt.popular] {
+				t.Fatalf("%s: expected %d to be the most popular record, but %d was chosen %d times to its %d", tt.name, tt.popular, v, c, counts[tt.popular])
+			}
+		}
+
+		// With YCSB's skew, the most popular record takes over a tenth of the choices.
+		if counts[tt.popular] < 1000 {
+			t.Fatalf("%s: expected record %d to be chosen often, got %d times", tt.name, tt.popular, counts[tt.popular])
+		}
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	for _, d := range []Distribution{Uniform{}, NewScrambledZipfian()} {
+		for i := 0; i < 1000; i++ {
+			if v := d.Next(rng, n); v >= n {
+				t.Fatalf("expected record below %d, got %d", n, v)
+			}
+		}
+	}
+}
+
+func TestZipfianShrinking(t *testing.T) {
+	const n = 10
+
+	z := NewZipfian()
+	rng := rand.New(rand.NewSource(1))
+
+	// Another goroutine has seen far more records, so the constants cover them.
+	z.Next(rng, 1000000)
+
+	counts := make([]int, n)
+
+	for i := 0; i < 10000; i++ {
+		v := z.Next(rng, n)
+		if v >= n {
+			t.Fatalf("expected record below %d, got %d", n, v)
+		}
+
+		counts[v]++
+	}
+
+	// Over 10 records, the first takes about a third of the choices.
+	if counts[0] < 2500 || counts[0] > 4500 {
+		t.Fatalf("expected record 0 to be chosen about a third of the time, got %d times", counts[0])
+	}
+}
+
+func TestRun(t *testing.T) {
+	opts := Options{RecordCount: 500, OperationCount: 1000, Threads: 4}
+
+	for _, workload := range Workloads {
+		store := bench.Memtable(memtable.NewSkipListDB())
+
+		result, err := Load(store, opts)
+		if err != nil {
+			t.Fatalf("unexpected error when loading: %s", err)
+		}
+
+		if result.Latencies[Insert].Count() != 500 {
+			t.Fatalf("expected 500 inserts when loading, got %d", result.Latencies[Insert].Count())
+		}
+
+		result, err = Run(store, workload, opts)
+		if err != nil {
+			t.Fatalf("unexpected error when running workload %s: %s", workload.Name, err)
+		}
+
+		if result.Operations() != 1000 || result.NotFound != 0 {
+			t.Fatalf("workload %s: expected 1000 operations finding every record, got %d with %d not found", workload.Name, result.Operations(), result.NotFound)
+		}
+
+		for i, op := range Operations {
+			p := workload.proportions()[i]
+			count := 0
+			if h, ok := result.Latencies[op]; ok {
+				count = int(h.Count())
+			}
+
+			if count < int(p*1000)-100 || count > int(p*1000)+100 {
+				t.Fatalf("workload %s: expected about %.0f %s operations, got %d", workload.Name, p*1000, op, count)
+			}
+		}
+	}
+}
@@ -0,0 +1,95 @@
+// Package iterator defines the Iterator returned by range scans, along with an Iterator over pairs
+// held in memory.
+package iterator
+
+type Iterator interface {
+	// Next moves the iterator to the next key/value pair.  It returns false if the iterator is
+	// exhausted.
+	Next() bool
+
+	// Error returns any accumulated error. Exhausting all the key/value pairs is not considered to
+	// be an error.
+	Error() error
+
+	// Key returns the key of the current key/value pair, or nil if done.
+	Key() []byte
+
+	// Value returns the value of the current key/value pair, or nil if done.
+	Value() []byte
+}
+
+// Expiring is implemented by iterators that know when the current key/value pair expires.
+type Expiring interface {
+	Expiry() int64
+}
+
+// Expiry returns the expiry of the current key/value pair of iter, or zero if iter does not know it.
+func Expiry(iter Iterator) int64 {
+	e, ok := iter.(Expiring)
+	if !ok {
+		return 0
+	}
+
+	return e.Expiry()
+}
+
+// New returns a SimpleIterator over the given keys and their values, positioned on the first pair.
+func New(keys, values [][]byte) *SimpleIterator {
+	return NewWithExpiries(keys, values, nil)
+}
+
+// NewWithExpiries returns a SimpleIterator like New, where expiries holds the expiry of each pair.
+func NewWithExpiries(keys, values [][]byte, expiries []int64) *SimpleIterator {
+	return &SimpleIterator{
+		keys:     keys,
+		values:   values,
+		expiries: expiries,
+		index:    0,
+	}
+}
+
+// SimpleIterator iterates over key/value pairs held in slices.
+type SimpleIterator struct {
+	keys     [][]byte
+	values   [][]byte
+	expiries []int64
+	index    int
+}
+
+func (iter *SimpleIterator) Next() bool {
+	if len(iter.keys) == 0 || iter.index == len(iter.keys)-1 {
+		return false
+	}
+
+	iter.index++
+	return true
+}
+
+func (iter *SimpleIterator) Error() error {
+	return nil
+}
+
+func (iter *SimpleIterator) Key() []byte {
+	if len(iter.keys) == 0 {
+		return nil
+	}
+
+	return iter.keys[iter.index]
+}
+
+func (iter *SimpleIterator) Value() []byte {
+	if len(iter.values) == 0 {
+		return nil
+	}
+
+	return iter.values[iter.index]
+}
+
+// Expiry returns the expiry of the current key/value pair, or zero if it never expires.
+func (iter *SimpleIterator) Expiry() int64 {
+	if len(iter.expiries) == 0 {
+		return 0
+	}
+
+	return iter.expiries[iter.index]
+}
@@ -0,0 +1,34 @@
+// Package levels is a basic LevelDB clone. It provides a persistent Database of column families
+// backed by a write-ahead log and SSTables, along with write batches and transactions. The
+// in-memory collections live in package memtable, and the SSTable format in package table.
+package levels
+
+import (
+	"github.com/savarin/levels/internal/base"
+	"github.com/savarin/levels/iterator"
+)
+
+const (
+	// MaxKeySize and MaxValueSize are the largest key and value, in bytes, that can be stored.
+	MaxKeySize   = base.MaxKeySize
+	MaxValueSize = base.MaxValueSize
+)
+
+var (
+	KeyError   = base.KeyError
+	ValueError = base.ValueError
+)
+
+type (
+	// DB is an in-memory key/value collection that can be flushed to an SSTable.
+	DB = base.DB
+
+	// ImmutableDB is a read-only key/value collection, such as an SSTable.
+	ImmutableDB = base.ImmutableDB
+
+	Iterator     = iterator.Iterator
+	Item         = base.Item
+	Clock        = base.Clock
+	ReaderSeeker = base.ReaderSeeker
+	SizeError    = base.SizeError
+)
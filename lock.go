@@ -0,0 +1,159 @@
+package levels
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	LockTimeoutError = errors.New("Lock wait timed out")
+	DeadlockError    = errors.New("Deadlock detected")
+)
+
+type LockMode int
+
+const (
+	SharedLock LockMode = iota
+	ExclusiveLock
+)
+
+type keyLock struct {
+	holders map[uint64]LockMode
+
+	// released is closed, and replaced, whenever a holder gives up the lock so that waiters can
+	// try again.
+	released chan struct{}
+}
+
+// LockManager grants shared and exclusive locks on keys to transactions identified by number. A
+// transaction waiting for a lock is recorded in a wait-for graph, and a request that would close a
+// cycle in the graph fails immediately with DeadlockError rather than waiting for its timeout.
+type LockManager struct {
+	mu       sync.Mutex
+	locks    map[string]*keyLock
+	waitsFor map[uint64]map[uint64]struct{}
+}
+
+func NewLockManager() *LockManager {
+	return &LockManager{
+		locks:    make(map[string]*keyLock),
+		waitsFor: make(map[uint64]map[uint64]struct{}),
+	}
+}
+
+// blockers returns the transactions whose hold on l prevents txn from acquiring it in mode.
+func (l *keyLock) blockers(txn uint64, mode LockMode) map[uint64]struct{} {
+	blockers := make(map[uint64]struct{})
+
+	for holder, held := range l.holders {
+		if holder == txn {
+			continue
+		}
+
+		if mode == ExclusiveLock || held == ExclusiveLock {
+			blockers[holder] = struct{}{}
+		}
+	}
+
+	return blockers
+}
+
+// hasCycle returns true if txn can reach itself in the wait-for graph.
+func (lm *LockManager) hasCycle(txn uint64) bool {
+	visited := make(map[uint64]bool)
+	stack := []uint64{txn}
+
+	for len(stack) > 0 {
+		current := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		for next := range lm.waitsFor[current] {
+			if next == txn {
+				return true
+			}
+
+			if !visited[next] {
+				visited[next] = true
+				stack = append(stack, next)
+			}
+		}
+	}
+
+	return false
+}
+
+// Lock acquires the lock on key for txn in the given mode, upgrading a shared lock already held by
+// txn if needed. It waits at most timeout for conflicting holders to release the lock.
+func (lm *LockManager) Lock(txn uint64, key []byte, mode LockMode, timeout time.Duration) error {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		lm.mu.Lock()
+
+		l, ok := lm.locks[string(key)]
+		if !ok {
+			l = &keyLock{
+				holders:  make(map[uint64]LockMode),
+				released: make(chan struct{}),
+			}
+			lm.locks[string(key)] = l
+		}
+
+		blockers := l.blockers(txn, mode)
+
+		if len(blockers) == 0 {
+			if held, ok := l.holders[txn]; !ok || held < mode {
+				l.holders[txn] = mode
+			}
+
+			delete(lm.waitsFor, txn)
+			lm.mu.Unlock()
+			return nil
+		}
+
+		lm.waitsFor[txn] = blockers
+
+		if lm.hasCycle(txn) {
+			delete(lm.waitsFor, txn)
+			lm.mu.Unlock()
+			return DeadlockError
+		}
+
+		released := l.released
+		lm.mu.Unlock()
+
+		select {
+		case <-released:
+		case <-timer.C:
+			lm.mu.Lock()
+			delete(lm.waitsFor, txn)
+			lm.mu.Unlock()
+			return LockTimeoutError
+		}
+	}
+}
+
+// Unlock releases the lock on key held by txn.
+func (lm *LockManager) Unlock(txn uint64, key []byte) {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+
+	l, ok := lm.locks[string(key)]
+	if !ok {
+		return
+	}
+
+	if _, ok := l.holders[txn]; !ok {
+		return
+	}
+
+	delete(l.holders, txn)
+	close(l.released)
+	l.released = make(chan struct{})
+
+	if len(l.holders) == 0 {
+		delete(lm.locks, string(key))
+	}
+}
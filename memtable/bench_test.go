@@ -0,0 +1,168 @@
+package memtable
+
+import (
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/savarin/levels/internal/base"
+	"github.com/savarin/levels/internal/bench/fixture"
+)
+
+var collections = []struct {
+	name  string
+	newDB func() base.DB
+}{
+	{"simple", func() base.DB { return NewSimpleDB() }},
+	{"linkedlist", func() base.DB { return NewLinkedListDB() }},
+	{"skiplist", func() base.DB { return NewSkipListDB() }},
+}
+
+func fill(b *testing.B, d fixture.Data, db base.DB) {
+	for _, i := range d.Order {
+		err := db.Put(d.Keys[i], d.Value)
+		if err != nil {
+			b.Fatalf("unexpected error when putting: %s", err)
+		}
+	}
+}
+
+// runCollections runs f for every collection, key count and value size.
+func runCollections(b *testing.B, f func(b *testing.B, newDB func() base.DB, d fixture.Data)) {
+	for _, c := range collections {
+		for _, n := range fixture.KeyCounts {
+			for _, size := range fixture.ValueSizes {
+				b.Run(fmt.Sprintf("%s/keys=%d/value=%d", c.name, n, size), func(b *testing.B) {
+					d := fixture.New(n, size)
+
+					b.ReportAllocs()
+					f(b, c.newDB, d)
+				})
+			}
+		}
+	}
+}
+
+// BenchmarkPut puts keys in random order into a collection growing to the key count.
+func BenchmarkPut(b *testing.B) {
+	runCollections(b, func(b *testing.B, newDB func() base.DB, d fixture.Data) {
+		var db base.DB
+
+		for i := 0; i < b.N; i++ {
+			j := i % len(d.Keys)
+			if j == 0 {
+				b.StopTimer()
+				db = newDB()
+				b.StartTimer()
+			}
+
+			err := db.Put(d.Keys[d.Order[j]], d.Value)
+			if err != nil {
+				b.Fatalf("unexpected error when putting: %s", err)
+			}
+		}
+	})
+}
+
+func BenchmarkGet(b *testing.B) {
+	b.Run("hit", func(b *testing.B) {
+		runCollections(b, func(b *testing.B, newDB func() base.DB, d fixture.Data) {
+			db := newDB()
+			fill(b, d, db)
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				_, err := db.Get(d.Keys[d.Order[i%len(d.Keys)]])
+				if err != nil {
+					b.Fatalf("unexpected error when getting: %s", err)
+				}
+			}
+		})
+	})
+
+	b.Run("miss", func(b *testing.B) {
+		runCollections(b, func(b *testing.B, newDB func() base.DB, d fixture.Data) {
+			db := newDB()
+			fill(b, d, db)
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				_, err := db.Get(d.Misses[d.Order[i%len(d.Keys)]])
+				if err == nil {
+					b.Fatalf("expected error when getting missing key")
+				}
+			}
+		})
+	})
+}
+
+// BenchmarkDelete deletes every key in random order, filling the collection again in between.
+func BenchmarkDelete(b *testing.B) {
+	runCollections(b, func(b *testing.B, newDB func() base.DB, d fixture.Data) {
+		var db base.DB
+
+		for i := 0; i < b.N; i++ {
+			j := i % len(d.Keys)
+			if j == 0 {
+				b.StopTimer()
+				db = newDB()
+				fill(b, d, db)
+				b.StartTimer()
+			}
+
+			err := db.Delete(d.Keys[d.Order[j]])
+			if err != nil {
+				b.Fatalf("unexpected error when deleting: %s", err)
+			}
+		}
+	})
+}
+
+// BenchmarkRangeScan scans ranges of each width from random starting keys, reading every pair.
+func BenchmarkRangeScan(b *testing.B) {
+	for _, width := range fixture.ScanWidths {
+		b.Run(fmt.Sprintf("width=%d", width), func(b *testing.B) {
+			runCollections(b, func(b *testing.B, newDB func() base.DB, d fixture.Data) {
+				if width >= len(d.Keys) {
+					b.Skip("range wider than the collection")
+				}
+
+				db := newDB()
+				fill(b, d, db)
+				b.ResetTimer()
+
+				for i := 0; i < b.N; i++ {
+					start := d.Order[i%len(d.Keys)] % (len(d.Keys) - width)
+
+					iter, err := db.RangeScan(d.Keys[start], d.Keys[start+width])
+					if err != nil {
+						b.Fatalf("unexpected error when scanning: %s", err)
+					}
+
+					for key := iter.Key(); key != nil; key = iter.Key() {
+						iter.Value()
+
+						if !iter.Next() {
+							break
+						}
+					}
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkFlush(b *testing.B) {
+	runCollections(b, func(b *testing.B, newDB func() base.DB, d fixture.Data) {
+		db := newDB()
+		fill(b, d, db)
+		b.ResetTimer()
+
+		for i := 0; i < b.N; i++ {
+			err := db.Flush(io.Discard)
+			if err != nil {
+				b.Fatalf("unexpected error when flushing: %s", err)
+			}
+		}
+	})
+}
@@ -0,0 +1,69 @@
+package memtable
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/savarin/levels/dbtest"
+	"github.com/savarin/levels/table"
+)
+
+// FuzzFlush puts pairs decoded from arbitrary bytes into each collection, flushes it to a table
+// with the given block size and checks that the opened table holds exactly the pairs put.
+func FuzzFlush(f *testing.F) {
+	f.Add([]byte("\x01a\x05alpha\x01b\x05bravo\x01c\x07charlie"), 16)
+	f.Add([]byte("\x00\x00\x01a\x00\x01a\x01b\x02\xff\xff\x03xyz"), 1)
+
+	f.Fuzz(func(t *testing.T, data []byte, blockSize int) {
+		if blockSize < 0 || blockSize > 1<<16 {
+			return
+		}
+
+		for _, c := range collections {
+			db := c.newDB()
+			model := dbtest.Model{}
+			keyspace := [][]byte{{}, []byte("\xff")}
+
+			// Pairs are prefixed by one-byte lengths, and later pairs overwrite earlier ones.
+			for buf := data; len(buf) > 0; {
+				n := int(buf[0])
+				if n+2 > len(buf) {
+					break
+				}
+
+				key := buf[1 : n+1]
+				m := int(buf[n+1])
+				buf = buf[n+2:]
+
+				if m > len(buf) {
+					break
+				}
+
+				value := buf[:m]
+				buf = buf[m:]
+
+				err := db.Put(key, value)
+				if err != nil {
+					t.Fatalf("unexpected error when putting key %q with value %q: %s", key, value, err)
+				}
+
+				model[string(key)] = value
+				keyspace = append(keyspace, key)
+			}
+
+			var w bytes.Buffer
+			err := table.FlushWithOptions(db, &w, table.BuilderOptions{BlockSize: blockSize})
+			if err != nil {
+				t.Fatalf("unexpected error when flushing %s: %s", c.name, err)
+			}
+
+			sst, err := table.Open(bytes.NewReader(w.Bytes()))
+			if err != nil {
+				t.Fatalf("unexpected error when opening table flushed from %s: %s", c.name, err)
+			}
+
+			dbtest.Check(t, db, model, keyspace)
+			dbtest.Check(t, sst, model, keyspace)
+		}
+	})
+}
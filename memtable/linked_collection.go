@@ -0,0 +1,189 @@
+package memtable
+
+import (
+	"io"
+	"time"
+
+	"github.com/savarin/levels/internal/base"
+	"github.com/savarin/levels/iterator"
+	"github.com/savarin/levels/table"
+)
+
+type linkedListNode struct {
+	item base.Item
+	next *linkedListNode
+	prev *linkedListNode
+}
+
+type LinkedListDB struct {
+	head  *linkedListNode
+	tail  *linkedListNode
+	clock base.Clock
+}
+
+func NewLinkedListDB() *LinkedListDB {
+	head := &linkedListNode{}
+	tail := &linkedListNode{}
+	head.next = tail
+	tail.prev = head
+	return &LinkedListDB{head: head, tail: tail, clock: base.SystemClock{}}
+}
+
+// SetClock replaces the clock used to decide whether items have expired.
+func (db *LinkedListDB) SetClock(clock base.Clock) {
+	db.clock = clock
+}
+
+func (db LinkedListDB) first(key []byte) *linkedListNode {
+	node := db.head.next
+
+	for node != db.tail && string(node.item.Key) < string(key) {
+		node = node.next
+	}
+
+	return node
+}
+
+// skipExpired returns the first node from node onwards that has not expired.
+func (db LinkedListDB) skipExpired(node *linkedListNode) *linkedListNode {
+	for node != db.tail && base.IsExpired(db.clock, node.item.Expiry) {
+		node = node.next
+	}
+
+	return node
+}
+
+func (db LinkedListDB) Get(key []byte) (value []byte, err error) {
+	node := db.first(key)
+
+	if node != db.tail && string(node.item.Key) == string(key) && !base.IsExpired(db.clock, node.item.Expiry) {
+		return node.item.Value, nil
+	}
+
+	return nil, base.KeyError
+}
+
+func (db LinkedListDB) Has(key []byte) (ret bool, err error) {
+	_, ok := db.Get(key)
+	return ok == nil, nil
+}
+
+func (db LinkedListDB) Put(key, value []byte) error {
+	return db.put(base.Item{Key: key, Value: value})
+}
+
+func (db LinkedListDB) PutWithTTL(key, value []byte, ttl time.Duration) error {
+	expiry, err := base.ExpiryFromTTL(db.clock, ttl)
+	if err != nil {
+		return err
+	}
+
+	return db.put(base.Item{Key: key, Value: value, Expiry: expiry})
+}
+
+func (db LinkedListDB) put(item base.Item) error {
+	err := base.CheckSize(item.Key, item.Value)
+	if err != nil {
+		return err
+	}
+
+	// A nil key is the empty key, but would read as the end of iteration.
+	if item.Key == nil {
+		item.Key = []byte{}
+	}
+
+	node := db.first(item.Key)
+
+	if node != db.tail && string(node.item.Key) == string(item.Key) {
+		node.item.Value = item.Value
+		node.item.Expiry = item.Expiry
+		return nil
+	}
+
+	currentNode := &linkedListNode{
+		item: item,
+		next: node,
+		prev: node.prev,
+	}
+
+	node.prev.next = currentNode
+	node.prev = currentNode
+	return nil
+}
+
+// Delete removes the key, returning KeyError if it is missing or has expired. An expired key is
+// removed all the same, rather than left in the list until it is flushed.
+func (db LinkedListDB) Delete(key []byte) error {
+	node := db.first(key)
+
+	if node == db.tail || string(node.item.Key) != string(key) {
+		return base.KeyError
+	}
+
+	node.prev.next = node.next
+	node.next.prev = node.prev
+
+	if base.IsExpired(db.clock, node.item.Expiry) {
+		return base.KeyError
+	}
+
+	return nil
+}
+
+func (db LinkedListDB) RangeScan(start, limit []byte) (iterator.Iterator, error) {
+	if len(limit) > 0 && string(start) > string(limit) {
+		return nil, base.ValueError
+	}
+
+	iter := &LinkedListIterator{db: &db, start: start, limit: limit}
+	iter.seek(db.first(start))
+	return iter, nil
+}
+
+func (db LinkedListDB) Flush(w io.Writer) error {
+	return table.Flush(db, w)
+}
+
+type LinkedListIterator struct {
+	db           *LinkedListDB
+	node         *linkedListNode
+	start, limit []byte
+}
+
+// seek positions the iterator at the first live node from node onwards, or at the tail if that
+// node is at or beyond the limit.
+func (iter *LinkedListIterator) seek(node *linkedListNode) {
+	node = iter.db.skipExpired(node)
+
+	if node != iter.db.tail && len(iter.limit) > 0 && string(node.item.Key) >= string(iter.limit) {
+		node = iter.db.tail
+	}
+
+	iter.node = node
+}
+
+func (iter *LinkedListIterator) Next() bool {
+	if iter.node == iter.db.tail {
+		return false
+	}
+
+	iter.seek(iter.node.next)
+	return true
+}
+
+func (iter *LinkedListIterator) Error() error {
+	return nil
+}
+
+func (iter *LinkedListIterator) Key() []byte {
+	return iter.node.item.Key
+}
+
+func (iter *LinkedListIterator) Value() []byte {
+	return iter.node.item.Value
+}
+
+// Expiry returns the expiry of the current key/value pair, or zero if it never expires.
+func (iter *LinkedListIterator) Expiry() int64 {
+	return iter.node.item.Expiry
+}
@@ -0,0 +1,205 @@
+package memtable
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/savarin/levels/dbtest"
+	"github.com/savarin/levels/internal/base"
+	"github.com/savarin/levels/table"
+)
+
+type entry struct {
+	Key   []byte
+	Value []byte
+}
+
+var (
+	A = entry{Key: []byte("a"), Value: []byte("alpha")}
+	B = entry{Key: []byte("b"), Value: []byte("bravo")}
+	C = entry{Key: []byte("c"), Value: []byte("charlie")}
+)
+
+func TestRun(t *testing.T) {
+	testRun(t, func() base.DB { return NewSimpleDB() })
+	testRun(t, func() base.DB { return NewLinkedListDB() })
+	testRun(t, func() base.DB { return NewSkipListDB() })
+}
+
+func TestConformance(t *testing.T) {
+	for _, c := range collections {
+		t.Run(c.name, func(t *testing.T) { dbtest.TestDB(t, c.newDB) })
+	}
+}
+
+func testRun(t *testing.T, factory func() base.DB) {
+	db := factory()
+
+	for _, e := range []entry{A, B, C} {
+		err := db.Put(e.Key, e.Value)
+		if err != nil {
+			t.Fatalf("unexpected error when putting key %q with value %q: %s", e.Key, e.Value, err)
+		}
+	}
+
+	for _, e := range []entry{A, B, C} {
+		v, err := db.Get(e.Key)
+		if err != nil {
+			t.Fatalf("unexpected error when getting key %q: %s", e.Key, err)
+		}
+
+		if string(v) != string(e.Value) {
+			t.Fatalf("expected %q got %q: %s", v, e.Value, err)
+		}
+	}
+
+	err := db.Delete(B.Key)
+	if err != nil {
+		t.Fatalf("unexpected error when deleting key %q: %s", B.Key, err)
+	}
+
+	for _, e := range []entry{A, C} {
+		v, err := db.Get(e.Key)
+		if err != nil {
+			t.Fatalf("unexpected error when getting key %q: %s", e.Key, err)
+		}
+
+		if string(v) != string(e.Value) {
+			t.Fatalf("expected %q got %q: %s", v, e.Value, err)
+		}
+	}
+
+	_, err = db.Get(B.Key)
+	if !errors.Is(err, base.KeyError) {
+		t.Errorf("e")
+	}
+}
+
+type manualClock struct {
+	now time.Time
+}
+
+func (c *manualClock) Now() time.Time {
+	return c.now
+}
+
+func TestTTL(t *testing.T) {
+	testTTL(t, func(clock base.Clock) base.DB { db := NewSimpleDB(); db.SetClock(clock); return db })
+	testTTL(t, func(clock base.Clock) base.DB { db := NewLinkedListDB(); db.SetClock(clock); return db })
+	testTTL(t, func(clock base.Clock) base.DB { db := NewSkipListDB(); db.SetClock(clock); return db })
+}
+
+func testTTL(t *testing.T, factory func(clock base.Clock) base.DB) {
+	clock := &manualClock{now: time.Unix(0, 0)}
+	db := factory(clock)
+
+	for _, e := range []entry{A, C} {
+		err := db.Put(e.Key, e.Value)
+		if err != nil {
+			t.Fatalf("unexpected error when putting key %q with value %q: %s", e.Key, e.Value, err)
+		}
+	}
+
+	err := db.PutWithTTL(B.Key, B.Value, time.Minute)
+	if err != nil {
+		t.Fatalf("unexpected error when putting key %q with ttl: %s", B.Key, err)
+	}
+
+	var buf bytes.Buffer
+	err = db.Flush(&buf)
+	if err != nil {
+		t.Fatalf("unexpected error when flushing: %s", err)
+	}
+
+	sst, err := table.OpenWithOptions(bytes.NewReader(buf.Bytes()), table.Options{Clock: clock})
+	if err != nil {
+		t.Fatalf("unexpected error when opening table: %s", err)
+	}
+
+	for _, source := range []base.ImmutableDB{db, sst} {
+		v, err := source.Get(B.Key)
+		if err != nil || string(v) != string(B.Value) {
+			t.Fatalf("expected %q before expiry got %q: %v", B.Value, v, err)
+		}
+	}
+
+	clock.now = clock.now.Add(time.Minute)
+
+	for _, source := range []base.ImmutableDB{db, sst} {
+		_, err := source.Get(B.Key)
+		if !errors.Is(err, base.KeyError) {
+			t.Fatalf("expected key %q to be expired, got %v", B.Key, err)
+		}
+
+		ok, _ := source.Has(B.Key)
+		if ok {
+			t.Fatalf("expected key %q to be hidden from Has", B.Key)
+		}
+
+		iter, err := source.RangeScan([]byte{}, []byte{})
+		if err != nil {
+			t.Fatalf("unexpected error when scanning: %s", err)
+		}
+
+		for _, e := range []entry{A, C} {
+			if string(iter.Key()) != string(e.Key) {
+				t.Fatalf("expected key %q in scan got %q", e.Key, iter.Key())
+			}
+
+			iter.Next()
+		}
+	}
+
+	buf.Reset()
+	err = db.Flush(&buf)
+	if err != nil {
+		t.Fatalf("unexpected error when flushing: %s", err)
+	}
+
+	if bytes.Contains(buf.Bytes(), B.Value) {
+		t.Fatalf("expected expired value %q to be dropped from flushed table", B.Value)
+	}
+
+	// Deleting an expired key reports it missing, but removes it rather than leaving it to a flush.
+	err = db.Delete(B.Key)
+	if !errors.Is(err, base.KeyError) {
+		t.Fatalf("expected KeyError when deleting expired key %q, got %v", B.Key, err)
+	}
+
+	if n := stored(db); n != 2 {
+		t.Fatalf("expected 2 stored items after deleting expired key, got %d", n)
+	}
+}
+
+// stored counts the items held by db, including those that have expired.
+func stored(db base.DB) int {
+	var n int
+
+	switch db := db.(type) {
+	case *SimpleDB:
+		n = len(db.store)
+	case *LinkedListDB:
+		for node := db.head.next; node != db.tail; node = node.next {
+			n++
+		}
+	case *SkipListDB:
+		for node := db.head.next[0]; node != nil; node = node.next[0] {
+			n++
+		}
+	}
+
+	return n
+}
+
+func TestSizeLimits(t *testing.T) {
+	for _, db := range []base.DB{NewSimpleDB(), NewLinkedListDB(), NewSkipListDB()} {
+		err := db.Put(make([]byte, base.MaxKeySize+1), nil)
+
+		var sizeErr *base.SizeError
+		if !errors.As(err, &sizeErr) || sizeErr.Field != "key" {
+			t.Fatalf("expected key size error, got %v", err)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+// Package memtable provides the in-memory key/value collections: a map, a linked list and a skip
+// list. Each implements levels.DB and can be flushed to an SSTable.
+package memtable
+
+import (
+	"io"
+	"sort"
+	"time"
+
+	"github.com/savarin/levels/internal/base"
+	"github.com/savarin/levels/iterator"
+	"github.com/savarin/levels/table"
+)
+
+type SimpleDB struct {
+	store map[string]base.Item
+	clock base.Clock
+}
+
+func NewSimpleDB() *SimpleDB {
+	return &SimpleDB{
+		store: make(map[string]base.Item),
+		clock: base.SystemClock{},
+	}
+}
+
+// SetClock replaces the clock used to decide whether items have expired.
+func (db *SimpleDB) SetClock(clock base.Clock) {
+	db.clock = clock
+}
+
+func (db SimpleDB) lookup(key []byte) (base.Item, bool) {
+	item, ok := db.store[string(key)]
+
+	if !ok || base.IsExpired(db.clock, item.Expiry) {
+		return base.Item{}, false
+	}
+
+	return item, true
+}
+
+func (db SimpleDB) Get(key []byte) (value []byte, err error) {
+	item, ok := db.lookup(key)
+
+	if !ok {
+		return nil, base.KeyError
+	}
+
+	return item.Value, nil
+}
+
+func (db SimpleDB) Has(key []byte) (ret bool, err error) {
+	_, ok := db.lookup(key)
+	return ok, nil
+}
+
+func (db SimpleDB) Put(key, value []byte) error {
+	err := base.CheckSize(key, value)
+	if err != nil {
+		return err
+	}
+
+	db.store[string(key)] = base.Item{Key: key, Value: value}
+	return nil
+}
+
+func (db SimpleDB) PutWithTTL(key, value []byte, ttl time.Duration) error {
+	err := base.CheckSize(key, value)
+	if err != nil {
+		return err
+	}
+
+	expiry, err := base.ExpiryFromTTL(db.clock, ttl)
+	if err != nil {
+		return err
+	}
+
+	db.store[string(key)] = base.Item{Key: key, Value: value, Expiry: expiry}
+	return nil
+}
+
+// Delete removes the key, returning KeyError if it is missing or has expired. An expired key is
+// removed all the same, rather than left in the map until it is flushed.
+func (db SimpleDB) Delete(key []byte) error {
+	_, ok := db.lookup(key)
+	delete(db.store, string(key))
+
+	if !ok {
+		return base.KeyError
+	}
+
+	return nil
+}
+
+func (db SimpleDB) RangeScan(start, limit []byte) (iterator.Iterator, error) {
+	startString := string(start)
+	limitString := string(limit)
+
+	if len(limit) > 0 && startString > limitString {
+		return nil, base.ValueError
+	}
+
+	strings := make([]string, len(db.store))
+	counter := 0
+
+	for k, _ := range db.store {
+		strings[counter] = k
+		counter++
+	}
+
+	sort.Strings(strings)
+
+	keys := make([][]byte, 0)
+	values := make([][]byte, 0)
+	expiries := make([]int64, 0)
+
+	for _, key := range strings {
+		item := db.store[key]
+
+		if base.IsExpired(db.clock, item.Expiry) {
+			continue
+		}
+
+		if key >= startString && (len(limit) == 0 || key < limitString) {
+			keys = append(keys, []byte(key))
+			values = append(values, []byte(item.Value))
+			expiries = append(expiries, item.Expiry)
+		}
+	}
+
+	return iterator.NewWithExpiries(keys, values, expiries), nil
+}
+
+func (db SimpleDB) Flush(w io.Writer) error {
+	return table.Flush(db, w)
+}
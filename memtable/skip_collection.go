@@ -0,0 +1,253 @@
+package memtable
+
+import (
+	"io"
+	"math/rand"
+	"time"
+
+	"github.com/savarin/levels/internal/base"
+	"github.com/savarin/levels/iterator"
+	"github.com/savarin/levels/table"
+)
+
+const (
+	maxLevel = 12
+)
+
+type skipListNode struct {
+	item  base.Item
+	next  [maxLevel]*skipListNode
+	level int
+}
+
+type SkipListDB struct {
+	head   *skipListNode
+	levels int
+	clock  base.Clock
+}
+
+func NewSkipListDB() *SkipListDB {
+	head := &skipListNode{level: maxLevel}
+	return &SkipListDB{head: head, levels: 1, clock: base.SystemClock{}}
+}
+
+// SetClock replaces the clock used to decide whether items have expired.
+func (db *SkipListDB) SetClock(clock base.Clock) {
+	db.clock = clock
+}
+
+func (db *SkipListDB) findPrevious(key []byte) [maxLevel]*skipListNode {
+	var result [maxLevel]*skipListNode
+
+	node := db.head
+	for i := db.levels - 1; i >= 0; i-- {
+		for node.next[i] != nil {
+			if string(node.next[i].item.Key) < string(key) {
+				node = node.next[i]
+			} else {
+				break
+			}
+		}
+
+		result[i] = node
+	}
+
+	return result
+}
+
+func verifyNode(previous [maxLevel]*skipListNode, key []byte) *skipListNode {
+	if previous[0].next[0] != nil && string(previous[0].next[0].item.Key) == string(key) {
+		return previous[0].next[0]
+	}
+
+	return nil
+}
+
+// skipExpired returns the first node from node onwards that has not expired.
+func (db *SkipListDB) skipExpired(node *skipListNode) *skipListNode {
+	for node != nil && base.IsExpired(db.clock, node.item.Expiry) {
+		node = node.next[0]
+	}
+
+	return node
+}
+
+func generateLevel() int {
+	level := 1
+	for level < maxLevel && rand.Intn(2) == 1 {
+		level++
+	}
+
+	return level
+}
+
+func (db *SkipListDB) Get(key []byte) (value []byte, err error) {
+	previous := db.findPrevious(key)
+	node := verifyNode(previous, key)
+
+	if node != nil && !base.IsExpired(db.clock, node.item.Expiry) {
+		return node.item.Value, nil
+	}
+
+	return nil, base.KeyError
+}
+
+func (db *SkipListDB) Has(key []byte) (ret bool, err error) {
+	_, ok := db.Get(key)
+	return ok == nil, nil
+}
+
+func (db *SkipListDB) Put(key, value []byte) error {
+	return db.put(base.Item{Key: key, Value: value})
+}
+
+func (db *SkipListDB) PutWithTTL(key, value []byte, ttl time.Duration) error {
+	expiry, err := base.ExpiryFromTTL(db.clock, ttl)
+	if err != nil {
+		return err
+	}
+
+	return db.put(base.Item{Key: key, Value: value, Expiry: expiry})
+}
+
+func (db *SkipListDB) put(item base.Item) error {
+	err := base.CheckSize(item.Key, item.Value)
+	if err != nil {
+		return err
+	}
+
+	// A nil key is the empty key, but would read as the end of iteration.
+	if item.Key == nil {
+		item.Key = []byte{}
+	}
+
+	previous := db.findPrevious(item.Key)
+	node := verifyNode(previous, item.Key)
+
+	if node != nil {
+		node.item.Value = item.Value
+		node.item.Expiry = item.Expiry
+		return nil
+	}
+
+	level := generateLevel()
+	node = &skipListNode{
+		item:  item,
+		level: level,
+	}
+
+	if level > db.levels {
+		for i := level - 1; i >= db.levels; i-- {
+			db.head.next[i] = node
+		}
+		for i := db.levels - 1; i >= 0; i-- {
+			node.next[i] = previous[i].next[i]
+			previous[i].next[i] = node
+		}
+		db.levels = level
+	} else {
+		for i := level - 1; i >= 0; i-- {
+			node.next[i] = previous[i].next[i]
+			previous[i].next[i] = node
+		}
+	}
+
+	return nil
+}
+
+// Delete removes the key, returning KeyError if it is missing or has expired. An expired key is
+// removed all the same, rather than left in the list until it is flushed.
+func (db *SkipListDB) Delete(key []byte) error {
+	previous := db.findPrevious(key)
+	node := verifyNode(previous, key)
+
+	if node == nil {
+		return base.KeyError
+	}
+
+	for i := node.level - 1; i >= 0; i-- {
+		previous[i].next[i] = node.next[i]
+	}
+
+	if base.IsExpired(db.clock, node.item.Expiry) {
+		return base.KeyError
+	}
+
+	return nil
+}
+
+func (db *SkipListDB) RangeScan(start, limit []byte) (iterator.Iterator, error) {
+	if len(limit) > 0 && string(start) > string(limit) {
+		return nil, base.ValueError
+	}
+
+	previous := db.findPrevious(start)
+	iter := &SkipListIterator{db: db, start: start, limit: limit}
+	iter.node = iter.live(previous[0].next[0])
+	return iter, nil
+}
+
+func (db *SkipListDB) Flush(w io.Writer) error {
+	return table.Flush(db, w)
+}
+
+type SkipListIterator struct {
+	db           *SkipListDB
+	node         *skipListNode
+	start, limit []byte
+}
+
+func (iter *SkipListIterator) Next() bool {
+	if iter.node == nil {
+		return false
+	}
+
+	next := iter.live(iter.node.next[0])
+	if next == nil {
+		return false
+	}
+
+	iter.node = next
+	return true
+}
+
+// live returns the first live node from node onwards, or nil if that node is at or beyond the
+// limit.
+func (iter *SkipListIterator) live(node *skipListNode) *skipListNode {
+	node = iter.db.skipExpired(node)
+
+	if node != nil && len(iter.limit) > 0 && string(node.item.Key) >= string(iter.limit) {
+		return nil
+	}
+
+	return node
+}
+
+func (iter *SkipListIterator) Error() error {
+	return nil
+}
+
+func (iter *SkipListIterator) Key() []byte {
+	if iter.node == nil {
+		return nil
+	}
+
+	return iter.node.item.Key
+}
+
+func (iter *SkipListIterator) Value() []byte {
+	if iter.node == nil {
+		return nil
+	}
+
+	return iter.node.item.Value
+}
+
+// Expiry returns the expiry of the current key/value pair, or zero if it never expires.
+func (iter *SkipListIterator) Expiry() int64 {
+	if iter.node == nil {
+		return 0
+	}
+
+	return iter.node.item.Expiry
+}
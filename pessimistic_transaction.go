@@ -0,0 +1,262 @@
+package levels
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	defaultLockTimeout = time.Second
+)
+
+// PessimisticTransactionDB wraps a DB to support transactions that lock every key they read or
+// write until they commit or roll back, so that hot keys are serialized instead of retried.
+type PessimisticTransactionDB struct {
+	mu          sync.Mutex
+	db          DB
+	locks       *LockManager
+	lockTimeout time.Duration
+	nextID      uint64
+}
+
+// NewPessimisticTransactionDB returns a PessimisticTransactionDB whose transactions wait at most
+// lockTimeout for each lock. A zero lockTimeout defaults to one second.
+func NewPessimisticTransactionDB(db DB, lockTimeout time.Duration) *PessimisticTransactionDB {
+	if lockTimeout <= 0 {
+		lockTimeout = defaultLockTimeout
+	}
+
+	return &PessimisticTransactionDB{
+		db:          db,
+		locks:       NewLockManager(),
+		lockTimeout: lockTimeout,
+	}
+}
+
+// Begin starts a transaction.
+func (pdb *PessimisticTransactionDB) Begin() *PessimisticTransaction {
+	pdb.mu.Lock()
+	defer pdb.mu.Unlock()
+
+	pdb.nextID++
+
+	return &PessimisticTransaction{
+		pdb:    pdb,
+		id:     pdb.nextID,
+		writes: make(map[string]pendingWrite),
+		locked: make(map[string]struct{}),
+	}
+}
+
+func (pdb *PessimisticTransactionDB) get(key []byte) (value []byte, err error) {
+	pdb.mu.Lock()
+	defer pdb.mu.Unlock()
+
+	return pdb.db.Get(key)
+}
+
+// PessimisticTransaction is a transaction over a PessimisticTransactionDB. Reads take shared locks
+// and writes take exclusive locks, held until Commit or Rollback. Writes are buffered, and reads
+// see the transaction's own writes.
+//
+// If an operation returns LockTimeoutError or DeadlockError the transaction still holds its other
+// locks, and the caller should Rollback and retry.
+type PessimisticTransaction struct {
+	pdb    *PessimisticTransactionDB
+	id     uint64
+	writes map[string]pendingWrite
+	locked map[string]struct{}
+	done   bool
+}
+
+func (txn *PessimisticTransaction) lock(key []byte, mode LockMode) error {
+	if txn.done {
+		return TransactionDoneError
+	}
+
+	err := txn.pdb.locks.Lock(txn.id, key, mode, txn.pdb.lockTimeout)
+	if err != nil {
+		return fmt.Errorf("locking key %q: %w", key, err)
+	}
+
+	txn.locked[string(key)] = struct{}{}
+	return nil
+}
+
+func (txn *PessimisticTransaction) Get(key []byte) (value []byte, err error) {
+	err = txn.lock(key, SharedLock)
+	if err != nil {
+		return nil, err
+	}
+
+	if w, ok := txn.writes[string(key)]; ok {
+		if w.deleted {
+			return nil, KeyError
+		}
+
+		return w.value, nil
+	}
+
+	return txn.pdb.get(key)
+}
+
+func (txn *PessimisticTransaction) Has(key []byte) (ret bool, err error) {
+	_, err = txn.Get(key)
+
+	if errors.Is(err, KeyError) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+func (txn *PessimisticTransaction) Put(key, value []byte) error {
+	err := txn.lock(key, ExclusiveLock)
+	if err != nil {
+		return err
+	}
+
+	txn.writes[string(key)] = pendingWrite{value: value}
+	return nil
+}
+
+// Delete deletes the value for the given key when the transaction commits. Like DB.Delete, it
+// returns KeyError if the key is not present.
+func (txn *PessimisticTransaction) Delete(key []byte) error {
+	err := txn.lock(key, ExclusiveLock)
+	if err != nil {
+		return err
+	}
+
+	ok, err := txn.Has(key)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return KeyError
+	}
+
+	txn.writes[string(key)] = pendingWrite{deleted: true}
+	return nil
+}
+
+// RangeScan returns an Iterator over the key/value pairs in the given range overlaid with the
+// transaction's pending writes. Every key returned is locked shared, but the range itself is not
+// locked, so keys inserted into it by other transactions may appear in a later scan.
+func (txn *PessimisticTransaction) RangeScan(start, limit []byte) (Iterator, error) {
+	if txn.done {
+		return nil, TransactionDoneError
+	}
+
+	txn.pdb.mu.Lock()
+	iter, err := txn.pdb.db.RangeScan(start, limit)
+	if err != nil {
+		txn.pdb.mu.Unlock()
+		return nil, err
+	}
+
+	r := keyRange{start: start, limit: limit}
+	var keys [][]byte
+
+	for key := iter.Key(); key != nil; key = iter.Key() {
+		if r.contains(string(key)) {
+			keys = append(keys, key)
+		}
+
+		if !iter.Next() {
+			break
+		}
+	}
+
+	err = iter.Error()
+	txn.pdb.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Values are read again once each key is locked, since they may have changed in between.
+	merged := make(map[string][]byte)
+
+	for _, key := range keys {
+		err := txn.lock(key, SharedLock)
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := txn.pdb.get(key)
+		if errors.Is(err, KeyError) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		merged[string(key)] = value
+	}
+
+	overlayWrites(merged, txn.writes, r)
+	return iteratorFromMap(merged), nil
+}
+
+func (txn *PessimisticTransaction) unlockAll() {
+	for key := range txn.locked {
+		txn.pdb.locks.Unlock(txn.id, []byte(key))
+	}
+
+	txn.locked = nil
+	txn.done = true
+}
+
+// Commit applies the transaction's writes and releases its locks. If the DB would reject any write,
+// Commit returns its error without applying the others.
+func (txn *PessimisticTransaction) Commit() error {
+	if txn.done {
+		return TransactionDoneError
+	}
+
+	defer txn.unlockAll()
+
+	err := checkWrites(txn.writes)
+	if err != nil {
+		return err
+	}
+
+	pdb := txn.pdb
+	pdb.mu.Lock()
+	defer pdb.mu.Unlock()
+
+	for key, w := range txn.writes {
+		var err error
+
+		// A key put and then deleted by the transaction may never have been in the DB, which leaves
+		// nothing to delete rather than a failed commit.
+		if w.deleted {
+			err = pdb.db.Delete([]byte(key))
+			if errors.Is(err, KeyError) {
+				err = nil
+			}
+		} else {
+			err = pdb.db.Put([]byte(key), w.value)
+		}
+
+		if err != nil {
+			return fmt.Errorf("committing write of key %q: %w", key, err)
+		}
+	}
+
+	return nil
+}
+
+// Rollback discards the transaction's writes and releases its locks.
+func (txn *PessimisticTransaction) Rollback() {
+	if txn.done {
+		return
+	}
+
+	txn.unlockAll()
+}
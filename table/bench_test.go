@@ -0,0 +1,161 @@
+package table
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/savarin/levels/internal/bench/fixture"
+)
+
+// benchKeyCounts replaces fixture.KeyCounts with a larger table, which spans many more data blocks
+// than a memtable flushed by the database benchmarks.
+var benchKeyCounts = []int{1000, 100000}
+
+func build(b *testing.B, d fixture.Data, w io.Writer) {
+	builder := NewBuilder(w, BuilderOptions{})
+
+	for _, key := range d.Keys {
+		err := builder.Add(key, d.Value)
+		if err != nil {
+			b.Fatalf("unexpected error when adding: %s", err)
+		}
+	}
+
+	err := builder.Finish()
+	if err != nil {
+		b.Fatalf("unexpected error when finishing: %s", err)
+	}
+}
+
+func open(b *testing.B, d fixture.Data) *Table {
+	var buf bytes.Buffer
+	build(b, d, &buf)
+
+	t, err := openTable(bytes.NewReader(buf.Bytes()), Options{})
+	if err != nil {
+		b.Fatalf("unexpected error when opening table: %s", err)
+	}
+
+	return t
+}
+
+// runTables runs f for every key count and value size.
+func runTables(b *testing.B, f func(b *testing.B, d fixture.Data)) {
+	for _, n := range benchKeyCounts {
+		for _, size := range fixture.ValueSizes {
+			b.Run(fmt.Sprintf("keys=%d/value=%d", n, size), func(b *testing.B) {
+				d := fixture.New(n, size)
+
+				b.ReportAllocs()
+				f(b, d)
+			})
+		}
+	}
+}
+
+// BenchmarkBuilder adds pairs to tables of the key count, including the cost of finishing each.
+func BenchmarkBuilder(b *testing.B) {
+	runTables(b, func(b *testing.B, d fixture.Data) {
+		var builder *Builder
+
+		for i := 0; i < b.N; i++ {
+			j := i % len(d.Keys)
+			if j == 0 {
+				builder = NewBuilder(io.Discard, BuilderOptions{})
+			}
+
+			err := builder.Add(d.Keys[j], d.Value)
+			if err != nil {
+				b.Fatalf("unexpected error when adding: %s", err)
+			}
+
+			if j == len(d.Keys)-1 {
+				err = builder.Finish()
+				if err != nil {
+					b.Fatalf("unexpected error when finishing: %s", err)
+				}
+			}
+		}
+	})
+}
+
+func BenchmarkTableOpen(b *testing.B) {
+	runTables(b, func(b *testing.B, d fixture.Data) {
+		var buf bytes.Buffer
+		build(b, d, &buf)
+		b.ResetTimer()
+
+		for i := 0; i < b.N; i++ {
+			_, err := Open(bytes.NewReader(buf.Bytes()))
+			if err != nil {
+				b.Fatalf("unexpected error when opening table: %s", err)
+			}
+		}
+	})
+}
+
+func BenchmarkTableGet(b *testing.B) {
+	b.Run("hit", func(b *testing.B) {
+		runTables(b, func(b *testing.B, d fixture.Data) {
+			t := open(b, d)
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				_, err := t.Get(d.Keys[d.Order[i%len(d.Keys)]])
+				if err != nil {
+					b.Fatalf("unexpected error when getting: %s", err)
+				}
+			}
+		})
+	})
+
+	b.Run("miss", func(b *testing.B) {
+		runTables(b, func(b *testing.B, d fixture.Data) {
+			t := open(b, d)
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				_, err := t.Get(d.Misses[d.Order[i%len(d.Keys)]])
+				if err == nil {
+					b.Fatalf("expected error when getting missing key")
+				}
+			}
+		})
+	})
+}
+
+// BenchmarkTableRangeScan scans ranges of each width from random starting keys, reading every
+// pair.
+func BenchmarkTableRangeScan(b *testing.B) {
+	for _, width := range fixture.ScanWidths {
+		b.Run(fmt.Sprintf("width=%d", width), func(b *testing.B) {
+			runTables(b, func(b *testing.B, d fixture.Data) {
+				if width >= len(d.Keys) {
+					b.Skip("range wider than the table")
+				}
+
+				t := open(b, d)
+				b.ResetTimer()
+
+				for i := 0; i < b.N; i++ {
+					start := d.Order[i%len(d.Keys)] % (len(d.Keys) - width)
+
+					iter, err := t.RangeScan(d.Keys[start], d.Keys[start+width])
+					if err != nil {
+						b.Fatalf("unexpected error when scanning: %s", err)
+					}
+
+					for key := iter.Key(); key != nil; key = iter.Key() {
+						iter.Value()
+
+						if !iter.Next() {
+							break
+						}
+					}
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package table
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	blockCacheShards = 16
+)
+
+var (
+	nextTableID atomic.Uint64
+)
+
+type blockKey struct {
+	table  uint64
+	offset uint64
+}
+
+type cacheEntry struct {
+	key    blockKey
+	value  interface{}
+	charge int
+	pinned bool
+}
+
+type cacheShard struct {
+	mu       sync.Mutex
+	capacity int
+	size     int
+	entries  map[blockKey]*list.Element
+	lru      *list.List
+}
+
+// BlockCache keeps recently read table blocks in memory, up to a capacity in bytes. Blocks are
+// keyed by table and offset, so a single cache can be shared by every open Table. The cache is
+// split into shards, each with its own lock and LRU list, to reduce contention between readers.
+type BlockCache struct {
+	shards [blockCacheShards]cacheShard
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// CacheStats reports the usage of a BlockCache.
+type CacheStats struct {
+	Hits, Misses   uint64
+	Size, Capacity int
+	Pinned         int
+}
+
+// NewBlockCache returns a BlockCache holding up to capacity bytes of blocks.
+func NewBlockCache(capacity int) *BlockCache {
+	c := &BlockCache{}
+
+	for i := range c.shards {
+		c.shards[i] = cacheShard{
+			capacity: capacity / blockCacheShards,
+			entries:  make(map[blockKey]*list.Element),
+			lru:      list.New(),
+		}
+	}
+
+	return c
+}
+
+func (c *BlockCache) shard(key blockKey) *cacheShard {
+	h := key.table*0x9e3779b97f4a7c15 ^ key.offset
+	h ^= h >> 32
+	return &c.shards[h%blockCacheShards]
+}
+
+// get returns the cached value for the block of the table at offset.
+func (c *BlockCache) get(table, offset uint64) (interface{}, bool) {
+	key := blockKey{table: table, offset: offset}
+	s := c.shard(key)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[key]
+	if !ok {
+		c.misses.Add(1)
+		return nil, false
+	}
+
+	c.hits.Add(1)
+	s.lru.MoveToFront(e)
+	return e.Value.(*cacheEntry).value, true
+}
+
+// insert caches value, which takes up charge bytes, for the block of the table at offset. Pinned
+// values count towards the capacity but are never evicted.
+func (c *BlockCache) insert(table, offset uint64, value interface{}, charge int, pinned bool) {
+	key := blockKey{table: table, offset: offset}
+	s := c.shard(key)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.entries[key]; ok {
+		s.remove(e)
+	}
+
+	// A value larger than the whole shard would only evict everything else and then itself.
+	if !pinned && charge > s.capacity {
+		return
+	}
+
+	entry := &cacheEntry{key: key, value: value, charge: charge, pinned: pinned}
+	s.entries[key] = s.lru.PushFront(entry)
+	s.size += charge
+
+	for e := s.lru.Back(); e != nil && s.size > s.capacity; {
+		prev := e.Prev()
+
+		if !e.Value.(*cacheEntry).pinned {
+			s.remove(e)
+		}
+
+		e = prev
+	}
+}
+
+func (s *cacheShard) remove(e *list.Element) {
+	entry := e.Value.(*cacheEntry)
+	s.lru.Remove(e)
+	delete(s.entries, entry.key)
+	s.size -= entry.charge
+}
+
+// evictTable removes every block of the table from the cache, including pinned ones.
+func (c *BlockCache) evictTable(table uint64) {
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.mu.Lock()
+
+		for key, e := range s.entries {
+			if key.table == table {
+				s.remove(e)
+			}
+		}
+
+		s.mu.Unlock()
+	}
+}
+
+// Stats returns the hit and miss counts and current size of the cache.
+func (c *BlockCache) Stats() CacheStats {
+	stats := CacheStats{
+		Hits:   c.hits.Load(),
+		Misses: c.misses.Load(),
+	}
+
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.mu.Lock()
+
+		stats.Size += s.size
+		stats.Capacity += s.capacity
+
+		for _, e := range s.entries {
+			if e.Value.(*cacheEntry).pinned {
+				stats.Pinned += e.Value.(*cacheEntry).charge
+			}
+		}
+
+		s.mu.Unlock()
+	}
+
+	return stats
+}
@@ -0,0 +1,302 @@
+package table
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/savarin/levels/internal/base"
+)
+
+var (
+	OrderError         = errors.New("Keys not added in ascending order")
+	BuilderClosedError = errors.New("Table builder already finished or abandoned")
+)
+
+// BuilderOptions configures the layout of a table written by a Builder.
+type BuilderOptions struct {
+	// BlockSize is the approximate number of bytes of entries between sparse index entries. It
+	// defaults to 4 KiB.
+	BlockSize int
+
+	// Clock provides the creation time recorded in the table properties. It defaults to the system
+	// clock.
+	Clock base.Clock
+
+	// IsDeletion, if set, reports whether a value marks a deleted key, so that deletion markers can
+	// be counted in the table properties.
+	IsDeletion func(value []byte) bool
+
+	// PropertiesCollectors create the collectors whose properties are recorded in the table, one
+	// of each for every table built.
+	PropertiesCollectors []func() PropertiesCollector
+}
+
+// Builder writes an SSTable from key/value pairs added in ascending key order, so tables can be
+// produced from any sorted source rather than only by flushing a DB.
+type Builder struct {
+	writer    simpleWriter
+	blockSize uint64
+
+	sparseIndex    []sparseIndexEntry
+	nextCheckpoint uint64
+
+	lastKey, finalKey []byte
+	finalOffset       uint64
+	entries           int
+
+	props      Properties
+	clock      base.Clock
+	isDeletion func(value []byte) bool
+	collectors []PropertiesCollector
+
+	err  error
+	done bool
+}
+
+func NewBuilder(w io.Writer, opts BuilderOptions) *Builder {
+	size := opts.BlockSize
+	if size <= 0 {
+		size = defaultBlockSize
+	}
+
+	clock := opts.Clock
+	if clock == nil {
+		clock = base.SystemClock{}
+	}
+
+	collectors := make([]PropertiesCollector, 0, len(opts.PropertiesCollectors))
+	for _, newCollector := range opts.PropertiesCollectors {
+		collectors = append(collectors, newCollector())
+	}
+
+	return &Builder{
+		writer:     simpleWriter{Writer: w},
+		blockSize:  uint64(size),
+		clock:      clock,
+		isDeletion: opts.IsDeletion,
+		collectors: collectors,
+		props: Properties{
+			Comparator:    bytewiseComparator,
+			Compression:   noCompression,
+			FormatVersion: formatVersion,
+			User:          make(map[string][]byte),
+		},
+	}
+}
+
+// Add appends the key/value pair to the table. Keys must be added in strictly ascending order.
+func (b *Builder) Add(key, value []byte) error {
+	return b.AddWithExpiry(key, value, 0)
+}
+
+// AddWithExpiry appends the key/value pair to the table with an expiry in Unix nanoseconds, after
+// which the pair is hidden from reads. Zero means the pair never expires.
+func (b *Builder) AddWithExpiry(key, value []byte, expiry int64) error {
+	if b.done {
+		return BuilderClosedError
+	}
+
+	if b.err != nil {
+		return b.err
+	}
+
+	err := base.CheckSize(key, value)
+	if err != nil {
+		return err
+	}
+
+	if b.entries > 0 && string(key) <= string(b.lastKey) {
+		return fmt.Errorf("adding key %q after %q: %w", key, b.lastKey, OrderError)
+	}
+
+	for _, c := range b.collectors {
+		err = c.Add(key, value)
+		if err != nil {
+			b.err = fmt.Errorf("collecting properties with %s: %w", c.Name(), err)
+			return b.err
+		}
+	}
+
+	startOffset := b.writer.Offset
+	b.finalOffset = startOffset
+	b.lastKey = append(b.lastKey[:0], key...)
+	b.entries++
+
+	if b.entries == 1 {
+		b.props.SmallestKey = append([]byte(nil), key...)
+	}
+
+	b.props.NumEntries++
+	b.props.RawKeySize += uint64(len(key))
+	b.props.RawValueSize += uint64(len(value))
+
+	if b.isDeletion != nil && b.isDeletion(value) {
+		b.props.NumDeletions++
+	}
+
+	if b.nextCheckpoint <= startOffset {
+		e := sparseIndexEntry{
+			key:    append([]byte(nil), key...),
+			offset: startOffset,
+		}
+
+		b.sparseIndex = append(b.sparseIndex, e)
+		b.nextCheckpoint = startOffset + b.blockSize
+
+		b.finalKey = e.key
+	}
+
+	b.err = b.writeEntry(key, value, expiry)
+	return b.err
+}
+
+func (b *Builder) writeEntry(key, value []byte, expiry int64) error {
+	err := b.writer.WriteLen(uint64(len(key)))
+	if err != nil {
+		return fmt.Errorf("writing length (%d) of key %q in table: %w", len(key), key, err)
+	}
+
+	err = b.writer.Write(key)
+	if err != nil {
+		return fmt.Errorf("writing key %q: %w in table", key, err)
+	}
+
+	err = b.writer.WriteLen(uint64(len(value)))
+	if err != nil {
+		return fmt.Errorf("writing length (%d) of value %q in table: %w", len(value), value, err)
+	}
+
+	err = b.writer.Write(value)
+	if err != nil {
+		return fmt.Errorf("writing value %q in table: %w", value, err)
+	}
+
+	err = b.writer.WriteExpiry(expiry)
+	if err != nil {
+		return fmt.Errorf("writing expiry of key %q in table: %w", key, err)
+	}
+
+	return nil
+}
+
+// SetUserProperty records a property that is returned in Properties.User once the table is
+// opened. Names starting with "levels." are reserved for built-in properties and are ignored.
+func (b *Builder) SetUserProperty(name string, value []byte) {
+	b.props.User[name] = append([]byte(nil), value...)
+}
+
+// NumEntries returns the number of key/value pairs added so far.
+func (b *Builder) NumEntries() int {
+	return b.entries
+}
+
+// Offset returns the number of bytes written so far.
+func (b *Builder) Offset() uint64 {
+	return b.writer.Offset
+}
+
+// Finish writes the sparse index that completes the table. The builder cannot be used afterwards.
+func (b *Builder) Finish() error {
+	if b.done {
+		return BuilderClosedError
+	}
+
+	b.done = true
+
+	if b.err != nil {
+		return b.err
+	}
+
+	if b.entries > 0 && string(b.lastKey) != string(b.finalKey) {
+		e := sparseIndexEntry{
+			key:    b.lastKey,
+			offset: b.finalOffset,
+		}
+
+		b.sparseIndex = append(b.sparseIndex, e)
+	}
+
+	sparseIndexOffset := b.writer.Offset
+
+	for _, e := range b.sparseIndex {
+		indexKey := e.key
+		indexOffset := e.offset
+
+		err := b.writer.WriteLen(uint64(len(indexKey)))
+		if err != nil {
+			return fmt.Errorf("writing length (%d) of key in sparse index%q: %w", len(indexKey), indexKey, err)
+		}
+
+		err = b.writer.Write(indexKey)
+		if err != nil {
+			return fmt.Errorf("writing key %q in sparse index: %w", indexKey, err)
+		}
+
+		err = b.writer.WriteLen(indexOffset)
+		if err != nil {
+			return fmt.Errorf("writing value %q in sparse index: %w", indexOffset, err)
+		}
+	}
+
+	index := blockHandle{
+		offset: sparseIndexOffset,
+		size:   b.writer.Offset - sparseIndexOffset,
+	}
+
+	b.props.DataSize = index.offset
+	b.props.IndexSize = index.size
+	b.props.CreationTime = b.clock.Now()
+
+	if b.entries > 0 {
+		b.props.LargestKey = b.lastKey
+	}
+
+	for _, c := range b.collectors {
+		user, err := c.Finish()
+		if err != nil {
+			return fmt.Errorf("collecting properties with %s: %w", c.Name(), err)
+		}
+
+		for name, value := range user {
+			b.props.User[name] = value
+		}
+	}
+
+	properties := blockHandle{offset: b.writer.Offset}
+
+	err := b.writer.Write(b.props.encode())
+	if err != nil {
+		return fmt.Errorf("writing properties: %w", err)
+	}
+
+	properties.size = b.writer.Offset - properties.offset
+	metaindex := blockHandle{offset: b.writer.Offset}
+
+	err = b.writer.Write(encodeMetaindex(map[string]blockHandle{propertiesBlockName: properties}))
+	if err != nil {
+		return fmt.Errorf("writing metaindex: %w", err)
+	}
+
+	metaindex.size = b.writer.Offset - metaindex.offset
+
+	f := footer{
+		metaindex: metaindex,
+		index:     index,
+		version:   formatVersion,
+	}
+
+	err = b.writer.Write(f.encode())
+	if err != nil {
+		return fmt.Errorf("writing footer: %w", err)
+	}
+
+	return nil
+}
+
+// Abandon stops building the table without completing it. Whatever was already written is not an
+// openable table, and it is up to the caller to discard it.
+func (b *Builder) Abandon() {
+	b.done = true
+	b.sparseIndex = nil
+}
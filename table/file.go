@@ -0,0 +1,80 @@
+package table
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/savarin/levels/internal/base"
+	"github.com/savarin/levels/iterator"
+)
+
+const (
+	defaultBlockSize = 1 << 12
+)
+
+type sparseIndexEntry struct {
+	key    []byte
+	offset uint64
+}
+
+type simpleWriter struct {
+	Offset uint64
+	Writer io.Writer
+}
+
+func (w *simpleWriter) Write(b []byte) error {
+	n, err := w.Writer.Write(b)
+
+	if err != nil {
+		return err
+	}
+
+	w.Offset += uint64(n)
+	return nil
+}
+
+func (w *simpleWriter) WriteLen(n uint64) error {
+	var buf [binary.MaxVarintLen64]byte
+	size := binary.PutUvarint(buf[:], n)
+	return w.Write(buf[:size])
+}
+
+func (w *simpleWriter) WriteExpiry(expiry int64) error {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], uint64(expiry))
+	return w.Write(buf[:])
+}
+
+func Flush(db base.DB, w io.Writer) error {
+	return FlushWithOptions(db, w, BuilderOptions{})
+}
+
+// FlushWithOptions writes the contents of db to w as an SSTable laid out according to opts.
+func FlushWithOptions(db base.DB, w io.Writer, opts BuilderOptions) error {
+	iter, err := db.RangeScan([]byte{}, []byte{})
+	if err != nil {
+		return fmt.Errorf("scanning database to flush: %w", err)
+	}
+
+	builder := NewBuilder(w, opts)
+
+	for key := iter.Key(); key != nil; key = iter.Key() {
+		err = builder.AddWithExpiry(key, iter.Value(), iterator.Expiry(iter))
+		if err != nil {
+			builder.Abandon()
+			return err
+		}
+
+		if !iter.Next() {
+			break
+		}
+	}
+
+	if err := iter.Error(); err != nil {
+		builder.Abandon()
+		return fmt.Errorf("scanning database to flush: %w", err)
+	}
+
+	return builder.Finish()
+}
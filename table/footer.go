@@ -0,0 +1,170 @@
+package table
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/savarin/levels/internal/base"
+)
+
+const (
+	// tableMagic ends every table with a footer, and spells "LVLSSTBL" in little-endian order.
+	tableMagic uint64 = 0x4c425453534c564c
+
+	// legacyFormatVersion identifies tables that end with the offset of the sparse index instead of
+	// a footer, as written before keys could expire; their entries have no expiry. Legacy tables and
+	// version 1 tables store lengths and offsets as 32-bit integers, while later versions use
+	// varints so that tables can grow beyond 4 GiB.
+	legacyFormatVersion uint32 = 0
+	formatVersion       uint32 = 2
+
+	blockHandleSize = 16
+	footerSize      = 3*blockHandleSize + 4 + 8
+)
+
+var (
+	VersionError = errors.New("Unsupported table format version")
+)
+
+// blockHandle locates a block within a table file.
+type blockHandle struct {
+	offset, size uint64
+}
+
+func (h blockHandle) end() uint64 {
+	return h.offset + h.size
+}
+
+func appendBlockHandle(buf []byte, h blockHandle) []byte {
+	buf = binary.LittleEndian.AppendUint64(buf, h.offset)
+	return binary.LittleEndian.AppendUint64(buf, h.size)
+}
+
+func decodeBlockHandle(buf []byte) blockHandle {
+	return blockHandle{
+		offset: binary.LittleEndian.Uint64(buf[0:8]),
+		size:   binary.LittleEndian.Uint64(buf[8:16]),
+	}
+}
+
+// footer is the fixed-size trailer of a table. The data blocks are followed by the sparse index,
+// the filter block (which no table has yet) and the metaindex block, which maps names of optional
+// blocks to their handles.
+type footer struct {
+	metaindex, index, filter blockHandle
+	version                  uint32
+}
+
+func (f footer) encode() []byte {
+	buf := make([]byte, 0, footerSize)
+	buf = appendBlockHandle(buf, f.metaindex)
+	buf = appendBlockHandle(buf, f.index)
+	buf = appendBlockHandle(buf, f.filter)
+	buf = binary.LittleEndian.AppendUint32(buf, f.version)
+	return binary.LittleEndian.AppendUint64(buf, tableMagic)
+}
+
+// readFooter returns the footer of the table in r. Tables written before the footer existed are
+// recognised by the missing magic number, and described by a footer with the legacy version.
+func readFooter(r base.ReaderSeeker) (footer, error) {
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return footer{}, fmt.Errorf("seeking to end of file to read footer: %s", err)
+	}
+
+	if size >= footerSize {
+		var buf [footerSize]byte
+		_, err = r.ReadAt(buf[:], size-footerSize)
+		if err != nil {
+			return footer{}, fmt.Errorf("reading footer: %s", err)
+		}
+
+		if binary.LittleEndian.Uint64(buf[footerSize-8:]) == tableMagic {
+			f := footer{
+				metaindex: decodeBlockHandle(buf[0:]),
+				index:     decodeBlockHandle(buf[blockHandleSize:]),
+				filter:    decodeBlockHandle(buf[2*blockHandleSize:]),
+				version:   binary.LittleEndian.Uint32(buf[3*blockHandleSize:]),
+			}
+
+			if f.version < 1 || f.version > formatVersion {
+				return footer{}, fmt.Errorf("table format version %d, expected %d: %w", f.version, formatVersion, VersionError)
+			}
+
+			limit := uint64(size - footerSize)
+			for _, h := range []blockHandle{f.metaindex, f.index, f.filter} {
+				if h.offset > limit || h.size > limit-h.offset {
+					return footer{}, fmt.Errorf("corrupted footer: block %d+%d beyond end of table at %d", h.offset, h.size, limit)
+				}
+			}
+
+			return f, nil
+		}
+	}
+
+	if size < 4 {
+		return footer{}, fmt.Errorf("corrupted table file: %d bytes is too short for a table", size)
+	}
+
+	var buf [4]byte
+	_, err = r.ReadAt(buf[:], size-4)
+	if err != nil {
+		return footer{}, fmt.Errorf("reading index start location: %s", err)
+	}
+
+	indexStart := uint64(binary.LittleEndian.Uint32(buf[:]))
+	indexEnd := uint64(size - 4)
+
+	if indexStart > indexEnd {
+		return footer{}, fmt.Errorf("corrupted table file: index end %d > index start %d", indexEnd, indexStart)
+	}
+
+	return footer{
+		index:   blockHandle{offset: indexStart, size: indexEnd - indexStart},
+		version: legacyFormatVersion,
+	}, nil
+}
+
+// encodeMetaindex encodes the handles of named blocks, sorted by name.
+func encodeMetaindex(handles map[string]blockHandle) []byte {
+	names := make([]string, 0, len(handles))
+	for name := range handles {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	var buf []byte
+	for _, name := range names {
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(len(name)))
+		buf = append(buf, name...)
+		buf = appendBlockHandle(buf, handles[name])
+	}
+
+	return buf
+}
+
+func decodeMetaindex(buf []byte) (map[string]blockHandle, error) {
+	handles := make(map[string]blockHandle)
+
+	for len(buf) > 0 {
+		if len(buf) < 4 {
+			return nil, fmt.Errorf("corrupted metaindex: end of block while reading name length")
+		}
+
+		n := uint64(binary.LittleEndian.Uint32(buf))
+		buf = buf[4:]
+
+		if n+blockHandleSize > uint64(len(buf)) {
+			return nil, fmt.Errorf("corrupted metaindex: end of block while reading entry")
+		}
+
+		handles[string(buf[:n])] = decodeBlockHandle(buf[n:])
+		buf = buf[n+blockHandleSize:]
+	}
+
+	return handles, nil
+}
@@ -0,0 +1,162 @@
+package table
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/savarin/levels/internal/base"
+)
+
+// buildFuzzTable writes a table with small blocks holding the entries, each value repeated so
+// that blocks hold several entries.
+func buildFuzzTable(tb testing.TB, entries ...entry) []byte {
+	var buf bytes.Buffer
+	builder := NewBuilder(&buf, BuilderOptions{BlockSize: 32})
+
+	for _, e := range entries {
+		err := builder.Add(e.Key, bytes.Repeat(e.Value, 4))
+		if err != nil {
+			tb.Fatalf("unexpected error when adding: %s", err)
+		}
+	}
+
+	err := builder.Finish()
+	if err != nil {
+		tb.Fatalf("unexpected error when finishing: %s", err)
+	}
+
+	return buf.Bytes()
+}
+
+// buildFuzzLevelDBTable writes a LevelDB table with small blocks holding the entries.
+func buildFuzzLevelDBTable(tb testing.TB, entries ...entry) []byte {
+	var buf bytes.Buffer
+	builder := NewLevelDBBuilder(&buf, LevelDBOptions{BlockSize: 32, RestartInterval: 2})
+
+	for _, e := range entries {
+		err := builder.Add(e.Key, e.Value)
+		if err != nil {
+			tb.Fatalf("unexpected error when adding: %s", err)
+		}
+	}
+
+	err := builder.Finish()
+	if err != nil {
+		tb.Fatalf("unexpected error when finishing: %s", err)
+	}
+
+	return buf.Bytes()
+}
+
+// exercise gets each key from db and scans between them, failing only if a scan that succeeds
+// returns keys out of order or outside its range.
+func exercise(t *testing.T, db base.ImmutableDB, keys [][]byte) {
+	for _, key := range keys {
+		db.Get(key)
+		db.Has(key)
+	}
+
+	for i, start := range keys {
+		for _, limit := range [][]byte{nil, keys[(i+1)%len(keys)]} {
+			iter, err := db.RangeScan(start, limit)
+			if err != nil {
+				continue
+			}
+
+			var previous []byte
+			for key := iter.Key(); key != nil; key = iter.Key() {
+				if previous != nil && string(previous) >= string(key) {
+					t.Fatalf("expected keys in increasing order, got %q then %q", previous, key)
+				}
+
+				if string(key) < string(start) || (len(limit) > 0 && string(key) >= string(limit)) {
+					t.Fatalf("expected keys from %q to %q, got %q", start, limit, key)
+				}
+
+				previous = key
+
+				if !iter.Next() {
+					break
+				}
+			}
+		}
+	}
+}
+
+// FuzzOpen opens arbitrary bytes as a table, then reads and scans keys of the index along with the
+// given key. No input may cause a panic or an allocation beyond the size of the input.
+func FuzzOpen(f *testing.F) {
+	for _, table := range [][]byte{
+		buildFuzzTable(f, A, B, C),
+		buildFuzzTable(f),
+		legacyTable(A, B, C),
+		legacyTable(B, A, C),
+	} {
+		f.Add(table, []byte("b"))
+	}
+
+	f.Fuzz(func(t *testing.T, data, key []byte) {
+		table, err := openTable(bytes.NewReader(data), Options{})
+		if err != nil {
+			return
+		}
+
+		keys := [][]byte{key, {}, []byte("\xff")}
+		for _, e := range table.sparseIndex {
+			keys = append(keys, e.key)
+		}
+
+		exercise(t, table, keys)
+		table.Properties()
+	})
+}
+
+// FuzzOpenLevelDB opens arbitrary bytes as a LevelDB table, then reads and scans keys of the index
+// along with the given key.
+func FuzzOpenLevelDB(f *testing.F) {
+	for _, table := range [][]byte{
+		buildFuzzLevelDBTable(f, A, B, C),
+		buildFuzzLevelDBTable(f),
+	} {
+		f.Add(table, []byte("b"))
+	}
+
+	f.Fuzz(func(t *testing.T, data, key []byte) {
+		table, err := openLevelDBTable(bytes.NewReader(data), LevelDBOptions{})
+		if err != nil {
+			return
+		}
+
+		keys := [][]byte{key, {}, []byte("\xff")}
+		for _, e := range table.index {
+			keys = append(keys, e.key)
+		}
+
+		exercise(t, table, keys)
+	})
+}
+
+// FuzzVerify verifies arbitrary bytes as a table, and repairs them into a table that must verify
+// without problems.
+func FuzzVerify(f *testing.F) {
+	f.Add(buildFuzzTable(f, A, B, C))
+	f.Add(legacyTable(A, B, C))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_, err := Verify(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+
+		var buf bytes.Buffer
+		_, err = Repair(bytes.NewReader(data), &buf, BuilderOptions{})
+		if err != nil {
+			return
+		}
+
+		result, err := Verify(bytes.NewReader(buf.Bytes()))
+		if err != nil || !result.OK() {
+			t.Fatalf("expected repaired table to verify, got %+v: %v", result, err)
+		}
+	})
+}
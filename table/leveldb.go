@@ -0,0 +1,801 @@
+package table
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"sort"
+
+	"github.com/savarin/levels/internal/base"
+	"github.com/savarin/levels/iterator"
+)
+
+// LevelDB's table format, as described in its doc/table_format.md. A table is a sequence of data
+// blocks followed by a metaindex block, an index block and a fixed-size footer. Every block ends
+// with a trailer holding its compression type and a masked CRC32C checksum.
+const (
+	levelDBMagic            uint64 = 0xdb4775248b80fb57
+	levelDBFooterSize              = 2*levelDBMaxHandleSize + 8
+	levelDBMaxHandleSize           = 2 * binary.MaxVarintLen64
+	levelDBBlockTrailerSize        = 5
+
+	levelDBNoCompression = 0
+
+	defaultRestartInterval = 16
+
+	// Keys in the tables of a LevelDB database are internal keys, which end with 8 bytes packing a
+	// 56-bit sequence number and the value type.
+	levelDBInternalTrailerSize        = 8
+	levelDBMaxSequence         uint64 = 1<<56 - 1
+	levelDBTypeDeletion               = 0
+	levelDBTypeValue                  = 1
+
+	crcMaskDelta = 0xa282ead8
+)
+
+var (
+	CompressionError = errors.New("Unsupported compression type")
+
+	castagnoli = crc32.MakeTable(crc32.Castagnoli)
+)
+
+// LevelDBOptions configures how tables in LevelDB's format are written and read.
+type LevelDBOptions struct {
+	// BlockSize is the approximate size of the data blocks. It defaults to 4 KiB.
+	BlockSize int
+
+	// RestartInterval is the number of keys between restart points, where a key is stored whole
+	// rather than sharing a prefix with the key before it. It defaults to 16.
+	RestartInterval int
+
+	// InternalKeys stores keys as LevelDB does in the tables of a database, followed by a sequence
+	// number and value type, rather than as they are. Tables written with it use sequence number 0.
+	InternalKeys bool
+}
+
+// maskCRC masks a checksum the way LevelDB does, since computing the CRC of data that contains
+// embedded CRCs is problematic.
+func maskCRC(crc uint32) uint32 {
+	return (crc>>15 | crc<<17) + crcMaskDelta
+}
+
+func blockChecksum(contents []byte, compression byte) uint32 {
+	crc := crc32.Update(0, castagnoli, contents)
+	return maskCRC(crc32.Update(crc, castagnoli, []byte{compression}))
+}
+
+func appendLevelDBHandle(buf []byte, h blockHandle) []byte {
+	buf = binary.AppendUvarint(buf, h.offset)
+	return binary.AppendUvarint(buf, h.size)
+}
+
+func decodeLevelDBHandle(buf []byte) (h blockHandle, n int, err error) {
+	offset, i := binary.Uvarint(buf)
+	if i <= 0 {
+		return blockHandle{}, 0, fmt.Errorf("corrupted block handle: invalid offset")
+	}
+
+	size, j := binary.Uvarint(buf[i:])
+	if j <= 0 {
+		return blockHandle{}, 0, fmt.Errorf("corrupted block handle: invalid size")
+	}
+
+	return blockHandle{offset: offset, size: size}, i + j, nil
+}
+
+// internalKey appends the trailer of an internal key with the given sequence number and type.
+func internalKey(key []byte, sequence uint64, kind byte) []byte {
+	buf := append([]byte(nil), key...)
+	return binary.LittleEndian.AppendUint64(buf, sequence<<8|uint64(kind))
+}
+
+func splitInternalKey(key []byte) (userKey []byte, sequence uint64, kind byte, err error) {
+	if len(key) < levelDBInternalTrailerSize {
+		return nil, 0, 0, fmt.Errorf("corrupted internal key %q: too short", key)
+	}
+
+	n := len(key) - levelDBInternalTrailerSize
+	trailer := binary.LittleEndian.Uint64(key[n:])
+	return key[:n], trailer >> 8, byte(trailer), nil
+}
+
+// compareInternalKeys orders internal keys by user key, then by decreasing sequence number, so that
+// the newest version of a key comes first.
+func compareInternalKeys(a, b []byte) int {
+	an, bn := len(a)-levelDBInternalTrailerSize, len(b)-levelDBInternalTrailerSize
+	if an < 0 || bn < 0 {
+		return bytes.Compare(a, b)
+	}
+
+	c := bytes.Compare(a[:an], b[:bn])
+	if c != 0 {
+		return c
+	}
+
+	at, bt := binary.LittleEndian.Uint64(a[an:]), binary.LittleEndian.Uint64(b[bn:])
+	if at > bt {
+		return -1
+	}
+
+	if at < bt {
+		return 1
+	}
+
+	return 0
+}
+
+// shortestSeparator returns a short key that is at least start and less than limit, as LevelDB's
+// bytewise comparator does, to keep index blocks small.
+func shortestSeparator(start, limit []byte) []byte {
+	n := 0
+	for n < len(start) && n < len(limit) && start[n] == limit[n] {
+		n++
+	}
+
+	if n < len(start) && n < len(limit) && start[n] < 0xff && start[n]+1 < limit[n] {
+		separator := append([]byte(nil), start[:n+1]...)
+		separator[n]++
+		return separator
+	}
+
+	return start
+}
+
+// shortSuccessor returns a short key that is at least key.
+func shortSuccessor(key []byte) []byte {
+	for i, b := range key {
+		if b != 0xff {
+			successor := append([]byte(nil), key[:i+1]...)
+			successor[i]++
+			return successor
+		}
+	}
+
+	return key
+}
+
+// levelDBBlock builds a block of prefix-compressed entries. Each entry stores the length of the
+// prefix shared with the previous key, the rest of the key and the value. Every restart interval
+// a key is stored whole, and the block ends with the offsets of these restart points.
+type levelDBBlock struct {
+	interval int
+	buf      []byte
+	restarts []uint32
+	counter  int
+	lastKey  []byte
+}
+
+func newLevelDBBlock(interval int) *levelDBBlock {
+	return &levelDBBlock{interval: interval, restarts: []uint32{0}}
+}
+
+func (b *levelDBBlock) add(key, value []byte) {
+	shared := 0
+
+	if b.counter < b.interval {
+		for shared < len(key) && shared < len(b.lastKey) && key[shared] == b.lastKey[shared] {
+			shared++
+		}
+	} else {
+		b.restarts = append(b.restarts, uint32(len(b.buf)))
+		b.counter = 0
+	}
+
+	b.buf = binary.AppendUvarint(b.buf, uint64(shared))
+	b.buf = binary.AppendUvarint(b.buf, uint64(len(key)-shared))
+	b.buf = binary.AppendUvarint(b.buf, uint64(len(value)))
+	b.buf = append(b.buf, key[shared:]...)
+	b.buf = append(b.buf, value...)
+
+	b.lastKey = append(b.lastKey[:0], key...)
+	b.counter++
+}
+
+func (b *levelDBBlock) empty() bool {
+	return len(b.buf) == 0
+}
+
+func (b *levelDBBlock) estimatedSize() int {
+	return len(b.buf) + 4*len(b.restarts) + 4
+}
+
+// finish returns the contents of the block and resets the builder.
+func (b *levelDBBlock) finish() []byte {
+	buf := b.buf
+	for _, r := range b.restarts {
+		buf = binary.LittleEndian.AppendUint32(buf, r)
+	}
+
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(b.restarts)))
+
+	*b = levelDBBlock{interval: b.interval, restarts: []uint32{0}}
+	return buf
+}
+
+// LevelDBBuilder writes a table in LevelDB's format, one key/value pair at a time.
+type LevelDBBuilder struct {
+	writer    simpleWriter
+	blockSize int
+	internal  bool
+
+	data, index *levelDBBlock
+
+	// The index entry for a finished data block is added once the next key is known, so that its
+	// key can be shortened to fall between the two blocks.
+	pendingIndex  bool
+	pendingHandle blockHandle
+
+	lastKey []byte
+	entries int
+
+	err  error
+	done bool
+}
+
+// NewLevelDBBuilder returns a LevelDBBuilder writing to w.
+func NewLevelDBBuilder(w io.Writer, opts LevelDBOptions) *LevelDBBuilder {
+	blockSize := opts.BlockSize
+	if blockSize <= 0 {
+		blockSize = defaultBlockSize
+	}
+
+	interval := opts.RestartInterval
+	if interval <= 0 {
+		interval = defaultRestartInterval
+	}
+
+	return &LevelDBBuilder{
+		writer:    simpleWriter{Writer: w},
+		blockSize: blockSize,
+		internal:  opts.InternalKeys,
+		data:      newLevelDBBlock(interval),
+		index:     newLevelDBBlock(1),
+	}
+}
+
+// Add appends a key/value pair to the table. Keys must be added in strictly increasing order.
+func (b *LevelDBBuilder) Add(key, value []byte) error {
+	if b.done {
+		return BuilderClosedError
+	}
+
+	if b.err != nil {
+		return b.err
+	}
+
+	err := base.CheckSize(key, value)
+	if err != nil {
+		return err
+	}
+
+	if b.entries > 0 && string(key) <= string(b.lastKey) {
+		return fmt.Errorf("adding key %q after %q: %w", key, b.lastKey, OrderError)
+	}
+
+	if b.pendingIndex {
+		b.addIndexEntry(shortestSeparator(b.lastKey, key))
+	}
+
+	b.lastKey = append(b.lastKey[:0], key...)
+	b.entries++
+
+	if b.internal {
+		key = internalKey(key, 0, levelDBTypeValue)
+	}
+
+	b.data.add(key, value)
+
+	if b.data.estimatedSize() >= b.blockSize {
+		b.err = b.flushData()
+	}
+
+	return b.err
+}
+
+// addIndexEntry adds the pending index entry under short, a key between the last key of the block
+// and the first key of the next one. Like LevelDB, with internal keys the shortened key is only
+// used if it is actually shorter, and takes the largest sequence number to sort before any version.
+func (b *LevelDBBuilder) addIndexEntry(short []byte) {
+	key := short
+
+	if b.internal {
+		if len(short) < len(b.lastKey) && string(b.lastKey) < string(short) {
+			key = internalKey(short, levelDBMaxSequence, levelDBTypeValue)
+		} else {
+			key = internalKey(b.lastKey, 0, levelDBTypeValue)
+		}
+	}
+
+	b.index.add(key, appendLevelDBHandle(nil, b.pendingHandle))
+	b.pendingIndex = false
+}
+
+func (b *LevelDBBuilder) flushData() error {
+	if b.data.empty() {
+		return nil
+	}
+
+	h, err := b.writeBlock(b.data.finish())
+	if err != nil {
+		return fmt.Errorf("writing data block: %w", err)
+	}
+
+	b.pendingIndex = true
+	b.pendingHandle = h
+	return nil
+}
+
+func (b *LevelDBBuilder) writeBlock(contents []byte) (blockHandle, error) {
+	h := blockHandle{offset: b.writer.Offset, size: uint64(len(contents))}
+
+	var trailer [levelDBBlockTrailerSize]byte
+	trailer[0] = levelDBNoCompression
+	binary.LittleEndian.PutUint32(trailer[1:], blockChecksum(contents, levelDBNoCompression))
+
+	err := b.writer.Write(contents)
+	if err != nil {
+		return blockHandle{}, err
+	}
+
+	err = b.writer.Write(trailer[:])
+	if err != nil {
+		return blockHandle{}, err
+	}
+
+	return h, nil
+}
+
+// NumEntries returns the number of key/value pairs added so far.
+func (b *LevelDBBuilder) NumEntries() int {
+	return b.entries
+}
+
+// Finish writes the remaining data block, the metaindex and index blocks and the footer. The
+// builder cannot be used afterwards.
+func (b *LevelDBBuilder) Finish() error {
+	if b.done {
+		return BuilderClosedError
+	}
+
+	b.done = true
+
+	if b.err != nil {
+		return b.err
+	}
+
+	err := b.flushData()
+	if err != nil {
+		return err
+	}
+
+	// Without filters the metaindex block has no entries, but it is still written.
+	metaindex, err := b.writeBlock(newLevelDBBlock(defaultRestartInterval).finish())
+	if err != nil {
+		return fmt.Errorf("writing metaindex block: %w", err)
+	}
+
+	if b.pendingIndex {
+		b.addIndexEntry(shortSuccessor(b.lastKey))
+	}
+
+	index, err := b.writeBlock(b.index.finish())
+	if err != nil {
+		return fmt.Errorf("writing index block: %w", err)
+	}
+
+	buf := make([]byte, 0, levelDBFooterSize)
+	buf = appendLevelDBHandle(buf, metaindex)
+	buf = appendLevelDBHandle(buf, index)
+	buf = append(buf, make([]byte, 2*levelDBMaxHandleSize-len(buf))...)
+	buf = binary.LittleEndian.AppendUint64(buf, levelDBMagic)
+
+	err = b.writer.Write(buf)
+	if err != nil {
+		return fmt.Errorf("writing footer: %w", err)
+	}
+
+	return nil
+}
+
+// Abandon stops the builder without finishing the table.
+func (b *LevelDBBuilder) Abandon() {
+	b.done = true
+}
+
+// FlushLevelDB writes the contents of db to w as a table in LevelDB's format. Expiry times have no
+// equivalent in LevelDB, so pairs that have not expired yet are written without them.
+func FlushLevelDB(db base.DB, w io.Writer, opts LevelDBOptions) error {
+	iter, err := db.RangeScan([]byte{}, []byte{})
+	if err != nil {
+		return fmt.Errorf("scanning database to flush: %w", err)
+	}
+
+	builder := NewLevelDBBuilder(w, opts)
+
+	for key := iter.Key(); key != nil; key = iter.Key() {
+		err = builder.Add(key, iter.Value())
+		if err != nil {
+			builder.Abandon()
+			return err
+		}
+
+		if !iter.Next() {
+			break
+		}
+	}
+
+	if err := iter.Error(); err != nil {
+		builder.Abandon()
+		return fmt.Errorf("scanning database to flush: %w", err)
+	}
+
+	return builder.Finish()
+}
+
+type levelDBIndexEntry struct {
+	key    []byte
+	handle blockHandle
+}
+
+// LevelDBTable is a table in LevelDB's format opened for reading. With internal keys, only the
+// newest version of each key is visible, and keys whose newest version is a deletion are hidden.
+type LevelDBTable struct {
+	reader   base.ReaderSeeker
+	internal bool
+	dataEnd  uint64
+	index    []levelDBIndexEntry
+}
+
+// OpenLevelDB opens a table in LevelDB's format. Blocks compressed with Snappy are not supported.
+func OpenLevelDB(r base.ReaderSeeker, opts LevelDBOptions) (base.ImmutableDB, error) {
+	t, err := openLevelDBTable(r, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+func openLevelDBTable(r base.ReaderSeeker, opts LevelDBOptions) (*LevelDBTable, error) {
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, fmt.Errorf("seeking to end of file to read footer: %s", err)
+	}
+
+	if size < levelDBFooterSize {
+		return nil, fmt.Errorf("corrupted table file: %d bytes is too short for a LevelDB table", size)
+	}
+
+	var buf [levelDBFooterSize]byte
+	_, err = r.ReadAt(buf[:], size-levelDBFooterSize)
+	if err != nil {
+		return nil, fmt.Errorf("reading footer: %s", err)
+	}
+
+	if binary.LittleEndian.Uint64(buf[levelDBFooterSize-8:]) != levelDBMagic {
+		return nil, fmt.Errorf("corrupted table file: not a LevelDB table")
+	}
+
+	_, n, err := decodeLevelDBHandle(buf[:])
+	if err != nil {
+		return nil, fmt.Errorf("reading metaindex handle: %w", err)
+	}
+
+	indexHandle, _, err := decodeLevelDBHandle(buf[n:])
+	if err != nil {
+		return nil, fmt.Errorf("reading index handle: %w", err)
+	}
+
+	t := &LevelDBTable{
+		reader:   r,
+		internal: opts.InternalKeys,
+		dataEnd:  uint64(size - levelDBFooterSize),
+	}
+
+	block, err := t.readBlock(indexHandle)
+	if err != nil {
+		return nil, fmt.Errorf("reading index block: %w", err)
+	}
+
+	err = block.each(func(key, value []byte) bool {
+		var h blockHandle
+		h, _, err = decodeLevelDBHandle(value)
+		if err != nil {
+			return false
+		}
+
+		t.index = append(t.index, levelDBIndexEntry{key: key, handle: h})
+		return true
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("reading index block: %w", err)
+	}
+
+	return t, nil
+}
+
+// levelDBBlockReader decodes the entries of a block.
+type levelDBBlockReader struct {
+	data     []byte
+	restarts []byte
+}
+
+// readBlock reads the block at h and verifies its checksum.
+func (t *LevelDBTable) readBlock(h blockHandle) (levelDBBlockReader, error) {
+	// The size is compared after subtracting from the end, since adding to a corrupted size may
+	// overflow.
+	if h.offset > t.dataEnd || t.dataEnd-h.offset < levelDBBlockTrailerSize || h.size > t.dataEnd-h.offset-levelDBBlockTrailerSize {
+		return levelDBBlockReader{}, fmt.Errorf("corrupted table file: block %d+%d beyond end of blocks at %d", h.offset, h.size, t.dataEnd)
+	}
+
+	buf := make([]byte, h.size+levelDBBlockTrailerSize)
+	_, err := t.reader.ReadAt(buf, int64(h.offset))
+	if err != nil {
+		return levelDBBlockReader{}, fmt.Errorf("reading block at offset %d: %s", h.offset, err)
+	}
+
+	contents, compression := buf[:h.size], buf[h.size]
+
+	if blockChecksum(contents, compression) != binary.LittleEndian.Uint32(buf[h.size+1:]) {
+		return levelDBBlockReader{}, fmt.Errorf("corrupted block at offset %d: checksum mismatch", h.offset)
+	}
+
+	if compression != levelDBNoCompression {
+		return levelDBBlockReader{}, fmt.Errorf("block at offset %d has compression type %d: %w", h.offset, compression, CompressionError)
+	}
+
+	if len(contents) < 4 {
+		return levelDBBlockReader{}, fmt.Errorf("corrupted block at offset %d: too short", h.offset)
+	}
+
+	numRestarts := uint64(binary.LittleEndian.Uint32(contents[len(contents)-4:]))
+	if numRestarts > uint64(len(contents)-4)/4 {
+		return levelDBBlockReader{}, fmt.Errorf("corrupted block at offset %d: %d restart points", h.offset, numRestarts)
+	}
+
+	restartsOffset := len(contents) - 4 - 4*int(numRestarts)
+
+	return levelDBBlockReader{
+		data:     contents[:restartsOffset],
+		restarts: contents[restartsOffset : len(contents)-4],
+	}, nil
+}
+
+func (b levelDBBlockReader) numRestarts() int {
+	return len(b.restarts) / 4
+}
+
+func (b levelDBBlockReader) restart(i int) int {
+	return int(binary.LittleEndian.Uint32(b.restarts[4*i:]))
+}
+
+// decodeEntry decodes the entry at offset, whose key shares a prefix with lastKey. It returns a
+// new slice for the key, the value as a slice of the block and the offset of the next entry.
+func (b levelDBBlockReader) decodeEntry(offset int, lastKey []byte) (key, value []byte, next int, err error) {
+	if offset > len(b.data) {
+		return nil, nil, 0, fmt.Errorf("corrupted block: entry at %d beyond end of block", offset)
+	}
+
+	buf := b.data[offset:]
+
+	var lengths [3]uint64
+	for i := range lengths {
+		v, n := binary.Uvarint(buf)
+		if n <= 0 {
+			return nil, nil, 0, fmt.Errorf("corrupted block: end of block while reading entry at %d", offset)
+		}
+
+		lengths[i] = v
+		buf = buf[n:]
+	}
+
+	shared, nonShared, valueLength := lengths[0], lengths[1], lengths[2]
+
+	if shared > uint64(len(lastKey)) || nonShared > uint64(len(buf)) || valueLength > uint64(len(buf))-nonShared {
+		return nil, nil, 0, fmt.Errorf("corrupted block: bad entry lengths at %d", offset)
+	}
+
+	key = append(append([]byte(nil), lastKey[:shared]...), buf[:nonShared]...)
+	value = buf[nonShared : nonShared+valueLength]
+	next = len(b.data) - len(buf) + int(nonShared+valueLength)
+
+	return key, value, next, nil
+}
+
+// each calls f for every entry of the block from the first, until f returns false.
+func (b levelDBBlockReader) each(f func(key, value []byte) bool) error {
+	return b.eachFrom(0, f)
+}
+
+func (b levelDBBlockReader) eachFrom(offset int, f func(key, value []byte) bool) error {
+	var key []byte
+
+	for offset < len(b.data) {
+		k, value, next, err := b.decodeEntry(offset, key)
+		if err != nil {
+			return err
+		}
+
+		if !f(k, value) {
+			return nil
+		}
+
+		key, offset = k, next
+	}
+
+	return nil
+}
+
+// seek calls f for every entry of the block from the first one not less than target. It uses the
+// restart points, where keys are stored whole, to skip most of the entries before target.
+func (b levelDBBlockReader) seek(target []byte, compare func(a, b []byte) int, f func(key, value []byte) bool) error {
+	var err error
+
+	i := sort.Search(b.numRestarts(), func(i int) bool {
+		if err != nil {
+			return true
+		}
+
+		var key []byte
+		key, _, _, err = b.decodeEntry(b.restart(i), nil)
+		return err == nil && compare(key, target) >= 0
+	})
+
+	if err != nil {
+		return err
+	}
+
+	offset := 0
+	if i > 0 {
+		offset = b.restart(i - 1)
+	}
+
+	return b.eachFrom(offset, func(key, value []byte) bool {
+		if compare(key, target) < 0 {
+			return true
+		}
+
+		return f(key, value)
+	})
+}
+
+func (t *LevelDBTable) compare(a, b []byte) int {
+	if t.internal {
+		return compareInternalKeys(a, b)
+	}
+
+	return bytes.Compare(a, b)
+}
+
+// seekKey returns the key to seek to for the given user key, which is the first possible version
+// of it with internal keys.
+func (t *LevelDBTable) seekKey(key []byte) []byte {
+	if t.internal {
+		return internalKey(key, levelDBMaxSequence, levelDBTypeValue)
+	}
+
+	return key
+}
+
+// scan calls f with the user key, value type and value of every entry from start onwards, until f
+// returns false.
+func (t *LevelDBTable) scan(start []byte, f func(key []byte, kind byte, value []byte) bool) error {
+	target := t.seekKey(start)
+
+	first := sort.Search(len(t.index), func(i int) bool {
+		return t.compare(t.index[i].key, target) >= 0
+	})
+
+	var keyErr error
+	done := false
+
+	for i := first; i < len(t.index) && !done; i++ {
+		block, err := t.readBlock(t.index[i].handle)
+		if err != nil {
+			return err
+		}
+
+		err = block.seek(target, t.compare, func(key, value []byte) bool {
+			kind := byte(levelDBTypeValue)
+
+			if t.internal {
+				key, _, kind, keyErr = splitInternalKey(key)
+				if keyErr != nil {
+					done = true
+					return false
+				}
+			}
+
+			done = !f(key, kind, value)
+			return !done
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return keyErr
+}
+
+func (t *LevelDBTable) Get(key []byte) (value []byte, err error) {
+	found := false
+
+	err = t.scan(key, func(k []byte, kind byte, v []byte) bool {
+		if string(k) == string(key) && kind == levelDBTypeValue {
+			value = v
+			found = true
+		}
+
+		return false
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !found {
+		return nil, base.KeyError
+	}
+
+	return value, nil
+}
+
+func (t *LevelDBTable) Has(key []byte) (ret bool, err error) {
+	_, e := t.Get(key)
+	return e == nil, nil
+}
+
+func (t *LevelDBTable) RangeScan(start, limit []byte) (iterator.Iterator, error) {
+	startString := string(start)
+	limitString := string(limit)
+
+	if len(limit) > 0 && startString > limitString {
+		return nil, base.ValueError
+	}
+
+	keys := make([][]byte, 0)
+	values := make([][]byte, 0)
+
+	var lastKey []byte
+	var err error
+
+	scanErr := t.scan(start, func(key []byte, kind byte, value []byte) bool {
+		if len(limit) > 0 && string(key) >= limitString {
+			return false
+		}
+
+		// Older versions of a key follow its newest version, which is the only one visible.
+		if lastKey != nil && string(key) == string(lastKey) {
+			return true
+		}
+
+		if lastKey != nil && string(key) < string(lastKey) {
+			err = fmt.Errorf("corrupted table: key %q after %q: %w", key, lastKey, CorruptionError)
+			return false
+		}
+
+		lastKey = key
+
+		if kind == levelDBTypeValue {
+			keys = append(keys, key)
+			values = append(values, value)
+		}
+
+		return true
+	})
+
+	if scanErr != nil {
+		return nil, scanErr
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return iterator.New(keys, values), nil
+}
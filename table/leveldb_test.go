@@ -0,0 +1,204 @@
+package table
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/savarin/levels/internal/base"
+)
+
+// The golden files in testdata/leveldb were encoded by hand following LevelDB's table_format.md,
+// without going through this package:
+//
+//   - simple.ldb holds apple=red, banana=yellow and cherry="dark red" in a single block, indexed
+//     under "d", the short successor of "cherry".
+//   - blocks.ldb holds key00 to key11 with values value00 to value11, written with 64-byte blocks
+//     and a restart interval of 4, giving blocks of 5, 5 and 2 entries.
+//   - internal.ldb holds internal keys: a@5=a5, a@3=a3, a deletion of b@4, b@2=b2 and c@1=c1.
+func readGolden(t *testing.T, name string) []byte {
+	data, err := os.ReadFile(filepath.Join("testdata", "leveldb", name))
+	if err != nil {
+		t.Fatalf("unexpected error when reading golden file: %s", err)
+	}
+
+	return data
+}
+
+func blocksEntries() []entry {
+	var entries []entry
+	for i := 0; i < 12; i++ {
+		entries = append(entries, entry{Key: []byte(fmt.Sprintf("key%02d", i)), Value: []byte(fmt.Sprintf("value%02d", i))})
+	}
+
+	return entries
+}
+
+func buildLevelDB(t *testing.T, entries []entry, opts LevelDBOptions) []byte {
+	var buf bytes.Buffer
+	builder := NewLevelDBBuilder(&buf, opts)
+
+	for _, e := range entries {
+		err := builder.Add(e.Key, e.Value)
+		if err != nil {
+			t.Fatalf("unexpected error when adding: %s", err)
+		}
+	}
+
+	err := builder.Finish()
+	if err != nil {
+		t.Fatalf("unexpected error when finishing: %s", err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestLevelDBGoldenWrite(t *testing.T) {
+	tests := []struct {
+		golden  string
+		entries []entry
+		opts    LevelDBOptions
+	}{
+		{"simple.ldb", []entry{{[]byte("apple"), []byte("red")}, {[]byte("banana"), []byte("yellow")}, {[]byte("cherry"), []byte("dark red")}}, LevelDBOptions{}},
+		{"blocks.ldb", blocksEntries(), LevelDBOptions{BlockSize: 64, RestartInterval: 4}},
+	}
+
+	for _, tt := range tests {
+		data := buildLevelDB(t, tt.entries, tt.opts)
+
+		if !bytes.Equal(data, readGolden(t, tt.golden)) {
+			t.Fatalf("written table differs from %s:\n%x", tt.golden, data)
+		}
+	}
+}
+
+func TestLevelDBGoldenRead(t *testing.T) {
+	table, err := OpenLevelDB(bytes.NewReader(readGolden(t, "blocks.ldb")), LevelDBOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error when opening table: %s", err)
+	}
+
+	entries := blocksEntries()
+
+	for _, e := range entries {
+		v, err := table.Get(e.Key)
+		if err != nil || string(v) != string(e.Value) {
+			t.Fatalf("unexpected value %q for key %q: %v", v, e.Key, err)
+		}
+	}
+
+	for _, key := range []string{"a", "key", "key05x", "key99"} {
+		_, err = table.Get([]byte(key))
+		if !errors.Is(err, base.KeyError) {
+			t.Fatalf("expected error when getting missing key %q, got %v", key, err)
+		}
+	}
+
+	iter, err := table.RangeScan([]byte("key03"), []byte("key10"))
+	if err != nil {
+		t.Fatalf("unexpected error when scanning: %s", err)
+	}
+
+	for i := 3; i < 10; i++ {
+		if string(iter.Key()) != string(entries[i].Key) || string(iter.Value()) != string(entries[i].Value) {
+			t.Fatalf("expected %q at position %d, got %q", entries[i].Key, i, iter.Key())
+		}
+
+		if iter.Next() != (i < 9) {
+			t.Fatalf("unexpected end of scan at position %d", i)
+		}
+	}
+}
+
+func TestLevelDBInternalKeys(t *testing.T) {
+	table, err := OpenLevelDB(bytes.NewReader(readGolden(t, "internal.ldb")), LevelDBOptions{InternalKeys: true})
+	if err != nil {
+		t.Fatalf("unexpected error when opening table: %s", err)
+	}
+
+	for key, want := range map[string]string{"a": "a5", "c": "c1"} {
+		v, err := table.Get([]byte(key))
+		if err != nil || string(v) != want {
+			t.Fatalf("expected %q for key %q, got %q: %v", want, key, v, err)
+		}
+	}
+
+	_, err = table.Get([]byte("b"))
+	if !errors.Is(err, base.KeyError) {
+		t.Fatalf("expected error when getting deleted key, got %v", err)
+	}
+
+	iter, err := table.RangeScan([]byte{}, []byte("z"))
+	if err != nil {
+		t.Fatalf("unexpected error when scanning: %s", err)
+	}
+
+	var keys []string
+	for key := iter.Key(); key != nil; key = iter.Key() {
+		keys = append(keys, string(key)+"="+string(iter.Value()))
+
+		if !iter.Next() {
+			break
+		}
+	}
+
+	if fmt.Sprint(keys) != "[a=a5 c=c1]" {
+		t.Fatalf("unexpected pairs from scan %v", keys)
+	}
+
+	// Tables written with internal keys read back the same way.
+	data := buildLevelDB(t, blocksEntries(), LevelDBOptions{BlockSize: 64, InternalKeys: true})
+
+	table, err = OpenLevelDB(bytes.NewReader(data), LevelDBOptions{InternalKeys: true})
+	if err != nil {
+		t.Fatalf("unexpected error when opening table: %s", err)
+	}
+
+	for _, e := range blocksEntries() {
+		v, err := table.Get(e.Key)
+		if err != nil || string(v) != string(e.Value) {
+			t.Fatalf("unexpected value %q for key %q: %v", v, e.Key, err)
+		}
+	}
+}
+
+func TestLevelDBCorruption(t *testing.T) {
+	golden := readGolden(t, "simple.ldb")
+
+	corrupted := append([]byte(nil), golden...)
+	corrupted[3]++
+
+	table, err := OpenLevelDB(bytes.NewReader(corrupted), LevelDBOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error when opening table: %s", err)
+	}
+
+	_, err = table.Get([]byte("apple"))
+	if err == nil {
+		t.Fatalf("expected checksum error when reading corrupted block")
+	}
+
+	_, err = OpenLevelDB(bytes.NewReader(golden[:len(golden)-1]), LevelDBOptions{})
+	if err == nil {
+		t.Fatalf("expected error when opening truncated table")
+	}
+
+	// An index handle whose size overflows when the block trailer is added to it.
+	var footer []byte
+	footer = appendLevelDBHandle(footer, blockHandle{})
+	footer = appendLevelDBHandle(footer, blockHandle{offset: 0, size: math.MaxUint64 - 2})
+	footer = append(footer, make([]byte, levelDBFooterSize-8-len(footer))...)
+	footer = binary.LittleEndian.AppendUint64(footer, levelDBMagic)
+
+	overflowed := append(golden[:len(golden)-levelDBFooterSize:len(golden)-levelDBFooterSize], footer...)
+
+	_, err = OpenLevelDB(bytes.NewReader(overflowed), LevelDBOptions{})
+	if err == nil {
+		t.Fatalf("expected error when opening table with overflowing index handle")
+	}
+}
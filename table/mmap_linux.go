@@ -0,0 +1,76 @@
+//go:build linux
+
+package table
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// MmapTable is a Table read through a read-only memory mapping of its file. Get and RangeScan
+// return keys and values that point directly into the mapping instead of copies, so they must not
+// be modified and must not be used after Close unmaps the file. Once closed, the table and its
+// iterators return ClosedError.
+type MmapTable struct {
+	*Table
+	file *os.File
+}
+
+// OpenMmap memory-maps the table at path. Blocks are read straight from the mapping, so
+// opts.BlockCache is ignored.
+func OpenMmap(path string, opts Options) (*MmapTable, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening table %s: %w", path, err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("reading size of table %s: %w", path, err)
+	}
+
+	if info.Size() == 0 || int64(int(info.Size())) != info.Size() {
+		f.Close()
+		return nil, fmt.Errorf("corrupted table file: cannot map %d bytes", info.Size())
+	}
+
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(info.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("mapping table %s: %w", path, err)
+	}
+
+	opts.BlockCache = nil
+	m := newMapping(data)
+
+	t, err := openTable(m, opts)
+	if err != nil {
+		syscall.Munmap(data)
+		f.Close()
+		return nil, err
+	}
+
+	t.mapped = m
+
+	return &MmapTable{Table: t, file: f}, nil
+}
+
+// Close unmaps and closes the table file, waiting for reads in progress to finish first. Keys and
+// values previously returned by the table become invalid, and reading them will crash the program.
+func (t *MmapTable) Close() error {
+	data := t.mapped.close()
+	if data == nil {
+		return nil
+	}
+
+	err := syscall.Munmap(data)
+
+	closeErr := t.file.Close()
+	if err == nil {
+		err = closeErr
+	}
+
+	return err
+}
@@ -0,0 +1,208 @@
+//go:build linux
+
+package table
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/savarin/levels/internal/base"
+)
+
+func writeTableFile(tb testing.TB, n int) string {
+	path := filepath.Join(tb.TempDir(), "table.sst")
+
+	f, err := os.Create(path)
+	if err != nil {
+		tb.Fatalf("unexpected error when creating table file: %s", err)
+	}
+	defer f.Close()
+
+	buildTable(tb, f, n, "key%08d")
+	return path
+}
+
+func TestMmapTable(t *testing.T) {
+	path := writeTableFile(t, 1000)
+
+	table, err := OpenMmap(path, Options{})
+	if err != nil {
+		t.Fatalf("unexpected error when mapping table: %s", err)
+	}
+	defer table.Close()
+
+	for _, i := range []int{0, 123, 999} {
+		v, err := table.Get([]byte(fmt.Sprintf("key%08d", i)))
+		if err != nil || string(v) != fmt.Sprintf("value%08d", i) {
+			t.Fatalf("unexpected value %q for key %d: %v", v, i, err)
+		}
+	}
+
+	iter, err := table.RangeScan([]byte("key00000500"), []byte("key00000600"))
+	if err != nil {
+		t.Fatalf("unexpected error when scanning: %s", err)
+	}
+
+	for i := 500; i < 600; i++ {
+		if string(iter.Key()) != fmt.Sprintf("key%08d", i) {
+			t.Fatalf("expected key%08d got %q", i, iter.Key())
+		}
+
+		if iter.Next() != (i < 599) {
+			t.Fatalf("unexpected end of scan after key%08d", i)
+		}
+	}
+}
+
+func TestMmapTableClose(t *testing.T) {
+	path := writeTableFile(t, 1000)
+
+	table, err := OpenMmap(path, Options{})
+	if err != nil {
+		t.Fatalf("unexpected error when mapping table: %s", err)
+	}
+
+	iter, err := table.RangeScan([]byte{}, []byte{})
+	if err != nil {
+		t.Fatalf("unexpected error when scanning: %s", err)
+	}
+
+	err = table.Close()
+	if err != nil {
+		t.Fatalf("unexpected error when closing table: %s", err)
+	}
+
+	_, err = table.Get([]byte("key00000123"))
+	if !errors.Is(err, ClosedError) {
+		t.Fatalf("expected ClosedError when getting from closed table, got %v", err)
+	}
+
+	_, err = table.RangeScan([]byte{}, []byte{})
+	if !errors.Is(err, ClosedError) {
+		t.Fatalf("expected ClosedError when scanning closed table, got %v", err)
+	}
+
+	_, err = table.Properties()
+	if !errors.Is(err, ClosedError) {
+		t.Fatalf("expected ClosedError when reading properties of closed table, got %v", err)
+	}
+
+	if iter.Next() || !errors.Is(iter.Error(), ClosedError) {
+		t.Fatalf("expected ClosedError from iterator over closed table, got %v", iter.Error())
+	}
+
+	err = table.Close()
+	if err != nil {
+		t.Fatalf("unexpected error when closing table twice: %s", err)
+	}
+}
+
+// TestMmapTableConcurrentClose closes a table while it is read from, which the race detector
+// checks is synchronised. Reads only check for errors, since the keys and values they return are
+// invalid once the table is closed.
+func TestMmapTableConcurrentClose(t *testing.T) {
+	path := writeTableFile(t, 1000)
+
+	table, err := OpenMmap(path, Options{})
+	if err != nil {
+		t.Fatalf("unexpected error when mapping table: %s", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			for j := 0; j < 100; j++ {
+				_, err := table.Get([]byte(fmt.Sprintf("key%08d", (i*100+j)%1000)))
+				if errors.Is(err, ClosedError) {
+					return
+				}
+
+				if err != nil {
+					errs <- err
+					return
+				}
+
+				iter, err := table.RangeScan([]byte{}, []byte{})
+				if errors.Is(err, ClosedError) {
+					return
+				}
+
+				if err != nil {
+					errs <- err
+					return
+				}
+
+				for iter.Next() {
+				}
+
+				if iter.Error() != nil && !errors.Is(iter.Error(), ClosedError) {
+					errs <- iter.Error()
+					return
+				}
+			}
+		}(i)
+	}
+
+	err = table.Close()
+	if err != nil {
+		t.Fatalf("unexpected error when closing table: %s", err)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Fatalf("unexpected error when reading table while closing: %s", err)
+	}
+}
+
+func benchmarkTableGet(b *testing.B, table base.ImmutableDB, n int) {
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_, err := table.Get([]byte(fmt.Sprintf("key%08d", i%n)))
+		if err != nil {
+			b.Fatalf("unexpected error when getting: %s", err)
+		}
+	}
+}
+
+func BenchmarkTableGetReader(b *testing.B) {
+	path := writeTableFile(b, 100000)
+
+	f, err := os.Open(path)
+	if err != nil {
+		b.Fatalf("unexpected error when opening table file: %s", err)
+	}
+	defer f.Close()
+
+	table, err := Open(f)
+	if err != nil {
+		b.Fatalf("unexpected error when opening table: %s", err)
+	}
+
+	benchmarkTableGet(b, table, 100000)
+}
+
+func BenchmarkTableGetMmap(b *testing.B) {
+	path := writeTableFile(b, 100000)
+
+	table, err := OpenMmap(path, Options{})
+	if err != nil {
+		b.Fatalf("unexpected error when mapping table: %s", err)
+	}
+	defer table.Close()
+
+	benchmarkTableGet(b, table, 100000)
+}
@@ -0,0 +1,22 @@
+//go:build !linux
+
+package table
+
+import (
+	"fmt"
+)
+
+// MmapTable is a Table read through a memory mapping of its file. Memory mapping is only
+// supported on Linux.
+type MmapTable struct {
+	*Table
+}
+
+// OpenMmap returns an error, since memory mapping is only supported on Linux.
+func OpenMmap(path string, opts Options) (*MmapTable, error) {
+	return nil, fmt.Errorf("opening table %s: memory mapping is not supported on this platform", path)
+}
+
+func (t *MmapTable) Close() error {
+	return nil
+}
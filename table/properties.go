@@ -0,0 +1,223 @@
+package table
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/savarin/levels/internal/base"
+)
+
+const (
+	propertiesBlockName = "levels.properties"
+
+	// reservedPropertyPrefix starts the names of built-in properties. User properties with names
+	// starting with it are ignored.
+	reservedPropertyPrefix = "levels."
+
+	bytewiseComparator = "bytewise"
+	noCompression      = "none"
+)
+
+const (
+	propNumEntries    = "levels.num.entries"
+	propNumDeletions  = "levels.num.deletions"
+	propRawKeySize    = "levels.raw.key.size"
+	propRawValueSize  = "levels.raw.value.size"
+	propDataSize      = "levels.data.size"
+	propIndexSize     = "levels.index.size"
+	propSmallestKey   = "levels.smallest.key"
+	propLargestKey    = "levels.largest.key"
+	propCreationTime  = "levels.creation.time"
+	propComparator    = "levels.comparator"
+	propCompression   = "levels.compression"
+	propFormatVersion = "levels.format.version"
+)
+
+// Properties describes the contents of a table. They are recorded by the Builder when
+// the table is written, so they can be read without scanning the table.
+type Properties struct {
+	NumEntries   uint64
+	NumDeletions uint64
+
+	// RawKeySize and RawValueSize are the total sizes of the keys and values added, while DataSize
+	// and IndexSize are the on-disk sizes of the data blocks and sparse index.
+	RawKeySize   uint64
+	RawValueSize uint64
+	DataSize     uint64
+	IndexSize    uint64
+
+	SmallestKey []byte
+	LargestKey  []byte
+
+	CreationTime  time.Time
+	Comparator    string
+	Compression   string
+	FormatVersion uint32
+
+	// User holds properties set through Builder.SetUserProperty.
+	User map[string][]byte
+}
+
+// PropertiesCollector gathers user properties while a table is built, such as the range of
+// timestamps found in its values, so that readers can decide whether to look at the table at all.
+// A collector is created for each table and sees every key/value pair in the order they are added.
+type PropertiesCollector interface {
+	// Name identifies the collector in errors.
+	Name() string
+
+	// Add is called for every key/value pair added to the table. An error aborts the build.
+	Add(key, value []byte) error
+
+	// Finish returns the properties to record in the table once every pair has been added. They are
+	// returned in Properties.User, and names starting with "levels." are ignored.
+	Finish() (map[string][]byte, error)
+}
+
+func appendProperty(buf []byte, name string, value []byte) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(name)))
+	buf = append(buf, name...)
+	buf = binary.AppendUvarint(buf, uint64(len(value)))
+	return append(buf, value...)
+}
+
+func (p *Properties) encode() []byte {
+	props := map[string][]byte{
+		propNumEntries:    binary.AppendUvarint(nil, p.NumEntries),
+		propNumDeletions:  binary.AppendUvarint(nil, p.NumDeletions),
+		propRawKeySize:    binary.AppendUvarint(nil, p.RawKeySize),
+		propRawValueSize:  binary.AppendUvarint(nil, p.RawValueSize),
+		propDataSize:      binary.AppendUvarint(nil, p.DataSize),
+		propIndexSize:     binary.AppendUvarint(nil, p.IndexSize),
+		propSmallestKey:   p.SmallestKey,
+		propLargestKey:    p.LargestKey,
+		propCreationTime:  binary.AppendVarint(nil, p.CreationTime.UnixNano()),
+		propComparator:    []byte(p.Comparator),
+		propCompression:   []byte(p.Compression),
+		propFormatVersion: binary.AppendUvarint(nil, uint64(p.FormatVersion)),
+	}
+
+	for name, value := range p.User {
+		if !strings.HasPrefix(name, reservedPropertyPrefix) {
+			props[name] = value
+		}
+	}
+
+	names := make([]string, 0, len(props))
+	for name := range props {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	var buf []byte
+	for _, name := range names {
+		buf = appendProperty(buf, name, props[name])
+	}
+
+	return buf
+}
+
+func decodeProperties(buf []byte) (*Properties, error) {
+	p := &Properties{User: make(map[string][]byte)}
+
+	for len(buf) > 0 {
+		nameLength, n := binary.Uvarint(buf)
+		if n <= 0 || nameLength > uint64(len(buf)-n) {
+			return nil, fmt.Errorf("corrupted properties: end of block while reading name")
+		}
+
+		name := string(buf[n : n+int(nameLength)])
+		buf = buf[n+int(nameLength):]
+
+		valueLength, n := binary.Uvarint(buf)
+		if n <= 0 || valueLength > uint64(len(buf)-n) {
+			return nil, fmt.Errorf("corrupted properties: end of block while reading value of %q", name)
+		}
+
+		value := buf[n : n+int(valueLength)]
+		buf = buf[n+int(valueLength):]
+
+		err := p.set(name, value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return p, nil
+}
+
+func (p *Properties) set(name string, value []byte) error {
+	uvarint := func(dst *uint64) error {
+		v, n := binary.Uvarint(value)
+		if n <= 0 {
+			return fmt.Errorf("corrupted properties: invalid value for %q", name)
+		}
+
+		*dst = v
+		return nil
+	}
+
+	switch name {
+	case propNumEntries:
+		return uvarint(&p.NumEntries)
+	case propNumDeletions:
+		return uvarint(&p.NumDeletions)
+	case propRawKeySize:
+		return uvarint(&p.RawKeySize)
+	case propRawValueSize:
+		return uvarint(&p.RawValueSize)
+	case propDataSize:
+		return uvarint(&p.DataSize)
+	case propIndexSize:
+		return uvarint(&p.IndexSize)
+	case propSmallestKey:
+		p.SmallestKey = append([]byte(nil), value...)
+	case propLargestKey:
+		p.LargestKey = append([]byte(nil), value...)
+	case propCreationTime:
+		v, n := binary.Varint(value)
+		if n <= 0 {
+			return fmt.Errorf("corrupted properties: invalid value for %q", name)
+		}
+
+		p.CreationTime = time.Unix(0, v)
+	case propComparator:
+		p.Comparator = string(value)
+	case propCompression:
+		p.Compression = string(value)
+	case propFormatVersion:
+		var v uint64
+		err := uvarint(&v)
+		if err != nil {
+			return err
+		}
+
+		p.FormatVersion = uint32(v)
+	default:
+		if !strings.HasPrefix(name, reservedPropertyPrefix) {
+			p.User[name] = append([]byte(nil), value...)
+		}
+	}
+
+	return nil
+}
+
+// Properties returns the properties recorded when the table was written. Tables without a
+// properties block, such as legacy tables, return KeyError.
+func (t Table) Properties() (*Properties, error) {
+	h, ok := t.metaindex[propertiesBlockName]
+	if !ok {
+		return nil, fmt.Errorf("reading table properties: %w", base.KeyError)
+	}
+
+	buf := make([]byte, h.size)
+	_, err := t.reader.ReadAt(buf, int64(h.offset))
+	if err != nil {
+		return nil, fmt.Errorf("reading table properties: %w", err)
+	}
+
+	return decodeProperties(buf)
+}
@@ -0,0 +1,78 @@
+package table
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/savarin/levels/internal/base"
+)
+
+// scanTable opens a table holding keys key00000 to key00499 in several blocks.
+func scanTable(t *testing.T) base.ImmutableDB {
+	table, err := Open(bytes.NewReader(flushTable(t, 500)))
+	if err != nil {
+		t.Fatalf("unexpected error when opening table: %s", err)
+	}
+
+	return table
+}
+
+func checkScan(t *testing.T, table base.ImmutableDB, start, limit []byte, from, to int) {
+	iter, err := table.RangeScan(start, limit)
+	if err != nil {
+		t.Fatalf("unexpected error when scanning from %q to %q: %s", start, limit, err)
+	}
+
+	i := from
+	for key := iter.Key(); key != nil; key = iter.Key() {
+		if string(key) != fmt.Sprintf("key%05d", i) {
+			t.Fatalf("expected key %d in scan from %q to %q, got %q", i, start, limit, key)
+		}
+
+		i++
+
+		if !iter.Next() {
+			break
+		}
+	}
+
+	if i != to {
+		t.Fatalf("expected scan from %q to %q to end before key %d, got %d", start, limit, to, i)
+	}
+}
+
+func TestTableScan(t *testing.T) {
+	table := scanTable(t)
+
+	// Keys in the last block, which runs to the end of the data, are found like any other.
+	for _, i := range []int{0, 1, 250, 498, 499} {
+		v, err := table.Get([]byte(fmt.Sprintf("key%05d", i)))
+		if err != nil || string(v) != fmt.Sprintf("value%05d", i) {
+			t.Fatalf("unexpected value %q for key %d: %v", v, i, err)
+		}
+	}
+
+	for _, key := range []string{"a", "key00499a", "z"} {
+		_, err := table.Get([]byte(key))
+		if !errors.Is(err, base.KeyError) {
+			t.Fatalf("expected KeyError for key %q, got %v", key, err)
+		}
+	}
+
+	// The limit is exclusive, and an empty limit leaves the scan unbounded.
+	checkScan(t, table, []byte("key00100"), []byte("key00200"), 100, 200)
+	checkScan(t, table, []byte("key00100a"), []byte("key00200a"), 101, 201)
+	checkScan(t, table, []byte("key00450"), []byte("z"), 450, 500)
+	checkScan(t, table, []byte{}, []byte{}, 0, 500)
+	checkScan(t, table, []byte("key00499a"), []byte("z"), 500, 500)
+
+	// A table without keys holds no keys.
+	empty, err := Open(bytes.NewReader(flushTable(t, 0)))
+	if err != nil {
+		t.Fatalf("unexpected error when opening empty table: %s", err)
+	}
+
+	checkScan(t, empty, []byte{}, []byte{}, 0, 0)
+}
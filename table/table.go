@@ -0,0 +1,635 @@
+// Package table implements the SSTable format: a Builder writing sorted key/value pairs into data
+// blocks followed by a sparse index, properties and a footer, and a Table reading them back, with
+// block and table caches. It also reads and writes tables in LevelDB's format.
+package table
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/savarin/levels/internal/base"
+	"github.com/savarin/levels/iterator"
+)
+
+var (
+	ClosedError = errors.New("Table already closed")
+)
+
+type Table struct {
+	id          uint64
+	reader      base.ReaderSeeker
+	sparseIndex []sparseIndexEntry
+	indexStart  int64
+	indexEnd    int64
+	dataEnd     uint64
+	clock       base.Clock
+	cache       *BlockCache
+	cacheIndex  bool
+	pinIndex    bool
+	version     uint32
+	metaindex   map[string]blockHandle
+
+	// mapped holds the whole table when it is memory-mapped, in which case blocks are slices of it.
+	mapped *mapping
+}
+
+// mapping is the memory mapping of a table, which also serves as the table's reader. It is shared
+// by every copy of the Table, including those held by iterators, so that once it is unmapped they
+// all return ClosedError rather than read from it.
+type mapping struct {
+	// mu is held for reading while the mapping is read from, and for writing while it is closed, so
+	// that it is not unmapped in the middle of a read.
+	mu     sync.RWMutex
+	data   []byte
+	reader *bytes.Reader
+	closed bool
+}
+
+func newMapping(data []byte) *mapping {
+	return &mapping{data: data, reader: bytes.NewReader(data)}
+}
+
+// acquire keeps the mapping from being closed until release is called, returning ClosedError if
+// it already has been. Tables that are not memory-mapped have a nil mapping, which needs neither.
+func (m *mapping) acquire() error {
+	if m == nil {
+		return nil
+	}
+
+	m.mu.RLock()
+
+	if m.closed {
+		m.mu.RUnlock()
+		return ClosedError
+	}
+
+	return nil
+}
+
+func (m *mapping) release() {
+	if m != nil {
+		m.mu.RUnlock()
+	}
+}
+
+func (m *mapping) Read(p []byte) (n int, err error) {
+	err = m.acquire()
+	if err != nil {
+		return 0, err
+	}
+	defer m.release()
+
+	return m.reader.Read(p)
+}
+
+func (m *mapping) ReadAt(p []byte, off int64) (n int, err error) {
+	err = m.acquire()
+	if err != nil {
+		return 0, err
+	}
+	defer m.release()
+
+	return m.reader.ReadAt(p, off)
+}
+
+func (m *mapping) Seek(offset int64, whence int) (int64, error) {
+	err := m.acquire()
+	if err != nil {
+		return 0, err
+	}
+	defer m.release()
+
+	return m.reader.Seek(offset, whence)
+}
+
+// close marks the mapping unusable once reads in progress finish, returning the memory to unmap,
+// or nil if it was already closed.
+func (m *mapping) close() []byte {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	data := m.data
+	m.data, m.reader, m.closed = nil, nil, true
+	return data
+}
+
+// Options configures how an opened table is read.
+type Options struct {
+	// Clock decides whether entries have expired. It defaults to the system clock.
+	Clock base.Clock
+
+	// BlockCache, if set, caches the blocks read from the table. A single BlockCache is meant to be
+	// shared by every open Table.
+	BlockCache *BlockCache
+
+	// CacheIndex keeps the sparse index in the BlockCache, where it counts towards the capacity and
+	// may be evicted, rather than holding it for as long as the Table is open.
+	CacheIndex bool
+
+	// PinIndex prevents a sparse index kept in the BlockCache from being evicted. The table format
+	// has no filter blocks, so the index is the only block that can be pinned.
+	PinIndex bool
+}
+
+func Open(r base.ReaderSeeker) (base.ImmutableDB, error) {
+	return OpenWithOptions(r, Options{})
+}
+
+func OpenWithOptions(r base.ReaderSeeker, opts Options) (base.ImmutableDB, error) {
+	t, err := openTable(r, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+func openTable(r base.ReaderSeeker, opts Options) (*Table, error) {
+	clock := opts.Clock
+	if clock == nil {
+		clock = base.SystemClock{}
+	}
+
+	f, err := readFooter(r)
+	if err != nil {
+		return nil, err
+	}
+
+	indexStart := int64(f.index.offset)
+	indexEnd := int64(f.index.end())
+
+	t := &Table{
+		id:         nextTableID.Add(1),
+		reader:     r,
+		indexStart: indexStart,
+		indexEnd:   indexEnd,
+		dataEnd:    uint64(indexStart),
+		clock:      clock,
+		cache:      opts.BlockCache,
+		cacheIndex: opts.BlockCache != nil && opts.CacheIndex,
+		pinIndex:   opts.PinIndex,
+		version:    f.version,
+	}
+
+	if t.cacheIndex {
+		_, err = t.index()
+	} else {
+		t.sparseIndex, err = readSparseIndex(r, indexStart, indexEnd, t.version)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if f.metaindex.size > 0 {
+		buf := make([]byte, f.metaindex.size)
+		_, err = r.ReadAt(buf, int64(f.metaindex.offset))
+		if err != nil {
+			return nil, fmt.Errorf("reading metaindex: %s", err)
+		}
+
+		t.metaindex, err = decodeMetaindex(buf)
+		if err != nil {
+			return nil, err
+		}
+
+		for name, h := range t.metaindex {
+			if h.offset > f.metaindex.offset || h.size > f.metaindex.offset-h.offset {
+				return nil, fmt.Errorf("corrupted metaindex: block %q at %d+%d beyond metaindex at %d", name, h.offset, h.size, f.metaindex.offset)
+			}
+		}
+	}
+
+	return t, nil
+}
+
+// FormatVersion returns the version of the table's file format. Tables written before the format
+// was versioned report version 0.
+func (t Table) FormatVersion() uint32 {
+	return t.version
+}
+
+// Close drops the table's blocks from the BlockCache. It does not close the underlying reader.
+func (t Table) Close() error {
+	if t.cache != nil {
+		t.cache.evictTable(t.id)
+	}
+
+	return nil
+}
+
+// decodeLength decodes a length or offset at the start of buf, which is a uvarint from format
+// version 2 and a little-endian uint32 before that. It returns the number of bytes read, or zero if
+// buf is too short.
+func decodeLength(buf []byte, version uint32) (length uint64, n int) {
+	if version >= 2 {
+		length, n = binary.Uvarint(buf)
+		if n <= 0 {
+			return 0, 0
+		}
+
+		return length, n
+	}
+
+	if len(buf) < 4 {
+		return 0, 0
+	}
+
+	return uint64(binary.LittleEndian.Uint32(buf)), 4
+}
+
+func readSparseIndex(r base.ReaderSeeker, indexStart, indexEnd int64, version uint32) ([]sparseIndexEntry, error) {
+	buf := make([]byte, indexEnd-indexStart)
+	_, err := r.ReadAt(buf, indexStart)
+	if err != nil {
+		return nil, fmt.Errorf("reading index: %s", err)
+	}
+
+	var sparseIndex []sparseIndexEntry
+
+	for len(buf) > 0 {
+		keyLength, n := decodeLength(buf, version)
+		if n == 0 {
+			return nil, fmt.Errorf("corrupted index: end of index while reading key length")
+		}
+
+		buf = buf[n:]
+
+		if keyLength > uint64(len(buf)) {
+			return nil, fmt.Errorf("corrupted index: end of index while reading key")
+		}
+
+		key := append([]byte(nil), buf[:keyLength]...)
+		buf = buf[keyLength:]
+
+		blockOffset, n := decodeLength(buf, version)
+		if n == 0 {
+			return nil, fmt.Errorf("corrupted index: end of index while reading offset for key %q", key)
+		}
+
+		buf = buf[n:]
+
+		e := sparseIndexEntry{
+			key:    key,
+			offset: blockOffset,
+		}
+		sparseIndex = append(sparseIndex, e)
+	}
+
+	return sparseIndex, nil
+}
+
+// index returns the sparse index of the table, reading it back into the BlockCache if it is kept
+// there and has been evicted.
+func (t Table) index() ([]sparseIndexEntry, error) {
+	if !t.cacheIndex {
+		return t.sparseIndex, nil
+	}
+
+	v, ok := t.cache.get(t.id, uint64(t.indexStart))
+	if ok {
+		return v.([]sparseIndexEntry), nil
+	}
+
+	sparseIndex, err := readSparseIndex(t.reader, t.indexStart, t.indexEnd, t.version)
+	if err != nil {
+		return nil, err
+	}
+
+	t.cache.insert(t.id, uint64(t.indexStart), sparseIndex, int(t.indexEnd-t.indexStart), t.pinIndex)
+	return sparseIndex, nil
+}
+
+// readBlock returns the contents of the table between the given offsets, going through the
+// BlockCache if there is one. Blocks of a memory-mapped table are slices of the mapping, which the
+// caller must have acquired.
+func (t Table) readBlock(offsetStart, offsetEnd uint64) ([]byte, error) {
+	if t.mapped != nil {
+		data := t.mapped.data
+		if offsetStart > offsetEnd || offsetEnd > uint64(len(data)) {
+			return nil, fmt.Errorf("corrupted table file: block %d-%d outside mapping of %d bytes", offsetStart, offsetEnd, len(data))
+		}
+
+		return data[offsetStart:offsetEnd], nil
+	}
+
+	if t.cache != nil {
+		v, ok := t.cache.get(t.id, offsetStart)
+		if ok {
+			return v.([]byte), nil
+		}
+	}
+
+	if offsetStart > offsetEnd || offsetEnd > t.dataEnd {
+		return nil, fmt.Errorf("corrupted table file: block %d-%d outside data ending at %d", offsetStart, offsetEnd, t.dataEnd)
+	}
+
+	block := make([]byte, offsetEnd-offsetStart)
+	_, err := t.reader.ReadAt(block, int64(offsetStart))
+	if err != nil {
+		return nil, fmt.Errorf("reading block at offset %d: %s", offsetStart, err)
+	}
+
+	if t.cache != nil {
+		t.cache.insert(t.id, offsetStart, block, len(block), false)
+	}
+
+	return block, nil
+}
+
+// expirySize returns the size of the expiry ending each entry of the given format version. Legacy
+// tables were written before keys could expire, so their entries have none.
+func expirySize(version uint32) int {
+	if version == legacyFormatVersion {
+		return 0
+	}
+
+	return 8
+}
+
+// decodeEntry decodes the key/value pair and expiry at the start of block, written in the given
+// format version. The key and value are slices of block rather than copies, and n is the number of
+// bytes the entry takes up. Entries of legacy tables never expire.
+func decodeEntry(block []byte, version uint32) (key, value []byte, expiry int64, n int, err error) {
+	keyLength, size := decodeLength(block, version)
+	if size == 0 {
+		return nil, nil, 0, 0, fmt.Errorf("corrupted block: end of block while reading key length")
+	}
+
+	n = size
+
+	if keyLength > uint64(len(block)-n) {
+		return nil, nil, 0, 0, fmt.Errorf("corrupted block: end of block while reading key")
+	}
+
+	key = block[n : n+int(keyLength)]
+	n += int(keyLength)
+
+	valueLength, size := decodeLength(block[n:], version)
+	if size == 0 {
+		return nil, nil, 0, 0, fmt.Errorf("corrupted block: end of block while reading value length")
+	}
+
+	n += size
+
+	if valueLength > uint64(len(block)-n) {
+		return nil, nil, 0, 0, fmt.Errorf("corrupted block: end of block while reading value")
+	}
+
+	value = block[n : n+int(valueLength)]
+	n += int(valueLength)
+
+	if expirySize(version) == 0 {
+		return key, value, 0, n, nil
+	}
+
+	if len(block)-n < 8 {
+		return nil, nil, 0, 0, fmt.Errorf("corrupted block: end of block while reading expiry")
+	}
+
+	expiry = int64(binary.LittleEndian.Uint64(block[n:]))
+	n += 8
+
+	return key, value, expiry, n, nil
+}
+
+// own returns b, copied if it points into a block shared through the BlockCache.
+func (t Table) own(b []byte) []byte {
+	if t.cache == nil {
+		return b
+	}
+
+	return append([]byte(nil), b...)
+}
+
+// getBlock returns the position in the sparse index of the block that would contain key.
+func getBlock(sparseIndex []sparseIndexEntry, key []byte) (i int, isOffset bool) {
+	if len(sparseIndex) == 0 || string(key) < string(sparseIndex[0].key) {
+		return 0, false
+	}
+
+	for i := 1; i < len(sparseIndex); i++ {
+		keyEnd := sparseIndex[i].key
+
+		if string(key) < string(keyEnd) {
+			return i - 1, true
+		}
+	}
+
+	return len(sparseIndex) - 1, true
+}
+
+// blockOffsets returns the offsets of the i-th block. The final block runs from the last sparse
+// index entry to the end of the data.
+func (t Table) blockOffsets(sparseIndex []sparseIndexEntry, i int) (offsetStart, offsetEnd uint64) {
+	if i == len(sparseIndex)-1 {
+		return sparseIndex[i].offset, t.dataEnd
+	}
+
+	return sparseIndex[i].offset, sparseIndex[i+1].offset
+}
+
+func (t Table) findKey(offsetStart, offsetEnd uint64, key []byte) (value []byte, err error) {
+	block, err := t.readBlock(offsetStart, offsetEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	for len(block) > 0 {
+		currentKey, v, expiry, n, err := decodeEntry(block, t.version)
+		if err != nil {
+			return nil, err
+		}
+
+		block = block[n:]
+
+		if string(key) == string(currentKey) {
+			if base.IsExpired(t.clock, expiry) {
+				return nil, base.KeyError
+			}
+
+			return t.own(v), nil
+		}
+	}
+
+	return nil, base.KeyError
+}
+
+func (t Table) Get(key []byte) (value []byte, err error) {
+	err = t.mapped.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer t.mapped.release()
+
+	sparseIndex, err := t.index()
+	if err != nil {
+		return nil, err
+	}
+
+	i, isOffset := getBlock(sparseIndex, key)
+	if !isOffset {
+		return nil, base.KeyError
+	}
+
+	offsetStart, offsetEnd := t.blockOffsets(sparseIndex, i)
+
+	v, err := t.findKey(offsetStart, offsetEnd, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+func (t Table) Has(key []byte) (ret bool, err error) {
+	_, e := t.Get(key)
+	return e == nil, nil
+}
+
+func (t Table) RangeScan(start, limit []byte) (iterator.Iterator, error) {
+	if len(limit) > 0 && string(start) > string(limit) {
+		return nil, base.ValueError
+	}
+
+	sparseIndex, err := t.index()
+	if err != nil {
+		return nil, err
+	}
+
+	first, _ := getBlock(sparseIndex, start)
+
+	iter := &tableIterator{
+		table:       t,
+		sparseIndex: sparseIndex,
+		next:        first,
+		start:       start,
+		limit:       limit,
+	}
+
+	if !iter.advance() && iter.err != nil {
+		return nil, iter.err
+	}
+
+	return iter, nil
+}
+
+// tableIterator iterates over the pairs of a table in a range, reading one block at a time so that
+// a scan holds no more of the table in memory than the block it is in.
+type tableIterator struct {
+	table       Table
+	sparseIndex []sparseIndexEntry
+	next        int
+	block       []byte
+
+	start, limit []byte
+	done         bool
+
+	// previous is the last key decoded, since a corrupted table may hold keys out of order, which
+	// would break the ordering every Iterator promises.
+	previous []byte
+
+	key, value []byte
+	expiry     int64
+	err        error
+}
+
+// advance moves the iterator to the next live pair in its range. It returns false, leaving the
+// iterator on its current pair, if there is none or the table is corrupted.
+func (iter *tableIterator) advance() bool {
+	if iter.done {
+		return false
+	}
+
+	// A block of a memory-mapped table is a slice of the mapping, which is gone once it is closed.
+	iter.err = iter.table.mapped.acquire()
+	if iter.err != nil {
+		iter.done = true
+		return false
+	}
+	defer iter.table.mapped.release()
+
+	for !iter.done {
+		if len(iter.block) == 0 {
+			if iter.next >= len(iter.sparseIndex) {
+				iter.done = true
+				break
+			}
+
+			offsetStart, offsetEnd := iter.table.blockOffsets(iter.sparseIndex, iter.next)
+			iter.next++
+
+			iter.block, iter.err = iter.table.readBlock(offsetStart, offsetEnd)
+			if iter.err != nil {
+				iter.done = true
+				break
+			}
+
+			continue
+		}
+
+		key, value, expiry, n, err := decodeEntry(iter.block, iter.table.version)
+		if err != nil {
+			iter.err = err
+			iter.done = true
+			break
+		}
+
+		iter.block = iter.block[n:]
+
+		if iter.previous != nil && string(key) <= string(iter.previous) {
+			iter.err = fmt.Errorf("corrupted block: key %q after %q: %w", key, iter.previous, CorruptionError)
+			iter.done = true
+			break
+		}
+
+		iter.previous = key
+
+		if string(key) < string(iter.start) {
+			continue
+		}
+
+		if len(iter.limit) > 0 && string(key) >= string(iter.limit) {
+			iter.done = true
+			break
+		}
+
+		if base.IsExpired(iter.table.clock, expiry) {
+			continue
+		}
+
+		iter.key, iter.value, iter.expiry = iter.table.own(key), iter.table.own(value), expiry
+		return true
+	}
+
+	return false
+}
+
+func (iter *tableIterator) Next() bool {
+	if iter.key == nil {
+		return false
+	}
+
+	return iter.advance()
+}
+
+func (iter *tableIterator) Error() error {
+	return iter.err
+}
+
+func (iter *tableIterator) Key() []byte {
+	return iter.key
+}
+
+func (iter *tableIterator) Value() []byte {
+	return iter.value
+}
+
+// Expiry returns when the current pair expires, so that a table can be flushed again with expiries
+// intact.
+func (iter *tableIterator) Expiry() int64 {
+	return iter.expiry
+}
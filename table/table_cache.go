@@ -0,0 +1,215 @@
+package table
+
+import (
+	"container/list"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/savarin/levels/internal/base"
+)
+
+const (
+	defaultMaxOpenTables = 1000
+)
+
+// File is a file holding an SSTable.
+type File interface {
+	base.ReaderSeeker
+	io.Closer
+}
+
+// Cache opens Tables on demand and keeps at most a fixed number of them open, closing the
+// least recently used one when another needs to be opened.
+type Cache struct {
+	mu       sync.Mutex
+	capacity int
+	open     func(name string) (File, error)
+	opts     Options
+	entries  map[string]*list.Element
+	lru      *list.List
+
+	// loading holds the tables being opened, which is done without the lock held.
+	loading map[string]*load
+}
+
+// load is a table being opened by one caller of Acquire, which others acquiring it wait for.
+type load struct {
+	done    chan struct{}
+	err     error
+	evicted bool
+}
+
+// Handle is a reference to an open Table in a Cache. The Table, and any Iterator created
+// from it, stays usable until the handle is released, even if the cache evicts it in the meantime.
+type Handle struct {
+	cache *Cache
+	name  string
+	file  File
+	table *Table
+	refs  int
+}
+
+// NewCache returns a Cache keeping at most capacity tables open. Tables are opened by
+// passing their name to open, which defaults to os.Open, and read with opts.
+func NewCache(capacity int, open func(name string) (File, error), opts Options) *Cache {
+	if capacity <= 0 {
+		capacity = defaultMaxOpenTables
+	}
+
+	if open == nil {
+		open = func(name string) (File, error) { return os.Open(name) }
+	}
+
+	return &Cache{
+		capacity: capacity,
+		open:     open,
+		opts:     opts,
+		entries:  make(map[string]*list.Element),
+		lru:      list.New(),
+		loading:  make(map[string]*load),
+	}
+}
+
+// Acquire returns a handle to the named table, opening it if it is not already open. The handle
+// must be released once the caller is done with the table.
+//
+// Tables are opened without the cache locked, so that opening one does not hold up acquiring
+// others. Callers acquiring a table while another opens it wait for it rather than open it again.
+func (c *Cache) Acquire(name string) (*Handle, error) {
+	c.mu.Lock()
+
+	for {
+		if e, ok := c.entries[name]; ok {
+			c.lru.MoveToFront(e)
+			h := e.Value.(*Handle)
+			h.refs++
+			c.mu.Unlock()
+			return h, nil
+		}
+
+		l, ok := c.loading[name]
+		if !ok {
+			break
+		}
+
+		c.mu.Unlock()
+		<-l.done
+
+		if l.err != nil {
+			return nil, l.err
+		}
+
+		// The table may have been evicted again by the time this caller looks it up, in which
+		// case it is opened afresh.
+		c.mu.Lock()
+	}
+
+	l := &load{done: make(chan struct{})}
+	c.loading[name] = l
+	c.mu.Unlock()
+
+	h, err := c.openHandle(name)
+
+	c.mu.Lock()
+	delete(c.loading, name)
+	l.err = err
+
+	// A table evicted while it was being opened is only kept for as long as this caller holds it.
+	if err == nil && !l.evicted {
+		// One reference belongs to the cache for as long as the table stays resident.
+		h.refs++
+		c.entries[name] = c.lru.PushFront(h)
+
+		for c.lru.Len() > c.capacity {
+			c.evictLocked(c.lru.Back())
+		}
+	}
+
+	c.mu.Unlock()
+	close(l.done)
+
+	return h, err
+}
+
+// openHandle opens the named table, returning a handle referenced only by the caller.
+func (c *Cache) openHandle(name string) (*Handle, error) {
+	f, err := c.open(name)
+	if err != nil {
+		return nil, fmt.Errorf("opening table %s: %w", name, err)
+	}
+
+	table, err := openTable(f, c.opts)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("reading table %s: %w", name, err)
+	}
+
+	return &Handle{cache: c, name: name, file: f, table: table, refs: 1}, nil
+}
+
+// Evict drops the named table from the cache, closing it once every handle to it is released.
+func (c *Cache) Evict(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[name]; ok {
+		c.evictLocked(e)
+	}
+
+	if l, ok := c.loading[name]; ok {
+		l.evicted = true
+	}
+}
+
+func (c *Cache) evictLocked(e *list.Element) {
+	h := e.Value.(*Handle)
+	c.lru.Remove(e)
+	delete(c.entries, h.name)
+	h.releaseLocked()
+}
+
+// Len returns the number of tables currently open in the cache.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.lru.Len()
+}
+
+// Close evicts every table. Tables with outstanding handles are closed when they are released.
+func (c *Cache) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for c.lru.Len() > 0 {
+		c.evictLocked(c.lru.Back())
+	}
+
+	for _, l := range c.loading {
+		l.evicted = true
+	}
+}
+
+// Table returns the table the handle refers to.
+func (h *Handle) Table() *Table {
+	return h.table
+}
+
+// Release gives up the handle. The handle and its table must not be used afterwards.
+func (h *Handle) Release() {
+	h.cache.mu.Lock()
+	defer h.cache.mu.Unlock()
+
+	h.releaseLocked()
+}
+
+func (h *Handle) releaseLocked() {
+	h.refs--
+
+	if h.refs == 0 {
+		h.table.Close()
+		h.file.Close()
+	}
+}
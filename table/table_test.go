@@ -0,0 +1,585 @@
+package table
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/savarin/levels/internal/base"
+)
+
+type entry struct {
+	Key   []byte
+	Value []byte
+}
+
+var (
+	A = entry{Key: []byte("a"), Value: []byte("alpha")}
+	B = entry{Key: []byte("b"), Value: []byte("bravo")}
+	C = entry{Key: []byte("c"), Value: []byte("charlie")}
+)
+
+type manualClock struct {
+	now time.Time
+}
+
+func (c *manualClock) Now() time.Time {
+	return c.now
+}
+
+// buildTable writes a table to w holding n keys formatted with format, such as "key%05d", each
+// with a value formatted the same way after "value".
+func buildTable(tb testing.TB, w io.Writer, n int, format string) {
+	builder := NewBuilder(w, BuilderOptions{})
+
+	for i := 0; i < n; i++ {
+		err := builder.Add([]byte(fmt.Sprintf(format, i)), []byte(fmt.Sprintf("value"+format[3:], i)))
+		if err != nil {
+			tb.Fatalf("unexpected error when adding: %s", err)
+		}
+	}
+
+	err := builder.Finish()
+	if err != nil {
+		tb.Fatalf("unexpected error when finishing: %s", err)
+	}
+}
+
+func flushTable(t *testing.T, n int) []byte {
+	var buf bytes.Buffer
+	buildTable(t, &buf, n, "key%05d")
+	return buf.Bytes()
+}
+
+func TestBlockCache(t *testing.T) {
+	data := flushTable(t, 1000)
+	cache := NewBlockCache(1 << 20)
+
+	table, err := OpenWithOptions(bytes.NewReader(data), Options{BlockCache: cache, CacheIndex: true, PinIndex: true})
+	if err != nil {
+		t.Fatalf("unexpected error when opening table: %s", err)
+	}
+
+	for round := 0; round < 2; round++ {
+		for _, i := range []int{0, 500, 999} {
+			key := fmt.Sprintf("key%05d", i)
+
+			v, err := table.Get([]byte(key))
+			if err != nil || string(v) != fmt.Sprintf("value%05d", i) {
+				t.Fatalf("unexpected value %q for key %q: %v", v, key, err)
+			}
+		}
+	}
+
+	// The index misses once when the table is opened and each block misses on its first read.
+	stats := cache.Stats()
+	if stats.Misses != 1+3 || stats.Hits != 6+3 {
+		t.Fatalf("expected 4 misses and 9 hits, got %+v", stats)
+	}
+
+	if stats.Pinned == 0 {
+		t.Fatalf("expected index to be pinned, got %+v", stats)
+	}
+
+	small := NewBlockCache(blockCacheShards * defaultBlockSize)
+
+	table, err = OpenWithOptions(bytes.NewReader(data), Options{BlockCache: small})
+	if err != nil {
+		t.Fatalf("unexpected error when opening table: %s", err)
+	}
+
+	iter, err := table.RangeScan([]byte{}, []byte{})
+	if err != nil {
+		t.Fatalf("unexpected error when scanning: %s", err)
+	}
+
+	for i := 0; i < 1000; i++ {
+		if string(iter.Key()) != fmt.Sprintf("key%05d", i) {
+			t.Fatalf("expected key%05d got %q", i, iter.Key())
+		}
+
+		iter.Next()
+	}
+
+	if stats := small.Stats(); stats.Size > stats.Capacity {
+		t.Fatalf("expected cache to stay within capacity, got %+v", stats)
+	}
+}
+
+type closeTracker struct {
+	*bytes.Reader
+	closed bool
+}
+
+func (c *closeTracker) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestTableCache(t *testing.T) {
+	data := flushTable(t, 10)
+	files := make(map[string]*closeTracker)
+
+	cache := NewCache(2, func(name string) (File, error) {
+		f := &closeTracker{Reader: bytes.NewReader(data)}
+		files[name] = f
+		return f, nil
+	}, Options{})
+
+	held, err := cache.Acquire("a")
+	if err != nil {
+		t.Fatalf("unexpected error when acquiring table: %s", err)
+	}
+
+	for _, name := range []string{"b", "c"} {
+		h, err := cache.Acquire(name)
+		if err != nil {
+			t.Fatalf("unexpected error when acquiring table: %s", err)
+		}
+
+		h.Release()
+	}
+
+	if cache.Len() != 2 {
+		t.Fatalf("expected 2 open tables got %d", cache.Len())
+	}
+
+	if files["a"].closed {
+		t.Fatalf("expected evicted table with an outstanding handle to stay open")
+	}
+
+	v, err := held.Table().Get([]byte("key00009"))
+	if err != nil || string(v) != "value00009" {
+		t.Fatalf("unexpected value %q from evicted table: %v", v, err)
+	}
+
+	held.Release()
+
+	if !files["a"].closed || files["b"].closed || files["c"].closed {
+		t.Fatalf("expected only the evicted table to be closed")
+	}
+
+	cache.Close()
+
+	if !files["b"].closed || !files["c"].closed {
+		t.Fatalf("expected closing the cache to close every table")
+	}
+}
+
+func TestTableCacheConcurrent(t *testing.T) {
+	data := flushTable(t, 10)
+	opening := make(chan struct{}, 4)
+	unblock := make(chan struct{})
+
+	var mu sync.Mutex
+	opens := make(map[string]int)
+
+	cache := NewCache(2, func(name string) (File, error) {
+		mu.Lock()
+		opens[name]++
+		mu.Unlock()
+
+		if name == "slow" {
+			opening <- struct{}{}
+			<-unblock
+		}
+
+		return &closeTracker{Reader: bytes.NewReader(data)}, nil
+	}, Options{})
+	defer cache.Close()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 4)
+
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			h, err := cache.Acquire("slow")
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			h.Release()
+		}()
+	}
+
+	<-opening
+
+	// Another table can be acquired while the slow one is being opened.
+	h, err := cache.Acquire("fast")
+	if err != nil {
+		t.Fatalf("unexpected error when acquiring table: %s", err)
+	}
+
+	h.Release()
+	close(unblock)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Fatalf("unexpected error when acquiring table: %s", err)
+	}
+
+	if opens["slow"] != 1 {
+		t.Fatalf("expected table acquired concurrently to be opened once, got %d", opens["slow"])
+	}
+}
+
+func TestTableBuilder(t *testing.T) {
+	var buf bytes.Buffer
+	builder := NewBuilder(&buf, BuilderOptions{BlockSize: 64})
+
+	for i := 0; i < 100; i++ {
+		err := builder.Add([]byte(fmt.Sprintf("key%05d", i)), []byte(fmt.Sprintf("value%05d", i)))
+		if err != nil {
+			t.Fatalf("unexpected error when adding: %s", err)
+		}
+	}
+
+	err := builder.Add([]byte("key00050"), []byte("late"))
+	if !errors.Is(err, OrderError) {
+		t.Fatalf("expected error when adding key out of order, got %v", err)
+	}
+
+	err = builder.Finish()
+	if err != nil {
+		t.Fatalf("unexpected error when finishing: %s", err)
+	}
+
+	if err = builder.Add([]byte("key99999"), nil); !errors.Is(err, BuilderClosedError) {
+		t.Fatalf("expected error when adding after finish, got %v", err)
+	}
+
+	table, err := openTable(bytes.NewReader(buf.Bytes()), Options{})
+	if err != nil {
+		t.Fatalf("unexpected error when opening table: %s", err)
+	}
+
+	if len(table.sparseIndex) < 100/4 {
+		t.Fatalf("expected small blocks, got %d index entries", len(table.sparseIndex))
+	}
+
+	for _, i := range []int{0, 50, 99} {
+		v, err := table.Get([]byte(fmt.Sprintf("key%05d", i)))
+		if err != nil || string(v) != fmt.Sprintf("value%05d", i) {
+			t.Fatalf("unexpected value %q for key %d: %v", v, i, err)
+		}
+	}
+}
+
+// legacyTable encodes the entries as a single block in the layout used before tables had a footer,
+// with 32-bit lengths and offsets and no expiry.
+func legacyTable(entries ...entry) []byte {
+	var buf []byte
+
+	for _, e := range entries {
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(len(e.Key)))
+		buf = append(buf, e.Key...)
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(len(e.Value)))
+		buf = append(buf, e.Value...)
+	}
+
+	indexOffset := uint32(len(buf))
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(entries[0].Key)))
+	buf = append(buf, entries[0].Key...)
+	buf = binary.LittleEndian.AppendUint32(buf, 0)
+
+	return binary.LittleEndian.AppendUint32(buf, indexOffset)
+}
+
+func TestTableFooter(t *testing.T) {
+	data := flushTable(t, 100)
+
+	table, err := openTable(bytes.NewReader(data), Options{})
+	if err != nil {
+		t.Fatalf("unexpected error when opening table: %s", err)
+	}
+
+	if table.FormatVersion() != formatVersion {
+		t.Fatalf("expected format version %d got %d", formatVersion, table.FormatVersion())
+	}
+
+	table, err = openTable(bytes.NewReader(legacyTable(A, B, C)), Options{})
+	if err != nil {
+		t.Fatalf("unexpected error when opening legacy table: %s", err)
+	}
+
+	if table.FormatVersion() != legacyFormatVersion {
+		t.Fatalf("expected legacy format version got %d", table.FormatVersion())
+	}
+
+	for _, e := range []entry{A, B, C} {
+		v, err := table.Get(e.Key)
+		if err != nil || string(v) != string(e.Value) {
+			t.Fatalf("unexpected value %q for key %q from legacy table: %v", v, e.Key, err)
+		}
+	}
+
+	future := append([]byte(nil), data...)
+	binary.LittleEndian.PutUint32(future[len(future)-12:], formatVersion+1)
+
+	_, err = Open(bytes.NewReader(future))
+	if !errors.Is(err, VersionError) {
+		t.Fatalf("expected error when opening table with unknown version, got %v", err)
+	}
+}
+
+// The golden file in testdata/baseline.sst was written by Flush before tables had a footer or keys
+// could expire, holding key00000 to key00499 in several blocks.
+func TestTableBaseline(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "baseline.sst"))
+	if err != nil {
+		t.Fatalf("unexpected error when reading golden file: %s", err)
+	}
+
+	table, err := openTable(bytes.NewReader(data), Options{})
+	if err != nil {
+		t.Fatalf("unexpected error when opening table: %s", err)
+	}
+
+	if table.FormatVersion() != legacyFormatVersion {
+		t.Fatalf("expected legacy format version got %d", table.FormatVersion())
+	}
+
+	for _, i := range []int{0, 1, 250, 498, 499} {
+		v, err := table.Get([]byte(fmt.Sprintf("key%05d", i)))
+		if err != nil || string(v) != fmt.Sprintf("value%05d", i) {
+			t.Fatalf("unexpected value %q for key %d: %v", v, i, err)
+		}
+	}
+
+	iter, err := table.RangeScan(nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error when scanning: %s", err)
+	}
+
+	i := 0
+	for key := iter.Key(); key != nil; key = iter.Key() {
+		if string(key) != fmt.Sprintf("key%05d", i) || string(iter.Value()) != fmt.Sprintf("value%05d", i) {
+			t.Fatalf("unexpected pair %q: %q at position %d", key, iter.Value(), i)
+		}
+
+		i++
+
+		if !iter.Next() {
+			break
+		}
+	}
+
+	if i != 500 {
+		t.Fatalf("expected 500 pairs, got %d", i)
+	}
+
+	result, err := Verify(bytes.NewReader(data))
+	if err != nil || !result.OK() || result.Entries != 500 {
+		t.Fatalf("expected golden file to verify, got %+v: %v", result, err)
+	}
+}
+
+func TestTableCorruption(t *testing.T) {
+	table, err := openTable(bytes.NewReader(legacyTable(B, A, C)), Options{})
+	if err != nil {
+		t.Fatalf("unexpected error when opening table: %s", err)
+	}
+
+	// Blocks are read as the scan reaches them, so the keys before the corruption are returned.
+	iter, err := table.RangeScan(nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error when scanning: %s", err)
+	}
+
+	if string(iter.Key()) != string(B.Key) || iter.Next() {
+		t.Fatalf("expected scan to stop after key %q, got %q", B.Key, iter.Key())
+	}
+
+	if !errors.Is(iter.Error(), CorruptionError) {
+		t.Fatalf("expected CorruptionError when scanning keys out of order, got %v", iter.Error())
+	}
+
+	_, err = table.RangeScan(C.Key, nil)
+	if !errors.Is(err, CorruptionError) {
+		t.Fatalf("expected CorruptionError when corruption precedes the first pair, got %v", err)
+	}
+
+	// A key length running past the end of the block.
+	data := legacyTable(A)
+	binary.LittleEndian.PutUint32(data, math.MaxUint32)
+
+	table, err = openTable(bytes.NewReader(data), Options{})
+	if err != nil {
+		t.Fatalf("unexpected error when opening table: %s", err)
+	}
+
+	_, err = table.Get(A.Key)
+	if err == nil || errors.Is(err, base.KeyError) {
+		t.Fatalf("expected corruption error when reading key with bad length, got %v", err)
+	}
+}
+
+func TestTableProperties(t *testing.T) {
+	var buf bytes.Buffer
+	clock := &manualClock{now: time.Unix(1700000000, 0)}
+	// Deletions are marked by empty values here, as the table format leaves them to the caller.
+	isDeletion := func(value []byte) bool { return len(value) == 0 }
+	builder := NewBuilder(&buf, BuilderOptions{Clock: clock, IsDeletion: isDeletion})
+
+	for _, e := range []entry{A, B, C} {
+		err := builder.Add(e.Key, e.Value)
+		if err != nil {
+			t.Fatalf("unexpected error when adding: %s", err)
+		}
+	}
+
+	err := builder.Add([]byte("d"), nil)
+	if err != nil {
+		t.Fatalf("unexpected error when adding deletion: %s", err)
+	}
+
+	builder.SetUserProperty("app.owner", []byte("tests"))
+	builder.SetUserProperty(propNumEntries, []byte("ignored"))
+
+	err = builder.Finish()
+	if err != nil {
+		t.Fatalf("unexpected error when finishing: %s", err)
+	}
+
+	table, err := openTable(bytes.NewReader(buf.Bytes()), Options{})
+	if err != nil {
+		t.Fatalf("unexpected error when opening table: %s", err)
+	}
+
+	props, err := table.Properties()
+	if err != nil {
+		t.Fatalf("unexpected error when reading properties: %s", err)
+	}
+
+	if props.NumEntries != 4 || props.NumDeletions != 1 {
+		t.Fatalf("expected 4 entries and 1 deletion, got %d and %d", props.NumEntries, props.NumDeletions)
+	}
+
+	if string(props.SmallestKey) != string(A.Key) || string(props.LargestKey) != "d" {
+		t.Fatalf("unexpected key range %q to %q", props.SmallestKey, props.LargestKey)
+	}
+
+	rawValueSize := uint64(0)
+	for _, e := range []entry{A, B, C} {
+		rawValueSize += uint64(len(e.Value))
+	}
+
+	if props.RawValueSize != rawValueSize {
+		t.Fatalf("expected raw value size %d got %d", rawValueSize, props.RawValueSize)
+	}
+
+	if props.DataSize == 0 || props.IndexSize == 0 || props.DataSize+props.IndexSize > uint64(buf.Len()) {
+		t.Fatalf("unexpected data size %d and index size %d", props.DataSize, props.IndexSize)
+	}
+
+	if !props.CreationTime.Equal(clock.now) || props.FormatVersion != formatVersion {
+		t.Fatalf("unexpected creation time %s or format version %d", props.CreationTime, props.FormatVersion)
+	}
+
+	if string(props.User["app.owner"]) != "tests" || len(props.User) != 1 {
+		t.Fatalf("unexpected user properties %q", props.User)
+	}
+
+	legacy, err := openTable(bytes.NewReader(legacyTable(A, B, C)), Options{})
+	if err != nil {
+		t.Fatalf("unexpected error when opening legacy table: %s", err)
+	}
+
+	_, err = legacy.Properties()
+	if !errors.Is(err, base.KeyError) {
+		t.Fatalf("expected error when reading properties of legacy table, got %v", err)
+	}
+}
+
+func TestTableLargeOffsets(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping sparse file test in short mode")
+	}
+
+	const base = 5 << 30
+
+	path := filepath.Join(t.TempDir(), "large.sst")
+
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("unexpected error when creating table file: %s", err)
+	}
+	defer f.Close()
+
+	// Everything before base is a hole in the sparse file that no index entry refers to, so the
+	// entries, index and footer all sit beyond the reach of 32-bit offsets.
+	_, err = f.Seek(base, io.SeekStart)
+	if err != nil {
+		t.Fatalf("unexpected error when seeking: %s", err)
+	}
+
+	builder := NewBuilder(f, BuilderOptions{BlockSize: 64})
+	builder.writer.Offset = base
+
+	for i := 0; i < 100; i++ {
+		err := builder.Add([]byte(fmt.Sprintf("key%05d", i)), []byte(fmt.Sprintf("value%05d", i)))
+		if err != nil {
+			t.Fatalf("unexpected error when adding: %s", err)
+		}
+	}
+
+	err = builder.Finish()
+	if err != nil {
+		t.Fatalf("unexpected error when finishing: %s", err)
+	}
+
+	table, err := openTable(f, Options{})
+	if err != nil {
+		t.Fatalf("unexpected error when opening table: %s", err)
+	}
+
+	if table.sparseIndex[0].offset != base {
+		t.Fatalf("expected first block at offset %d got %d", uint64(base), table.sparseIndex[0].offset)
+	}
+
+	for _, i := range []int{0, 50, 99} {
+		v, err := table.Get([]byte(fmt.Sprintf("key%05d", i)))
+		if err != nil || string(v) != fmt.Sprintf("value%05d", i) {
+			t.Fatalf("unexpected value %q for key %d: %v", v, i, err)
+		}
+	}
+
+	iter, err := table.RangeScan([]byte("key00090"), []byte("key99999"))
+	if err != nil {
+		t.Fatalf("unexpected error when scanning: %s", err)
+	}
+
+	for i := 90; i < 100; i++ {
+		if string(iter.Key()) != fmt.Sprintf("key%05d", i) {
+			t.Fatalf("expected key%05d got %q", i, iter.Key())
+		}
+
+		iter.Next()
+	}
+}
+
+func TestTableSizeLimits(t *testing.T) {
+	var buf bytes.Buffer
+	builder := NewBuilder(&buf, BuilderOptions{})
+
+	err := builder.Add(make([]byte, base.MaxKeySize+1), nil)
+
+	var sizeErr *base.SizeError
+	if !errors.As(err, &sizeErr) || sizeErr.Field != "key" {
+		t.Fatalf("expected key size error, got %v", err)
+	}
+}
@@ -0,0 +1,369 @@
+package table
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/savarin/levels/internal/base"
+)
+
+var (
+	CorruptionError = errors.New("Corrupted table")
+)
+
+// verifyReadSize is the number of bytes Verify reads from the data at a time.
+const verifyReadSize = 64 << 10
+
+// Problem is a corruption found by Verify, at an offset in the table file.
+type Problem struct {
+	Offset uint64
+	Err    error
+}
+
+func (p Problem) Error() string {
+	return fmt.Sprintf("offset %d: %s", p.Offset, p.Err)
+}
+
+func (p Problem) Unwrap() error {
+	return p.Err
+}
+
+// VerifyResult describes what Verify or Repair found in a table.
+type VerifyResult struct {
+	Version uint32
+
+	// Blocks is the number of entries in the sparse index, and Entries the number of key/value
+	// pairs that could be read back in ascending order.
+	Blocks  int
+	Entries int
+
+	// LostBytes is the size of the damaged parts of the data that no pair could be read from, and
+	// Skipped the number of pairs that could be decoded but were out of order.
+	LostBytes uint64
+	Skipped   int
+
+	// Properties are the properties recorded when the table was written, or nil if they could not
+	// be read.
+	Properties *Properties
+
+	Problems []Problem
+}
+
+// OK reports whether no corruption was found.
+func (v *VerifyResult) OK() bool {
+	return len(v.Problems) == 0
+}
+
+// indexEntry is a sparse index entry along with its offset in the table file.
+type indexEntry struct {
+	sparseIndexEntry
+	position uint64
+}
+
+// verifier walks the data of a table entry by entry, using the sparse index to find where to
+// resume after a damaged entry.
+type verifier struct {
+	r       base.ReaderSeeker
+	version uint32
+	dataEnd uint64
+	index   []indexEntry
+
+	buf      []byte
+	bufStart uint64
+
+	result *VerifyResult
+}
+
+func (v *verifier) problem(offset uint64, format string, args ...any) {
+	err := fmt.Errorf(format+": %w", append(args, CorruptionError)...)
+	v.result.Problems = append(v.result.Problems, Problem{Offset: offset, Err: err})
+}
+
+// read returns up to n bytes of the data starting at offset, fewer if the data ends first.
+func (v *verifier) read(offset, n uint64) ([]byte, error) {
+	if n > v.dataEnd-offset {
+		n = v.dataEnd - offset
+	}
+
+	if offset >= v.bufStart && offset+n <= v.bufStart+uint64(len(v.buf)) {
+		return v.buf[offset-v.bufStart : offset-v.bufStart+n], nil
+	}
+
+	size := max(n, min(verifyReadSize, v.dataEnd-offset))
+
+	v.buf = make([]byte, size)
+	v.bufStart = offset
+
+	_, err := v.r.ReadAt(v.buf, int64(offset))
+	if err != nil {
+		v.buf = nil
+		return nil, fmt.Errorf("reading data at offset %d: %s", offset, err)
+	}
+
+	return v.buf[:n], nil
+}
+
+// entryAt decodes the entry at offset, reading no more of the table than the entry takes up.
+func (v *verifier) entryAt(offset uint64) (key, value []byte, expiry int64, n int, err error) {
+	size := uint64(0)
+
+	for i := 0; i < 2 && offset+size < v.dataEnd; i++ {
+		buf, err := v.read(offset+size, binary.MaxVarintLen64)
+		if err != nil {
+			return nil, nil, 0, 0, err
+		}
+
+		length, m := decodeLength(buf, v.version)
+		if m == 0 {
+			break
+		}
+
+		size += uint64(m) + length
+		if size > v.dataEnd-offset {
+			return nil, nil, 0, 0, fmt.Errorf("corrupted block: entry runs past end of data at %d", v.dataEnd)
+		}
+	}
+
+	buf, err := v.read(offset, size+uint64(expirySize(v.version)))
+	if err != nil {
+		return nil, nil, 0, 0, err
+	}
+
+	return decodeEntry(buf, v.version)
+}
+
+// readIndex decodes the sparse index between the given offsets, keeping the entries before any
+// corruption and dropping those that are out of order.
+func (v *verifier) readIndex(start, end uint64) {
+	buf := make([]byte, end-start)
+	_, err := v.r.ReadAt(buf, int64(start))
+	if err != nil {
+		v.problem(start, "reading index: %s", err)
+		return
+	}
+
+	for position := start; position < end; {
+		entry := buf[position-start:]
+
+		keyLength, n := decodeLength(entry, v.version)
+		if n == 0 || keyLength > uint64(len(entry)-n) {
+			v.problem(position, "end of index while reading key")
+			return
+		}
+
+		key := entry[n : n+int(keyLength)]
+
+		offset, m := decodeLength(entry[n+int(keyLength):], v.version)
+		if m == 0 {
+			v.problem(position, "end of index while reading offset for key %q", key)
+			return
+		}
+
+		e := indexEntry{
+			sparseIndexEntry: sparseIndexEntry{key: append([]byte(nil), key...), offset: offset},
+			position:         position,
+		}
+
+		position += uint64(n) + keyLength + uint64(m)
+
+		if offset >= v.dataEnd {
+			v.problem(e.position, "index entry for key %q points to offset %d beyond data ending at %d", key, offset, v.dataEnd)
+			continue
+		}
+
+		if len(v.index) == 0 && offset != 0 {
+			v.problem(e.position, "first index entry points to offset %d rather than the start of the data", offset)
+		}
+
+		if len(v.index) > 0 {
+			last := v.index[len(v.index)-1]
+
+			if offset <= last.offset || string(key) <= string(last.key) {
+				v.problem(e.position, "index entry for key %q at offset %d out of order after key %q at offset %d", key, offset, last.key, last.offset)
+				continue
+			}
+		}
+
+		v.index = append(v.index, e)
+	}
+}
+
+// walk reads every entry of the data in order, calling visit with each pair read after the
+// previous one. After a damaged entry it resumes at the next block in the sparse index, or stops
+// if there is none.
+func (v *verifier) walk(visit func(key, value []byte, expiry int64) error) error {
+	var lastKey []byte
+	next := 0
+
+	for offset := uint64(0); offset < v.dataEnd; {
+		for next < len(v.index) && v.index[next].offset < offset {
+			e := v.index[next]
+			v.problem(e.position, "index entry for key %q points to offset %d inside an entry", e.key, e.offset)
+			next++
+		}
+
+		key, value, expiry, n, err := v.entryAt(offset)
+		if err != nil {
+			v.problem(offset, "%s", err)
+
+			for next < len(v.index) && v.index[next].offset <= offset {
+				next++
+			}
+
+			resume := v.dataEnd
+			if next < len(v.index) {
+				resume = v.index[next].offset
+			}
+
+			v.result.LostBytes += resume - offset
+			offset = resume
+			continue
+		}
+
+		if next < len(v.index) && v.index[next].offset == offset {
+			e := v.index[next]
+			if string(e.key) != string(key) {
+				v.problem(e.position, "index entry for key %q points to key %q at offset %d", e.key, key, offset)
+			}
+
+			next++
+		}
+
+		if v.result.Entries > 0 && string(key) <= string(lastKey) {
+			v.problem(offset, "key %q out of order after %q", key, lastKey)
+			v.result.Skipped++
+		} else {
+			if visit != nil {
+				err = visit(key, value, expiry)
+				if err != nil {
+					return err
+				}
+			}
+
+			lastKey = append(lastKey[:0], key...)
+			v.result.Entries++
+		}
+
+		offset += uint64(n)
+	}
+
+	return nil
+}
+
+// verifyTable checks the footer, index and metaindex of the table in r, then walks its data,
+// calling visit with every readable pair in order.
+func verifyTable(r base.ReaderSeeker, visit func(key, value []byte, expiry int64) error) (*VerifyResult, error) {
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, fmt.Errorf("seeking to end of file: %s", err)
+	}
+
+	v := &verifier{r: r, result: &VerifyResult{}}
+
+	f, err := readFooter(r)
+	if errors.Is(err, VersionError) {
+		return nil, err
+	}
+
+	if err != nil {
+		// Without a footer, all that is known is that the data starts the file. Walking it stops
+		// at the first entry that cannot be decoded, which is at the latest where the index begins.
+		v.problem(uint64(max(size-footerSize, 0)), "%s", err)
+		v.version = formatVersion
+		v.dataEnd = uint64(max(size-footerSize, 0))
+	} else {
+		v.version = f.version
+		v.dataEnd = f.index.offset
+		v.readIndex(f.index.offset, f.index.end())
+	}
+
+	v.result.Version = v.version
+	v.result.Blocks = len(v.index)
+
+	if err == nil && f.metaindex.size > 0 {
+		v.result.Properties = v.readProperties(f)
+	}
+
+	err = v.walk(visit)
+	if err != nil {
+		return nil, err
+	}
+
+	p := v.result.Properties
+	if p != nil && p.NumEntries != uint64(v.result.Entries) {
+		v.problem(f.metaindex.offset, "read %d entries, properties record %d", v.result.Entries, p.NumEntries)
+	}
+
+	return v.result, nil
+}
+
+// readProperties returns the properties of the table, or nil if the metaindex or properties block
+// is damaged.
+func (v *verifier) readProperties(f footer) *Properties {
+	buf := make([]byte, f.metaindex.size)
+	_, err := v.r.ReadAt(buf, int64(f.metaindex.offset))
+	if err != nil {
+		v.problem(f.metaindex.offset, "reading metaindex: %s", err)
+		return nil
+	}
+
+	metaindex, err := decodeMetaindex(buf)
+	if err != nil {
+		v.problem(f.metaindex.offset, "%s", err)
+		return nil
+	}
+
+	h, ok := metaindex[propertiesBlockName]
+	if !ok {
+		return nil
+	}
+
+	if h.offset < f.index.end() || h.offset > f.metaindex.offset || h.size > f.metaindex.offset-h.offset {
+		v.problem(f.metaindex.offset, "properties block at %d+%d outside the table's metadata", h.offset, h.size)
+		return nil
+	}
+
+	buf = make([]byte, h.size)
+	_, err = v.r.ReadAt(buf, int64(h.offset))
+	if err != nil {
+		v.problem(h.offset, "reading properties: %s", err)
+		return nil
+	}
+
+	p, err := decodeProperties(buf)
+	if err != nil {
+		v.problem(h.offset, "%s", err)
+		return nil
+	}
+
+	return p
+}
+
+// Verify walks every entry of the table in r and every entry of its sparse index, reporting each
+// corruption found along with its offset. An error is only returned if the table cannot be read
+// at all.
+func Verify(r base.ReaderSeeker) (*VerifyResult, error) {
+	return verifyTable(r, nil)
+}
+
+// Repair writes every pair that can be read from the damaged table in r into a new table written
+// to w, keeping their expiries, and reports what was lost. Properties of the new table are
+// computed afresh, using the collectors in opts.
+func Repair(r base.ReaderSeeker, w io.Writer, opts BuilderOptions) (*VerifyResult, error) {
+	b := NewBuilder(w, opts)
+
+	result, err := verifyTable(r, b.AddWithExpiry)
+	if err != nil {
+		b.Abandon()
+		return nil, err
+	}
+
+	err = b.Finish()
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,94 @@
+package table
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/savarin/levels/internal/base"
+)
+
+// entrySize is the size of each entry written by flushTable: a one-byte length before both key00000
+// and value00000, followed by an 8-byte expiry.
+const entrySize uint64 = 1 + 8 + 1 + 10 + 8
+
+func TestVerify(t *testing.T) {
+	data := flushTable(t, 1000)
+
+	result, err := Verify(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("unexpected error when verifying: %s", err)
+	}
+
+	if !result.OK() || result.Entries != 1000 || result.Blocks < 2 || result.Properties == nil {
+		t.Fatalf("unexpected result when verifying intact table %+v", result)
+	}
+
+	// A key length running past the end of the data loses the rest of its block.
+	corrupted := append([]byte(nil), data...)
+	binary.PutUvarint(corrupted[500*entrySize:], 1<<40)
+
+	result, err = Verify(bytes.NewReader(corrupted))
+	if err != nil {
+		t.Fatalf("unexpected error when verifying: %s", err)
+	}
+
+	if result.OK() || result.Problems[0].Offset != 500*entrySize || !errors.Is(result.Problems[0], CorruptionError) {
+		t.Fatalf("expected corruption at offset %d, got %v", 500*entrySize, result.Problems)
+	}
+
+	if result.Entries >= 1000 || result.Entries < 1000-2*defaultBlockSize/int(entrySize) || result.LostBytes == 0 {
+		t.Fatalf("unexpected result when verifying damaged table %+v", result)
+	}
+
+	// Without the sparse index, every entry is still found by walking the data.
+	corrupted = append([]byte(nil), data...)
+	indexOffset := result.Properties.DataSize
+	binary.PutUvarint(corrupted[indexOffset:], 1<<40)
+
+	result, err = Verify(bytes.NewReader(corrupted))
+	if err != nil {
+		t.Fatalf("unexpected error when verifying: %s", err)
+	}
+
+	if result.OK() || result.Problems[0].Offset != indexOffset || result.Entries != 1000 {
+		t.Fatalf("unexpected result when verifying table with damaged index %+v", result)
+	}
+}
+
+func TestRepair(t *testing.T) {
+	corrupted := flushTable(t, 1000)
+	binary.PutUvarint(corrupted[500*entrySize:], 1<<40)
+
+	var buf bytes.Buffer
+	result, err := Repair(bytes.NewReader(corrupted), &buf, BuilderOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error when repairing: %s", err)
+	}
+
+	verified, err := Verify(bytes.NewReader(buf.Bytes()))
+	if err != nil || !verified.OK() || verified.Entries != result.Entries {
+		t.Fatalf("unexpected result when verifying repaired table %+v: %v", verified, err)
+	}
+
+	repaired, err := Open(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("unexpected error when opening repaired table: %s", err)
+	}
+
+	for _, i := range []int{0, 499, 999} {
+		key := []byte(fmt.Sprintf("key%05d", i))
+
+		v, err := repaired.Get(key)
+		if err != nil || string(v) != fmt.Sprintf("value%05d", i) {
+			t.Fatalf("unexpected value %q for key %q: %v", v, key, err)
+		}
+	}
+
+	_, err = repaired.Get([]byte("key00500"))
+	if !errors.Is(err, base.KeyError) {
+		t.Fatalf("expected damaged key to be lost, got %v", err)
+	}
+}
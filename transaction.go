@@ -0,0 +1,430 @@
+package levels
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/savarin/levels/internal/base"
+	"github.com/savarin/levels/iterator"
+)
+
+var (
+	TransactionDoneError = errors.New("Transaction already committed or rolled back")
+)
+
+// ConflictError is returned by Transaction.Commit when a key the transaction read, or a key within
+// a range it scanned, was modified after the transaction began. The transaction has been rolled
+// back and can be retried from the start.
+type ConflictError struct {
+	Key []byte
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("transaction conflict on key %q", e.Key)
+}
+
+type keyRange struct {
+	start, limit []byte
+}
+
+func (r keyRange) contains(key string) bool {
+	return key >= string(r.start) && (len(r.limit) == 0 || key < string(r.limit))
+}
+
+type pendingWrite struct {
+	value   []byte
+	deleted bool
+}
+
+// TransactionDB wraps a DB to support optimistic transactions. Every write made through the
+// TransactionDB, whether by a committed transaction or directly, is assigned a sequence number
+// that Commit uses to detect conflicts. Writes made to the underlying DB directly go unnoticed.
+type TransactionDB struct {
+	mu       sync.Mutex
+	db       DB
+	sequence uint64
+	modified map[string]uint64
+
+	// snapshots counts the running transactions by the sequence number they began at.
+	snapshots map[uint64]int
+}
+
+func NewTransactionDB(db DB) *TransactionDB {
+	return &TransactionDB{
+		db:        db,
+		modified:  make(map[string]uint64),
+		snapshots: make(map[uint64]int),
+	}
+}
+
+func (tdb *TransactionDB) Get(key []byte) (value []byte, err error) {
+	tdb.mu.Lock()
+	defer tdb.mu.Unlock()
+
+	return tdb.db.Get(key)
+}
+
+func (tdb *TransactionDB) Has(key []byte) (ret bool, err error) {
+	tdb.mu.Lock()
+	defer tdb.mu.Unlock()
+
+	return tdb.db.Has(key)
+}
+
+func (tdb *TransactionDB) Put(key, value []byte) error {
+	tdb.mu.Lock()
+	defer tdb.mu.Unlock()
+
+	err := tdb.db.Put(key, value)
+	if err != nil {
+		return err
+	}
+
+	tdb.record(key)
+	return nil
+}
+
+func (tdb *TransactionDB) PutWithTTL(key, value []byte, ttl time.Duration) error {
+	tdb.mu.Lock()
+	defer tdb.mu.Unlock()
+
+	err := tdb.db.PutWithTTL(key, value, ttl)
+	if err != nil {
+		return err
+	}
+
+	tdb.record(key)
+	return nil
+}
+
+func (tdb *TransactionDB) Delete(key []byte) error {
+	tdb.mu.Lock()
+	defer tdb.mu.Unlock()
+
+	err := tdb.db.Delete(key)
+	if err != nil {
+		return err
+	}
+
+	tdb.record(key)
+	return nil
+}
+
+func (tdb *TransactionDB) RangeScan(start, limit []byte) (Iterator, error) {
+	merged, err := tdb.scan(start, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return iteratorFromMap(merged), nil
+}
+
+func (tdb *TransactionDB) Flush(w io.Writer) error {
+	tdb.mu.Lock()
+	defer tdb.mu.Unlock()
+
+	return tdb.db.Flush(w)
+}
+
+// record assigns the next sequence number to a write of key. Sequence numbers are only needed
+// while transactions are running, since a transaction only checks writes made after it began.
+func (tdb *TransactionDB) record(key []byte) {
+	tdb.sequence++
+
+	if len(tdb.snapshots) > 0 {
+		tdb.modified[string(key)] = tdb.sequence
+	}
+}
+
+// finish ends a transaction that began at snapshot. Once the oldest running transaction finishes,
+// writes at or before the snapshot of every one still running can no longer conflict, so they are
+// forgotten.
+func (tdb *TransactionDB) finish(snapshot uint64) {
+	tdb.snapshots[snapshot]--
+
+	if tdb.snapshots[snapshot] > 0 {
+		return
+	}
+
+	delete(tdb.snapshots, snapshot)
+
+	oldest := tdb.sequence
+	for s := range tdb.snapshots {
+		if s < oldest {
+			oldest = s
+		}
+	}
+
+	if snapshot > oldest {
+		return
+	}
+
+	for key, sequence := range tdb.modified {
+		if sequence <= oldest {
+			delete(tdb.modified, key)
+		}
+	}
+}
+
+// Begin starts a transaction. Its writes are buffered until Commit, and its reads see the latest
+// committed data merged with its own pending writes.
+func (tdb *TransactionDB) Begin() *Transaction {
+	tdb.mu.Lock()
+	defer tdb.mu.Unlock()
+
+	tdb.snapshots[tdb.sequence]++
+
+	return &Transaction{
+		tdb:      tdb,
+		snapshot: tdb.sequence,
+		writes:   make(map[string]pendingWrite),
+		reads:    make(map[string]struct{}),
+	}
+}
+
+// Transaction is an optimistic read-modify-write transaction over a TransactionDB. It takes no
+// locks; instead Commit fails with a ConflictError if anything the transaction read has changed.
+type Transaction struct {
+	tdb      *TransactionDB
+	snapshot uint64
+	writes   map[string]pendingWrite
+	reads    map[string]struct{}
+	ranges   []keyRange
+	done     bool
+}
+
+func (txn *Transaction) Get(key []byte) (value []byte, err error) {
+	if txn.done {
+		return nil, TransactionDoneError
+	}
+
+	if w, ok := txn.writes[string(key)]; ok {
+		if w.deleted {
+			return nil, KeyError
+		}
+
+		return w.value, nil
+	}
+
+	txn.reads[string(key)] = struct{}{}
+	return txn.tdb.Get(key)
+}
+
+func (txn *Transaction) Has(key []byte) (ret bool, err error) {
+	_, err = txn.Get(key)
+
+	if errors.Is(err, KeyError) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+func (txn *Transaction) Put(key, value []byte) error {
+	if txn.done {
+		return TransactionDoneError
+	}
+
+	txn.writes[string(key)] = pendingWrite{value: value}
+	return nil
+}
+
+// Delete deletes the value for the given key when the transaction commits. Like DB.Delete, it
+// returns KeyError if the key is not present.
+func (txn *Transaction) Delete(key []byte) error {
+	ok, err := txn.Has(key)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return KeyError
+	}
+
+	txn.writes[string(key)] = pendingWrite{deleted: true}
+	return nil
+}
+
+// RangeScan returns an Iterator over the committed key/value pairs in the given range overlaid
+// with the transaction's pending writes.
+func (txn *Transaction) RangeScan(start, limit []byte) (Iterator, error) {
+	if txn.done {
+		return nil, TransactionDoneError
+	}
+
+	merged, err := txn.tdb.scan(start, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	r := keyRange{start: start, limit: limit}
+	txn.ranges = append(txn.ranges, r)
+
+	overlayWrites(merged, txn.writes, r)
+	return iteratorFromMap(merged), nil
+}
+
+// scan returns the committed key/value pairs in the given range. The pairs are read with the lock
+// held, since walking the DB's Iterator would otherwise race with concurrent writes.
+func (tdb *TransactionDB) scan(start, limit []byte) (map[string][]byte, error) {
+	tdb.mu.Lock()
+	defer tdb.mu.Unlock()
+
+	iter, err := tdb.db.RangeScan(start, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	r := keyRange{start: start, limit: limit}
+	merged := make(map[string][]byte)
+
+	for key := iter.Key(); key != nil; key = iter.Key() {
+		if r.contains(string(key)) {
+			merged[string(key)] = iter.Value()
+		}
+
+		if !iter.Next() {
+			break
+		}
+	}
+
+	err = iter.Error()
+	if err != nil {
+		return nil, err
+	}
+
+	return merged, nil
+}
+
+// overlayWrites applies the pending writes that fall within r to the key/value pairs in merged.
+func overlayWrites(merged map[string][]byte, writes map[string]pendingWrite, r keyRange) {
+	for key, w := range writes {
+		if !r.contains(key) {
+			continue
+		}
+
+		if w.deleted {
+			delete(merged, key)
+		} else {
+			merged[key] = w.value
+		}
+	}
+}
+
+// iteratorFromMap returns an Iterator over the key/value pairs in m, ordered by key ascending.
+func iteratorFromMap(m map[string][]byte) *iterator.SimpleIterator {
+	sorted := make([]string, 0, len(m))
+	for k := range m {
+		sorted = append(sorted, k)
+	}
+
+	sort.Strings(sorted)
+
+	keys := make([][]byte, 0, len(sorted))
+	values := make([][]byte, 0, len(sorted))
+
+	for _, key := range sorted {
+		keys = append(keys, []byte(key))
+		values = append(values, m[key])
+	}
+
+	return iterator.New(keys, values)
+}
+
+// validate returns a ConflictError if a key read or scanned by the transaction was written after
+// the transaction began. It must be called with the TransactionDB lock held.
+func (txn *Transaction) validate() error {
+	for key := range txn.reads {
+		if txn.tdb.modified[key] > txn.snapshot {
+			return &ConflictError{Key: []byte(key)}
+		}
+	}
+
+	for key, sequence := range txn.tdb.modified {
+		if sequence <= txn.snapshot {
+			continue
+		}
+
+		for _, r := range txn.ranges {
+			if r.contains(key) {
+				return &ConflictError{Key: []byte(key)}
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkWrites returns an error if the DB would reject any of writes, so that a commit can fail
+// before applying the first of them rather than part-way through.
+func checkWrites(writes map[string]pendingWrite) error {
+	for key, w := range writes {
+		err := base.CheckSize([]byte(key), w.value)
+		if err != nil {
+			return fmt.Errorf("committing write of key %q: %w", key, err)
+		}
+	}
+
+	return nil
+}
+
+// Commit atomically applies the transaction's writes, or returns a ConflictError without applying
+// any of them if another writer modified data the transaction read.
+func (txn *Transaction) Commit() error {
+	if txn.done {
+		return TransactionDoneError
+	}
+
+	tdb := txn.tdb
+	tdb.mu.Lock()
+	defer tdb.mu.Unlock()
+
+	txn.done = true
+	defer tdb.finish(txn.snapshot)
+
+	err := txn.validate()
+	if err != nil {
+		return err
+	}
+
+	err = checkWrites(txn.writes)
+	if err != nil {
+		return err
+	}
+
+	for key, w := range txn.writes {
+		if w.deleted {
+			err = tdb.db.Delete([]byte(key))
+			if errors.Is(err, KeyError) {
+				err = nil
+			}
+		} else {
+			err = tdb.db.Put([]byte(key), w.value)
+		}
+
+		if err != nil {
+			return fmt.Errorf("committing write of key %q: %w", key, err)
+		}
+
+		tdb.record([]byte(key))
+	}
+
+	return nil
+}
+
+// Rollback discards the transaction's writes.
+func (txn *Transaction) Rollback() {
+	if txn.done {
+		return
+	}
+
+	txn.tdb.mu.Lock()
+	defer txn.tdb.mu.Unlock()
+
+	txn.done = true
+	txn.tdb.finish(txn.snapshot)
+}
@@ -0,0 +1,326 @@
+package levels
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/savarin/levels/memtable"
+)
+
+func TestTransactionConflict(t *testing.T) {
+	tdb := NewTransactionDB(memtable.NewSkipListDB())
+
+	err := tdb.Put(A.Key, A.Value)
+	if err != nil {
+		t.Fatalf("unexpected error when putting key %q: %s", A.Key, err)
+	}
+
+	first := tdb.Begin()
+	second := tdb.Begin()
+
+	for _, txn := range []*Transaction{first, second} {
+		v, err := txn.Get(A.Key)
+		if err != nil {
+			t.Fatalf("unexpected error when getting key %q: %s", A.Key, err)
+		}
+
+		err = txn.Put(A.Key, append(v, '!'))
+		if err != nil {
+			t.Fatalf("unexpected error when putting key %q: %s", A.Key, err)
+		}
+	}
+
+	v, err := first.Get(A.Key)
+	if err != nil || string(v) != "alpha!" {
+		t.Fatalf("expected transaction to read its own write, got %q: %v", v, err)
+	}
+
+	err = first.Commit()
+	if err != nil {
+		t.Fatalf("unexpected error when committing: %s", err)
+	}
+
+	err = second.Commit()
+
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) || string(conflict.Key) != string(A.Key) {
+		t.Fatalf("expected conflict on key %q, got %v", A.Key, err)
+	}
+
+	v, _ = tdb.Get(A.Key)
+	if string(v) != "alpha!" {
+		t.Fatalf("expected %q got %q", "alpha!", v)
+	}
+}
+
+func TestTransactionRangeConflict(t *testing.T) {
+	tdb := NewTransactionDB(memtable.NewSimpleDB())
+	txn := tdb.Begin()
+
+	iter, err := txn.RangeScan(A.Key, C.Key)
+	if err != nil {
+		t.Fatalf("unexpected error when scanning: %s", err)
+	}
+
+	if iter.Key() != nil {
+		t.Fatalf("expected empty scan got key %q", iter.Key())
+	}
+
+	err = tdb.Put(B.Key, B.Value)
+	if err != nil {
+		t.Fatalf("unexpected error when putting key %q: %s", B.Key, err)
+	}
+
+	err = txn.Put(C.Key, C.Value)
+	if err != nil {
+		t.Fatalf("unexpected error when putting key %q: %s", C.Key, err)
+	}
+
+	var conflict *ConflictError
+	if err = txn.Commit(); !errors.As(err, &conflict) {
+		t.Fatalf("expected conflict from phantom key %q, got %v", B.Key, err)
+	}
+
+	if ok, _ := tdb.Has(C.Key); ok {
+		t.Fatalf("expected conflicting transaction's write to key %q to be discarded", C.Key)
+	}
+}
+
+func TestTransactionCommitSize(t *testing.T) {
+	tdb := NewTransactionDB(memtable.NewSkipListDB())
+	txn := tdb.Begin()
+
+	for _, e := range []entry{A, B, C} {
+		err := txn.Put(e.Key, e.Value)
+		if err != nil {
+			t.Fatalf("unexpected error when putting key %q: %s", e.Key, err)
+		}
+	}
+
+	large := make([]byte, MaxKeySize+1)
+
+	err := txn.Put(large, A.Value)
+	if err != nil {
+		t.Fatalf("unexpected error when putting large key: %s", err)
+	}
+
+	var sizeErr *SizeError
+	if err = txn.Commit(); !errors.As(err, &sizeErr) {
+		t.Fatalf("expected SizeError when committing large key, got %v", err)
+	}
+
+	for _, e := range []entry{A, B, C} {
+		if ok, _ := tdb.Has(e.Key); ok {
+			t.Fatalf("expected failed commit to leave key %q unwritten", e.Key)
+		}
+	}
+}
+
+func TestTransactionModified(t *testing.T) {
+	tdb := NewTransactionDB(memtable.NewSimpleDB())
+	long := tdb.Begin()
+
+	for i := 0; i < 10; i++ {
+		txn := tdb.Begin()
+
+		err := txn.Put([]byte(strconv.Itoa(i)), A.Value)
+		if err != nil {
+			t.Fatalf("unexpected error when putting key: %s", err)
+		}
+
+		err = txn.Commit()
+		if err != nil {
+			t.Fatalf("unexpected error when committing: %s", err)
+		}
+	}
+
+	if len(tdb.modified) != 10 {
+		t.Fatalf("expected writes to be kept while an older transaction runs, got %d", len(tdb.modified))
+	}
+
+	// Once the oldest transaction finishes, writes before every running transaction are forgotten.
+	short := tdb.Begin()
+	long.Rollback()
+
+	if len(tdb.modified) != 0 {
+		t.Fatalf("expected writes before running transactions to be forgotten, got %d", len(tdb.modified))
+	}
+
+	err := tdb.Put(A.Key, B.Value)
+	if err != nil {
+		t.Fatalf("unexpected error when putting key %q: %s", A.Key, err)
+	}
+
+	if len(tdb.modified) != 1 {
+		t.Fatalf("expected write after running transaction to be kept, got %d", len(tdb.modified))
+	}
+
+	short.Rollback()
+}
+
+func TestTransactionConcurrentScan(t *testing.T) {
+	tdb := NewTransactionDB(memtable.NewSkipListDB())
+
+	for i := 0; i < 1000; i += 2 {
+		err := tdb.Put([]byte(strconv.Itoa(i)), A.Value)
+		if err != nil {
+			t.Fatalf("unexpected error when putting key: %s", err)
+		}
+	}
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		for i := 1; i < 1000; i += 2 {
+			_ = tdb.Put([]byte(strconv.Itoa(i)), A.Value)
+		}
+	}()
+
+	// Scans run until the writes finish, so that some walk the DB while it is being written.
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+
+		txn := tdb.Begin()
+
+		_, err := txn.RangeScan([]byte{}, []byte{})
+		if err != nil {
+			t.Fatalf("unexpected error when scanning: %s", err)
+		}
+
+		txn.Rollback()
+	}
+}
+
+func TestPessimisticTransactionDeadlock(t *testing.T) {
+	pdb := NewPessimisticTransactionDB(memtable.NewSkipListDB(), 5*time.Second)
+
+	first := pdb.Begin()
+	second := pdb.Begin()
+
+	if err := first.Put(A.Key, A.Value); err != nil {
+		t.Fatalf("unexpected error when putting key %q: %s", A.Key, err)
+	}
+
+	if err := second.Put(B.Key, B.Value); err != nil {
+		t.Fatalf("unexpected error when putting key %q: %s", B.Key, err)
+	}
+
+	done := make(chan error)
+	go func() {
+		_, err := first.Get(B.Key)
+		done <- err
+	}()
+
+	// Give the first transaction time to start waiting on the second.
+	time.Sleep(50 * time.Millisecond)
+
+	_, err := second.Get(A.Key)
+	if !errors.Is(err, DeadlockError) {
+		t.Fatalf("expected deadlock, got %v", err)
+	}
+
+	second.Rollback()
+
+	err = <-done
+	if !errors.Is(err, KeyError) {
+		t.Fatalf("expected first transaction to proceed after rollback, got %v", err)
+	}
+
+	if err = first.Commit(); err != nil {
+		t.Fatalf("unexpected error when committing: %s", err)
+	}
+
+	v, err := pdb.db.Get(A.Key)
+	if err != nil || string(v) != string(A.Value) {
+		t.Fatalf("expected %q got %q: %v", A.Value, v, err)
+	}
+}
+
+func TestPessimisticTransactionCommitSize(t *testing.T) {
+	pdb := NewPessimisticTransactionDB(memtable.NewSkipListDB(), time.Second)
+	txn := pdb.Begin()
+
+	for _, e := range []entry{A, B, C} {
+		err := txn.Put(e.Key, e.Value)
+		if err != nil {
+			t.Fatalf("unexpected error when putting key %q: %s", e.Key, err)
+		}
+	}
+
+	err := txn.Put(make([]byte, MaxKeySize+1), A.Value)
+	if err != nil {
+		t.Fatalf("unexpected error when putting large key: %s", err)
+	}
+
+	var sizeErr *SizeError
+	if err = txn.Commit(); !errors.As(err, &sizeErr) {
+		t.Fatalf("expected SizeError when committing large key, got %v", err)
+	}
+
+	for _, e := range []entry{A, B, C} {
+		if _, err := pdb.get(e.Key); !errors.Is(err, KeyError) {
+			t.Fatalf("expected failed commit to leave key %q unwritten", e.Key)
+		}
+	}
+}
+
+func TestPessimisticTransactionDeleteUncommitted(t *testing.T) {
+	pdb := NewPessimisticTransactionDB(memtable.NewSkipListDB(), time.Second)
+	txn := pdb.Begin()
+
+	for i := 0; i < 20; i++ {
+		err := txn.Put([]byte(strconv.Itoa(i)), A.Value)
+		if err != nil {
+			t.Fatalf("unexpected error when putting key: %s", err)
+		}
+	}
+
+	err := txn.Put(B.Key, B.Value)
+	if err != nil {
+		t.Fatalf("unexpected error when putting key %q: %s", B.Key, err)
+	}
+
+	// The key was only ever written by the transaction, so the DB has nothing to delete on commit.
+	err = txn.Delete(B.Key)
+	if err != nil {
+		t.Fatalf("unexpected error when deleting key %q: %s", B.Key, err)
+	}
+
+	err = txn.Commit()
+	if err != nil {
+		t.Fatalf("unexpected error when committing: %s", err)
+	}
+
+	for i := 0; i < 20; i++ {
+		if _, err := pdb.get([]byte(strconv.Itoa(i))); err != nil {
+			t.Fatalf("expected key %d to be committed, got %v", i, err)
+		}
+	}
+
+	if _, err := pdb.get(B.Key); !errors.Is(err, KeyError) {
+		t.Fatalf("expected deleted key %q to be missing, got %v", B.Key, err)
+	}
+}
+
+func TestPessimisticTransactionLockTimeout(t *testing.T) {
+	pdb := NewPessimisticTransactionDB(memtable.NewSimpleDB(), 10*time.Millisecond)
+
+	first := pdb.Begin()
+	second := pdb.Begin()
+
+	if _, err := first.Get(A.Key); !errors.Is(err, KeyError) {
+		t.Fatalf("expected missing key %q, got %v", A.Key, err)
+	}
+
+	if err := second.Put(A.Key, A.Value); !errors.Is(err, LockTimeoutError) {
+		t.Fatalf("expected lock timeout, got %v", err)
+	}
+}
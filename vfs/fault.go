@@ -0,0 +1,277 @@
+package vfs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+var (
+	InjectedError = errors.New("Injected fault")
+)
+
+// Op is a kind of operation on an FS or one of its files.
+type Op int
+
+const (
+	OpCreate Op = iota
+	OpOpen
+	OpRead
+	OpWrite
+	OpSync
+	OpTruncate
+	OpRename
+	OpRemove
+	OpMkdir
+	OpList
+	OpStat
+	OpSyncDir
+	OpLock
+)
+
+var opNames = []string{"create", "open", "read", "write", "sync", "truncate", "rename", "remove", "mkdir", "list", "stat", "syncdir", "lock"}
+
+func (op Op) String() string {
+	if op < 0 || int(op) >= len(opNames) {
+		return fmt.Sprintf("Op(%d)", int(op))
+	}
+
+	return opNames[op]
+}
+
+// Injector decides whether an operation on the named file or directory fails, returning the error
+// it fails with or nil to let it through. It is called before the operation is made, and only for
+// one operation at a time.
+type Injector func(op Op, name string) error
+
+// FailNth fails the n-th operation of the given kind, counting from one, with InjectedError.
+func FailNth(op Op, n int) Injector {
+	var count int
+
+	return func(o Op, name string) error {
+		if o != op {
+			return nil
+		}
+
+		count++
+		if count == n {
+			return fmt.Errorf("%s %s: %w", op, name, InjectedError)
+		}
+
+		return nil
+	}
+}
+
+// FailAfter lets n operations of any kind through and fails every one after them with
+// InjectedError, as if the process had crashed. Together with MemFS.CrashClone, it simulates a
+// crash at any point of a workload.
+func FailAfter(n int) Injector {
+	var count int
+
+	return func(op Op, name string) error {
+		count++
+		if count > n {
+			return fmt.Errorf("%s %s: %w", op, name, InjectedError)
+		}
+
+		return nil
+	}
+}
+
+// FaultFS is an FS that fails operations chosen by an Injector, and otherwise passes them to the
+// FS it wraps.
+type FaultFS struct {
+	fs FS
+
+	mu     sync.Mutex
+	inject Injector
+}
+
+// NewFaultFS returns a FaultFS wrapping fs. A nil inject lets every operation through.
+func NewFaultFS(fs FS, inject Injector) *FaultFS {
+	return &FaultFS{fs: fs, inject: inject}
+}
+
+// SetInjector replaces the Injector deciding which operations fail.
+func (f *FaultFS) SetInjector(inject Injector) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.inject = inject
+}
+
+func (f *FaultFS) maybeFail(op Op, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.inject == nil {
+		return nil
+	}
+
+	return f.inject(op, name)
+}
+
+func (f *FaultFS) wrap(file File, name string, err error) (File, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	return &faultFile{File: file, fs: f, name: name}, nil
+}
+
+func (f *FaultFS) Create(name string) (File, error) {
+	err := f.maybeFail(OpCreate, name)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := f.fs.Create(name)
+	return f.wrap(file, name, err)
+}
+
+func (f *FaultFS) Open(name string) (File, error) {
+	err := f.maybeFail(OpOpen, name)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := f.fs.Open(name)
+	return f.wrap(file, name, err)
+}
+
+func (f *FaultFS) OpenReadWrite(name string) (File, error) {
+	err := f.maybeFail(OpOpen, name)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := f.fs.OpenReadWrite(name)
+	return f.wrap(file, name, err)
+}
+
+func (f *FaultFS) Rename(oldname, newname string) error {
+	err := f.maybeFail(OpRename, oldname)
+	if err != nil {
+		return err
+	}
+
+	return f.fs.Rename(oldname, newname)
+}
+
+func (f *FaultFS) Remove(name string) error {
+	err := f.maybeFail(OpRemove, name)
+	if err != nil {
+		return err
+	}
+
+	return f.fs.Remove(name)
+}
+
+func (f *FaultFS) RemoveAll(name string) error {
+	err := f.maybeFail(OpRemove, name)
+	if err != nil {
+		return err
+	}
+
+	return f.fs.RemoveAll(name)
+}
+
+func (f *FaultFS) MkdirAll(dir string, perm os.FileMode) error {
+	err := f.maybeFail(OpMkdir, dir)
+	if err != nil {
+		return err
+	}
+
+	return f.fs.MkdirAll(dir, perm)
+}
+
+func (f *FaultFS) List(dir string) ([]string, error) {
+	err := f.maybeFail(OpList, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return f.fs.List(dir)
+}
+
+func (f *FaultFS) Stat(name string) (os.FileInfo, error) {
+	err := f.maybeFail(OpStat, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return f.fs.Stat(name)
+}
+
+func (f *FaultFS) SyncDir(dir string) error {
+	err := f.maybeFail(OpSyncDir, dir)
+	if err != nil {
+		return err
+	}
+
+	return f.fs.SyncDir(dir)
+}
+
+func (f *FaultFS) Lock(name string) (io.Closer, error) {
+	err := f.maybeFail(OpLock, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return f.fs.Lock(name)
+}
+
+// faultFile is an open file of a FaultFS. Seeking and closing never fail, so that a file can
+// always be released.
+type faultFile struct {
+	File
+	fs   *FaultFS
+	name string
+}
+
+func (f *faultFile) Read(p []byte) (int, error) {
+	err := f.fs.maybeFail(OpRead, f.name)
+	if err != nil {
+		return 0, err
+	}
+
+	return f.File.Read(p)
+}
+
+func (f *faultFile) ReadAt(p []byte, off int64) (int, error) {
+	err := f.fs.maybeFail(OpRead, f.name)
+	if err != nil {
+		return 0, err
+	}
+
+	return f.File.ReadAt(p, off)
+}
+
+func (f *faultFile) Write(p []byte) (int, error) {
+	err := f.fs.maybeFail(OpWrite, f.name)
+	if err != nil {
+		return 0, err
+	}
+
+	return f.File.Write(p)
+}
+
+func (f *faultFile) Truncate(size int64) error {
+	err := f.fs.maybeFail(OpTruncate, f.name)
+	if err != nil {
+		return err
+	}
+
+	return f.File.Truncate(size)
+}
+
+func (f *faultFile) Sync() error {
+	err := f.fs.maybeFail(OpSync, f.name)
+	if err != nil {
+		return err
+	}
+
+	return f.File.Sync()
+}
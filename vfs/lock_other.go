@@ -0,0 +1,13 @@
+//go:build !unix
+
+package vfs
+
+import (
+	"io"
+	"os"
+)
+
+// lockFile creates the named file but does not lock it, since locking is only supported on Unix.
+func lockFile(name string) (io.Closer, error) {
+	return os.OpenFile(name, os.O_RDWR|os.O_CREATE, 0644)
+}
@@ -0,0 +1,27 @@
+//go:build unix
+
+package vfs
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+)
+
+// lockFile holds an advisory lock on the named file through flock, which other processes and
+// other opens of the file within this process respect.
+func lockFile(name string) (io.Closer, error) {
+	f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("locking %s: %w", name, err)
+	}
+
+	return f, nil
+}
@@ -0,0 +1,550 @@
+package vfs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// memNode is a file or directory of a MemFS. Alongside its current state, it keeps the state last
+// made durable: the contents of a file as of its last Sync, and the entries of a directory as of
+// its last SyncDir.
+type memNode struct {
+	isDir   bool
+	modTime time.Time
+
+	data, synced []byte
+
+	children, syncedChildren map[string]*memNode
+}
+
+func newMemDir() *memNode {
+	return &memNode{
+		isDir:          true,
+		modTime:        time.Now(),
+		children:       make(map[string]*memNode),
+		syncedChildren: make(map[string]*memNode),
+	}
+}
+
+// MemFS is an FS held in memory. It is safe for concurrent use.
+//
+// A MemFS tracks which writes would survive a power loss, as a real filesystem would only
+// guarantee: the contents of a file once it is synced, and a file or directory being created,
+// renamed or removed once its directory is synced. CrashClone returns the filesystem holding only
+// those.
+type MemFS struct {
+	mu    sync.Mutex
+	root  *memNode
+	locks map[string]bool
+}
+
+// NewMem returns an empty MemFS.
+func NewMem() *MemFS {
+	return &MemFS{root: newMemDir(), locks: make(map[string]bool)}
+}
+
+// split returns the components of a cleaned path. Relative and absolute paths share one tree.
+func split(name string) []string {
+	name = filepath.ToSlash(filepath.Clean(name))
+	name = strings.Trim(name, "/")
+
+	if name == "" || name == "." {
+		return nil
+	}
+
+	return strings.Split(name, "/")
+}
+
+// walk returns the directory holding the named entry along with the entry's name within it.
+func (m *MemFS) walk(op, name string) (*memNode, string, error) {
+	parts := split(name)
+	if len(parts) == 0 {
+		return nil, "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	dir := m.root
+
+	for _, part := range parts[:len(parts)-1] {
+		child, ok := dir.children[part]
+		if !ok {
+			return nil, "", &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+
+		if !child.isDir {
+			return nil, "", &fs.PathError{Op: op, Path: name, Err: errors.New("not a directory")}
+		}
+
+		dir = child
+	}
+
+	return dir, parts[len(parts)-1], nil
+}
+
+// lookup returns the named file or directory.
+func (m *MemFS) lookup(op, name string) (*memNode, error) {
+	if len(split(name)) == 0 {
+		return m.root, nil
+	}
+
+	dir, base, err := m.walk(op, name)
+	if err != nil {
+		return nil, err
+	}
+
+	node, ok := dir.children[base]
+	if !ok {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+
+	return node, nil
+}
+
+// openFile opens the named file, creating it if create is set and truncating it if truncate is.
+func (m *MemFS) openFile(op, name string, create, truncate, readOnly bool) (File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	dir, base, err := m.walk(op, name)
+	if err != nil {
+		return nil, err
+	}
+
+	node, ok := dir.children[base]
+
+	switch {
+	case ok && node.isDir:
+		return nil, &fs.PathError{Op: op, Path: name, Err: errors.New("is a directory")}
+	case !ok && !create:
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	case !ok:
+		node = &memNode{modTime: time.Now()}
+		dir.children[base] = node
+	case truncate:
+		node.data = nil
+		node.modTime = time.Now()
+	}
+
+	return &memFile{fs: m, node: node, name: name, readOnly: readOnly}, nil
+}
+
+func (m *MemFS) Create(name string) (File, error) {
+	return m.openFile("create", name, true, true, false)
+}
+
+func (m *MemFS) Open(name string) (File, error) {
+	return m.openFile("open", name, false, false, true)
+}
+
+func (m *MemFS) OpenReadWrite(name string) (File, error) {
+	return m.openFile("open", name, true, false, false)
+}
+
+func (m *MemFS) Rename(oldname, newname string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	oldDir, oldBase, err := m.walk("rename", oldname)
+	if err != nil {
+		return err
+	}
+
+	node, ok := oldDir.children[oldBase]
+	if !ok {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: fs.ErrNotExist}
+	}
+
+	newDir, newBase, err := m.walk("rename", newname)
+	if err != nil {
+		return err
+	}
+
+	if existing, ok := newDir.children[newBase]; ok && existing.isDir != node.isDir {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: fs.ErrExist}
+	}
+
+	delete(oldDir.children, oldBase)
+	newDir.children[newBase] = node
+	return nil
+}
+
+func (m *MemFS) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	dir, base, err := m.walk("remove", name)
+	if err != nil {
+		return err
+	}
+
+	node, ok := dir.children[base]
+	if !ok {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+
+	if node.isDir && len(node.children) > 0 {
+		return &fs.PathError{Op: "remove", Path: name, Err: errors.New("directory not empty")}
+	}
+
+	delete(dir.children, base)
+	return nil
+}
+
+func (m *MemFS) RemoveAll(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	dir, base, err := m.walk("removeall", name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	delete(dir.children, base)
+	return nil
+}
+
+func (m *MemFS) MkdirAll(dir string, perm os.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	node := m.root
+
+	for _, part := range split(dir) {
+		child, ok := node.children[part]
+		if !ok {
+			child = newMemDir()
+			node.children[part] = child
+		}
+
+		if !child.isDir {
+			return &fs.PathError{Op: "mkdir", Path: dir, Err: errors.New("not a directory")}
+		}
+
+		node = child
+	}
+
+	return nil
+}
+
+func (m *MemFS) List(dir string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	node, err := m.lookup("readdir", dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if !node.isDir {
+		return nil, &fs.PathError{Op: "readdir", Path: dir, Err: errors.New("not a directory")}
+	}
+
+	names := make([]string, 0, len(node.children))
+	for name := range node.children {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+func (m *MemFS) Stat(name string) (os.FileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	node, err := m.lookup("stat", name)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := split(name)
+	base := "/"
+	if len(parts) > 0 {
+		base = parts[len(parts)-1]
+	}
+
+	return memFileInfo{name: base, size: int64(len(node.data)), isDir: node.isDir, modTime: node.modTime}, nil
+}
+
+func (m *MemFS) SyncDir(dir string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	node, err := m.lookup("sync", dir)
+	if err != nil {
+		return err
+	}
+
+	if !node.isDir {
+		return &fs.PathError{Op: "sync", Path: dir, Err: errors.New("not a directory")}
+	}
+
+	node.syncedChildren = make(map[string]*memNode, len(node.children))
+	for name, child := range node.children {
+		node.syncedChildren[name] = child
+	}
+
+	return nil
+}
+
+func (m *MemFS) Lock(name string) (io.Closer, error) {
+	f, err := m.OpenReadWrite(name)
+	if err != nil {
+		return nil, err
+	}
+
+	f.Close()
+
+	key := strings.Join(split(name), "/")
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.locks[key] {
+		return nil, fmt.Errorf("locking %s: already locked", name)
+	}
+
+	m.locks[key] = true
+	return &memLock{fs: m, key: key}, nil
+}
+
+// CrashClone returns a copy of the filesystem as it would be found after a power loss: holding
+// only the entries made durable by SyncDir, each file with only the contents made durable by
+// Sync. The copy holds no locks.
+func (m *MemFS) CrashClone() *MemFS {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return &MemFS{root: crashClone(m.root), locks: make(map[string]bool)}
+}
+
+func crashClone(node *memNode) *memNode {
+	if !node.isDir {
+		data := append([]byte(nil), node.synced...)
+		return &memNode{modTime: node.modTime, data: data, synced: data}
+	}
+
+	clone := newMemDir()
+	clone.modTime = node.modTime
+
+	for name, child := range node.syncedChildren {
+		c := crashClone(child)
+		clone.children[name] = c
+		clone.syncedChildren[name] = c
+	}
+
+	return clone
+}
+
+type memLock struct {
+	fs  *MemFS
+	key string
+}
+
+func (l *memLock) Close() error {
+	l.fs.mu.Lock()
+	defer l.fs.mu.Unlock()
+
+	delete(l.fs.locks, l.key)
+	return nil
+}
+
+type memFileInfo struct {
+	name    string
+	size    int64
+	isDir   bool
+	modTime time.Time
+}
+
+func (fi memFileInfo) Name() string       { return fi.name }
+func (fi memFileInfo) Size() int64        { return fi.size }
+func (fi memFileInfo) ModTime() time.Time { return fi.modTime }
+func (fi memFileInfo) IsDir() bool        { return fi.isDir }
+func (fi memFileInfo) Sys() any           { return nil }
+
+func (fi memFileInfo) Mode() os.FileMode {
+	if fi.isDir {
+		return fs.ModeDir | 0755
+	}
+
+	return 0644
+}
+
+// memFile is an open file of a MemFS. Its contents stay reachable through it after the file is
+// removed or replaced, as with an open file on Unix.
+type memFile struct {
+	fs       *MemFS
+	node     *memNode
+	name     string
+	offset   int64
+	readOnly bool
+	closed   bool
+}
+
+func (f *memFile) check(op string, write bool) error {
+	if f.closed {
+		return &fs.PathError{Op: op, Path: f.name, Err: fs.ErrClosed}
+	}
+
+	if write && f.readOnly {
+		return &fs.PathError{Op: op, Path: f.name, Err: fs.ErrPermission}
+	}
+
+	return nil
+}
+
+func (f *memFile) readAt(p []byte, off int64) (int, error) {
+	if off >= int64(len(f.node.data)) {
+		return 0, io.EOF
+	}
+
+	n := copy(p, f.node.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+func (f *memFile) Read(p []byte) (int, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	err := f.check("read", false)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	n, err := f.readAt(p, f.offset)
+	f.offset += int64(n)
+
+	if n > 0 {
+		return n, nil
+	}
+
+	return n, err
+}
+
+func (f *memFile) ReadAt(p []byte, off int64) (int, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	err := f.check("read", false)
+	if err != nil {
+		return 0, err
+	}
+
+	if off < 0 {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: fs.ErrInvalid}
+	}
+
+	return f.readAt(p, off)
+}
+
+func (f *memFile) Write(p []byte) (int, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	err := f.check("write", true)
+	if err != nil {
+		return 0, err
+	}
+
+	end := f.offset + int64(len(p))
+	if end > int64(len(f.node.data)) {
+		f.node.data = append(f.node.data, make([]byte, end-int64(len(f.node.data)))...)
+	}
+
+	copy(f.node.data[f.offset:], p)
+	f.offset = end
+	f.node.modTime = time.Now()
+	return len(p), nil
+}
+
+func (f *memFile) Seek(offset int64, whence int) (int64, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	err := f.check("seek", false)
+	if err != nil {
+		return 0, err
+	}
+
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += int64(len(f.node.data))
+	}
+
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+
+	f.offset = offset
+	return offset, nil
+}
+
+func (f *memFile) Truncate(size int64) error {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	err := f.check("truncate", true)
+	if err != nil {
+		return err
+	}
+
+	if size < 0 {
+		return &fs.PathError{Op: "truncate", Path: f.name, Err: fs.ErrInvalid}
+	}
+
+	if size <= int64(len(f.node.data)) {
+		f.node.data = f.node.data[:size:size]
+	} else {
+		f.node.data = append(f.node.data, make([]byte, size-int64(len(f.node.data)))...)
+	}
+
+	f.node.modTime = time.Now()
+	return nil
+}
+
+func (f *memFile) Sync() error {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	err := f.check("sync", false)
+	if err != nil {
+		return err
+	}
+
+	f.node.synced = append([]byte(nil), f.node.data...)
+	return nil
+}
+
+func (f *memFile) Close() error {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	err := f.check("close", false)
+	if err != nil {
+		return err
+	}
+
+	f.closed = true
+	return nil
+}
@@ -0,0 +1,132 @@
+// Package vfs abstracts the filesystem a Database is stored in, so that it can be kept in memory
+// for tests, or have faults injected into it to simulate crashes. Default is the operating
+// system's filesystem.
+package vfs
+
+import (
+	"io"
+	"os"
+	"sort"
+)
+
+// File is an open file of an FS.
+type File interface {
+	io.Reader
+	io.ReaderAt
+	io.Writer
+	io.Seeker
+	io.Closer
+
+	// Truncate changes the size of the file, without moving its offset.
+	Truncate(size int64) error
+
+	// Sync makes the contents of the file durable. It does not make the file's directory entry
+	// durable, which takes a SyncDir of its directory.
+	Sync() error
+}
+
+// FS is a filesystem. Names are paths in the style of the operating system, and errors wrap the
+// errors of package os, such as os.ErrNotExist, where they apply.
+type FS interface {
+	// Create creates the named file for reading and writing, truncating it if it already exists.
+	Create(name string) (File, error)
+
+	// Open opens the named file for reading.
+	Open(name string) (File, error)
+
+	// OpenReadWrite opens the named file for reading and writing, creating it if it does not exist.
+	OpenReadWrite(name string) (File, error)
+
+	// Rename moves oldname to newname, replacing any file already there.
+	Rename(oldname, newname string) error
+
+	// Remove removes the named file or empty directory.
+	Remove(name string) error
+
+	// RemoveAll removes the named file or directory along with everything it contains. It returns
+	// nil if name does not exist.
+	RemoveAll(name string) error
+
+	// MkdirAll creates the directory dir along with any parents it needs.
+	MkdirAll(dir string, perm os.FileMode) error
+
+	// List returns the names of the entries of dir, sorted.
+	List(dir string) ([]string, error)
+
+	// Stat describes the named file or directory.
+	Stat(name string) (os.FileInfo, error)
+
+	// SyncDir makes the entries created, renamed and removed in dir durable.
+	SyncDir(dir string) error
+
+	// Lock acquires an exclusive lock on the named file, creating it if needed, so that only one
+	// user at a time works within its directory. The lock is held until the returned Closer is
+	// closed, and acquiring a lock that is already held fails rather than waits.
+	Lock(name string) (io.Closer, error)
+}
+
+// Default is the operating system's filesystem.
+var Default FS = osFS{}
+
+type osFS struct{}
+
+func (osFS) Create(name string) (File, error) {
+	return os.Create(name)
+}
+
+func (osFS) Open(name string) (File, error) {
+	return os.Open(name)
+}
+
+func (osFS) OpenReadWrite(name string) (File, error) {
+	return os.OpenFile(name, os.O_RDWR|os.O_CREATE, 0644)
+}
+
+func (osFS) Rename(oldname, newname string) error {
+	return os.Rename(oldname, newname)
+}
+
+func (osFS) Remove(name string) error {
+	return os.Remove(name)
+}
+
+func (osFS) RemoveAll(name string) error {
+	return os.RemoveAll(name)
+}
+
+func (osFS) MkdirAll(dir string, perm os.FileMode) error {
+	return os.MkdirAll(dir, perm)
+}
+
+func (osFS) List(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, len(entries))
+	for i, e := range entries {
+		names[i] = e.Name()
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+func (osFS) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (osFS) SyncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
+
+func (osFS) Lock(name string) (io.Closer, error) {
+	return lockFile(name)
+}
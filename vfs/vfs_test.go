@@ -0,0 +1,291 @@
+package vfs
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeFile(t *testing.T, fs FS, name string, data []byte, sync bool) {
+	t.Helper()
+
+	f, err := fs.Create(name)
+	if err != nil {
+		t.Fatalf("unexpected error when creating %s: %s", name, err)
+	}
+
+	_, err = f.Write(data)
+	if err != nil {
+		t.Fatalf("unexpected error when writing %s: %s", name, err)
+	}
+
+	if sync {
+		err = f.Sync()
+		if err != nil {
+			t.Fatalf("unexpected error when syncing %s: %s", name, err)
+		}
+	}
+
+	err = f.Close()
+	if err != nil {
+		t.Fatalf("unexpected error when closing %s: %s", name, err)
+	}
+}
+
+func readFile(t *testing.T, fs FS, name string) []byte {
+	t.Helper()
+
+	f, err := fs.Open(name)
+	if err != nil {
+		t.Fatalf("unexpected error when opening %s: %s", name, err)
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatalf("unexpected error when reading %s: %s", name, err)
+	}
+
+	return data
+}
+
+func TestFS(t *testing.T) {
+	t.Run("os", func(t *testing.T) { testFS(t, Default, t.TempDir()) })
+	t.Run("mem", func(t *testing.T) { testFS(t, NewMem(), "/db") })
+}
+
+func testFS(t *testing.T, sys FS, dir string) {
+	sub := filepath.Join(dir, "sub", "dir")
+
+	err := sys.MkdirAll(sub, 0755)
+	if err != nil {
+		t.Fatalf("unexpected error when creating directories: %s", err)
+	}
+
+	a, b := filepath.Join(sub, "a"), filepath.Join(sub, "b")
+	writeFile(t, sys, a, []byte("alpha"), true)
+
+	if got := readFile(t, sys, a); string(got) != "alpha" {
+		t.Fatalf("expected contents %q, got %q", "alpha", got)
+	}
+
+	// Opening for reading and writing keeps the contents, and writes go where the offset is.
+	f, err := sys.OpenReadWrite(a)
+	if err != nil {
+		t.Fatalf("unexpected error when opening for writing: %s", err)
+	}
+
+	_, err = f.Seek(0, io.SeekEnd)
+	if err == nil {
+		_, err = f.Write([]byte("bet"))
+	}
+
+	if err == nil {
+		err = f.Truncate(6)
+	}
+
+	if err != nil {
+		t.Fatalf("unexpected error when appending: %s", err)
+	}
+
+	buf := make([]byte, 4)
+	n, err := f.ReadAt(buf, 3)
+	if n != 3 || !errors.Is(err, io.EOF) || string(buf[:n]) != "hab" {
+		t.Fatalf("expected short read %q at end of file, got %q: %v", "hab", buf[:n], err)
+	}
+
+	f.Close()
+
+	err = sys.Rename(a, b)
+	if err != nil {
+		t.Fatalf("unexpected error when renaming: %s", err)
+	}
+
+	_, err = sys.Open(a)
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected ErrNotExist when opening renamed file, got %v", err)
+	}
+
+	names, err := sys.List(sub)
+	if err != nil || !reflect.DeepEqual(names, []string{"b"}) {
+		t.Fatalf("expected directory to hold %q, got %q: %v", []string{"b"}, names, err)
+	}
+
+	info, err := sys.Stat(b)
+	if err != nil || info.IsDir() || info.Size() != 6 || info.Name() != "b" {
+		t.Fatalf("unexpected description of file: %v", err)
+	}
+
+	info, err = sys.Stat(sub)
+	if err != nil || !info.IsDir() {
+		t.Fatalf("expected directory to be described as one: %v", err)
+	}
+
+	err = sys.SyncDir(sub)
+	if err != nil {
+		t.Fatalf("unexpected error when syncing directory: %s", err)
+	}
+
+	// Only one lock on a file can be held at a time.
+	lockName := filepath.Join(dir, "LOCK")
+
+	lock, err := sys.Lock(lockName)
+	if err != nil {
+		t.Fatalf("unexpected error when locking: %s", err)
+	}
+
+	_, err = sys.Lock(lockName)
+	if err == nil {
+		t.Fatalf("expected error when locking a locked file")
+	}
+
+	lock.Close()
+
+	lock, err = sys.Lock(lockName)
+	if err != nil {
+		t.Fatalf("unexpected error when locking an unlocked file: %s", err)
+	}
+
+	lock.Close()
+
+	err = sys.Remove(filepath.Join(dir, "sub"))
+	if err == nil {
+		t.Fatalf("expected error when removing a non-empty directory")
+	}
+
+	err = sys.RemoveAll(filepath.Join(dir, "sub"))
+	if err != nil {
+		t.Fatalf("unexpected error when removing directories: %s", err)
+	}
+
+	_, err = sys.Stat(b)
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected ErrNotExist for removed file, got %v", err)
+	}
+
+	err = sys.RemoveAll(filepath.Join(dir, "sub"))
+	if err != nil {
+		t.Fatalf("unexpected error when removing missing directory: %s", err)
+	}
+}
+
+func TestMemFSCrashClone(t *testing.T) {
+	m := NewMem()
+
+	err := m.MkdirAll("db", 0755)
+	if err != nil {
+		t.Fatalf("unexpected error when creating directory: %s", err)
+	}
+
+	// Synced contents of a file in an unsynced directory are lost along with the directory.
+	writeFile(t, m, "db/lost", []byte("lost"), true)
+
+	if names, _ := m.CrashClone().List("/"); len(names) != 0 {
+		t.Fatalf("expected unsynced directory to be lost, got %q", names)
+	}
+
+	m.SyncDir("/")
+	m.SyncDir("db")
+
+	writeFile(t, m, "db/unsynced", []byte("unsynced"), false)
+	writeFile(t, m, "db/synced", []byte("synced"), true)
+
+	f, _ := m.OpenReadWrite("db/lost")
+	f.Seek(0, io.SeekEnd)
+	f.Write([]byte(" and more"))
+	f.Close()
+
+	crashed := m.CrashClone()
+
+	names, err := crashed.List("db")
+	if err != nil || !reflect.DeepEqual(names, []string{"lost"}) {
+		t.Fatalf("expected only entries from before the directory sync, got %q: %v", names, err)
+	}
+
+	if got := readFile(t, crashed, "db/lost"); string(got) != "lost" {
+		t.Fatalf("expected unsynced append to be dropped, got %q", got)
+	}
+
+	m.SyncDir("db")
+	m.Rename("db/synced", "db/renamed")
+	m.Remove("db/lost")
+
+	crashed = m.CrashClone()
+
+	names, _ = crashed.List("db")
+	if !reflect.DeepEqual(names, []string{"lost", "synced", "unsynced"}) {
+		t.Fatalf("expected unsynced rename and removal to be undone, got %q", names)
+	}
+
+	if got := readFile(t, crashed, "db/synced"); string(got) != "synced" {
+		t.Fatalf("expected synced contents, got %q", got)
+	}
+
+	if got := readFile(t, crashed, "db/unsynced"); len(got) != 0 {
+		t.Fatalf("expected unsynced file to be empty, got %q", got)
+	}
+
+	// The clone is independent of the original.
+	writeFile(t, crashed, "db/synced", []byte("changed"), true)
+
+	if got := readFile(t, m, "db/renamed"); string(got) != "synced" {
+		t.Fatalf("expected original to be unchanged, got %q", got)
+	}
+}
+
+func TestFaultFS(t *testing.T) {
+	m := NewMem()
+	f := NewFaultFS(m, FailNth(OpSync, 2))
+
+	writeFile(t, f, "a", []byte("alpha"), true)
+
+	file, err := f.Create("b")
+	if err != nil {
+		t.Fatalf("unexpected error when creating: %s", err)
+	}
+
+	file.Write([]byte("bravo"))
+
+	err = file.Sync()
+	if !errors.Is(err, InjectedError) {
+		t.Fatalf("expected InjectedError from second sync, got %v", err)
+	}
+
+	err = file.Sync()
+	if err != nil {
+		t.Fatalf("unexpected error from third sync: %s", err)
+	}
+
+	file.Close()
+
+	f.SetInjector(FailAfter(1))
+
+	info, err := f.Stat("b")
+	if err != nil || info.Size() != int64(len("bravo")) {
+		t.Fatalf("unexpected error before crash: %v", err)
+	}
+
+	_, err = f.Open("a")
+	if !errors.Is(err, InjectedError) {
+		t.Fatalf("expected InjectedError after crash, got %v", err)
+	}
+
+	err = f.Rename("a", "c")
+	if !errors.Is(err, InjectedError) {
+		t.Fatalf("expected InjectedError after crash, got %v", err)
+	}
+
+	f.SetInjector(nil)
+
+	if got := readFile(t, f, "b"); !bytes.Equal(got, []byte("bravo")) {
+		t.Fatalf("expected contents %q, got %q", "bravo", got)
+	}
+
+	if got := readFile(t, f, "a"); string(got) != "alpha" {
+		t.Fatalf("expected failed rename to leave file in place, got %q", got)
+	}
+}
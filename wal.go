@@ -0,0 +1,292 @@
+package levels
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// The write-ahead log uses LevelDB's log format, as described in its doc/log_format.md. The log is
+// a sequence of 32 KiB blocks. Records are split into fragments that never cross a block boundary,
+// each with a header holding a masked CRC32C checksum, its length and its type. A block with less
+// room left than a header is padded with zeros.
+const (
+	logBlockSize  = 32 << 10
+	logHeaderSize = 7
+
+	// zeroRecord marks space that was preallocated but never written.
+	zeroRecord   = 0
+	fullRecord   = 1
+	firstRecord  = 2
+	middleRecord = 3
+	lastRecord   = 4
+
+	// eofRecord and badRecord are returned by readFragment, and never appear in the log.
+	eofRecord = 5
+	badRecord = 6
+)
+
+var (
+	CorruptionError = errors.New("Corrupted log")
+
+	castagnoli = crc32.MakeTable(crc32.Castagnoli)
+)
+
+// logWriter appends records to a write-ahead log.
+type logWriter struct {
+	Writer io.Writer
+
+	// blockOffset is the position within the current block, for logs that are appended to.
+	blockOffset int
+}
+
+// newLogWriter returns a logWriter appending to w, which already holds offset bytes of log.
+func newLogWriter(w io.Writer, offset int64) *logWriter {
+	return &logWriter{Writer: w, blockOffset: int(offset % logBlockSize)}
+}
+
+func (w *logWriter) Append(record []byte) error {
+	buf := make([]byte, 0, len(record)+logHeaderSize)
+	blockOffset := w.blockOffset
+	data := record
+	begin := true
+
+	// An empty record is still written, as a single empty fragment.
+	for {
+		leftover := logBlockSize - blockOffset
+		if leftover < logHeaderSize {
+			buf = append(buf, make([]byte, leftover)...)
+			blockOffset = 0
+		}
+
+		n := min(len(data), logBlockSize-blockOffset-logHeaderSize)
+		end := n == len(data)
+
+		kind := byte(middleRecord)
+		switch {
+		case begin && end:
+			kind = fullRecord
+		case begin:
+			kind = firstRecord
+		case end:
+			kind = lastRecord
+		}
+
+		buf = appendFragment(buf, kind, data[:n])
+		blockOffset += logHeaderSize + n
+		data = data[n:]
+		begin = false
+
+		if end {
+			break
+		}
+	}
+
+	_, err := w.Writer.Write(buf)
+	if err != nil {
+		return fmt.Errorf("appending record of length %d to log: %w", len(record), err)
+	}
+
+	w.blockOffset = blockOffset
+	return nil
+}
+
+// maskCRC masks a checksum the way LevelDB does, since computing the CRC of data that contains
+// embedded CRCs is problematic.
+func maskCRC(crc uint32) uint32 {
+	return (crc>>15 | crc<<17) + 0xa282ead8
+}
+
+func appendFragment(buf []byte, kind byte, data []byte) []byte {
+	crc := crc32.Update(0, castagnoli, []byte{kind})
+	crc = crc32.Update(crc, castagnoli, data)
+
+	buf = binary.LittleEndian.AppendUint32(buf, maskCRC(crc))
+	buf = binary.LittleEndian.AppendUint16(buf, uint16(len(data)))
+	buf = append(buf, kind)
+	return append(buf, data...)
+}
+
+// logReader reads records from a write-ahead log. Corrupted fragments are skipped along with the
+// rest of their block, and reported with the number of bytes dropped. A truncated fragment at the
+// end of the log is not reported, since it is what a crash in the middle of a write leaves behind.
+type logReader struct {
+	r      io.Reader
+	report func(bytes int, err error)
+
+	block  [logBlockSize]byte
+	buf    []byte
+	eof    bool
+	offset int64
+
+	// recordEnd is the offset just past the last record returned.
+	recordEnd int64
+}
+
+func newLogReader(r io.Reader, report func(bytes int, err error)) *logReader {
+	if report == nil {
+		report = func(int, error) {}
+	}
+
+	return &logReader{r: r, report: report}
+}
+
+// Next returns the next record in the log, or io.EOF once there are none left.
+func (r *logReader) Next() ([]byte, error) {
+	var record []byte
+	fragmented := false
+
+	for {
+		kind, fragment, err := r.readFragment()
+		if err != nil {
+			return nil, err
+		}
+
+		switch kind {
+		case fullRecord:
+			if fragmented && len(record) > 0 {
+				r.report(len(record), fmt.Errorf("partial record without end: %w", CorruptionError))
+			}
+
+			r.recordEnd = r.offset - int64(len(r.buf))
+			return append([]byte(nil), fragment...), nil
+
+		case firstRecord:
+			if fragmented && len(record) > 0 {
+				r.report(len(record), fmt.Errorf("partial record without end: %w", CorruptionError))
+			}
+
+			record = append(record[:0], fragment...)
+			fragmented = true
+
+		case middleRecord:
+			if !fragmented {
+				r.report(len(fragment), fmt.Errorf("missing start of fragmented record: %w", CorruptionError))
+				break
+			}
+
+			record = append(record, fragment...)
+
+		case lastRecord:
+			if !fragmented {
+				r.report(len(fragment), fmt.Errorf("missing start of fragmented record: %w", CorruptionError))
+				break
+			}
+
+			r.recordEnd = r.offset - int64(len(r.buf))
+			return append(record, fragment...), nil
+
+		case eofRecord:
+			// A record cut short by the end of the log was never acknowledged, so it is dropped
+			// without being reported.
+			return nil, io.EOF
+
+		case badRecord:
+			if fragmented {
+				r.report(len(record), fmt.Errorf("error in middle of record: %w", CorruptionError))
+				fragmented = false
+				record = record[:0]
+			}
+
+		default:
+			dropped := len(fragment)
+			if fragmented {
+				dropped += len(record)
+			}
+
+			r.report(dropped, fmt.Errorf("unknown record type %d: %w", kind, CorruptionError))
+			fragmented = false
+			record = record[:0]
+		}
+	}
+}
+
+// readFragment returns the type and data of the next fragment, or eofRecord at the end of the log
+// and badRecord for fragments that were skipped.
+func (r *logReader) readFragment() (kind byte, fragment []byte, err error) {
+	for {
+		if len(r.buf) < logHeaderSize {
+			if r.eof {
+				// A truncated header at the end of the log is left by a crash while writing it.
+				r.buf = nil
+				return eofRecord, nil, nil
+			}
+
+			// Anything left in the block is the zero padding of a block trailer.
+			n, err := io.ReadFull(r.r, r.block[:])
+			if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+				return 0, nil, fmt.Errorf("reading log block: %w", err)
+			}
+
+			r.buf = r.block[:n]
+			r.offset += int64(n)
+			r.eof = n < logBlockSize
+			continue
+		}
+
+		header := r.buf[:logHeaderSize]
+		checksum := binary.LittleEndian.Uint32(header[0:4])
+		length := int(binary.LittleEndian.Uint16(header[4:6]))
+		kind = header[6]
+
+		if logHeaderSize+length > len(r.buf) {
+			dropped := len(r.buf)
+			r.buf = nil
+
+			if !r.eof {
+				r.report(dropped, fmt.Errorf("bad record length: %w", CorruptionError))
+				return badRecord, nil, nil
+			}
+
+			return eofRecord, nil, nil
+		}
+
+		if kind == zeroRecord && length == 0 {
+			r.buf = nil
+			return badRecord, nil, nil
+		}
+
+		fragment = r.buf[logHeaderSize : logHeaderSize+length]
+
+		crc := crc32.Update(0, castagnoli, header[6:7])
+		crc = crc32.Update(crc, castagnoli, fragment)
+
+		if maskCRC(crc) != checksum {
+			// The length may be what is corrupted, so nothing else in the block can be trusted.
+			dropped := len(r.buf)
+			r.buf = nil
+			r.report(dropped, fmt.Errorf("checksum mismatch: %w", CorruptionError))
+			return badRecord, nil, nil
+		}
+
+		r.buf = r.buf[logHeaderSize+length:]
+		return kind, fragment, nil
+	}
+}
+
+// readLog returns every intact record in r, along with the offset just past the last of them and
+// the number of bytes of corrupted records that were skipped, which are also passed to report.
+func readLog(r io.Reader, report func(bytes int, err error)) (records [][]byte, validOffset int64, dropped int, err error) {
+	reader := newLogReader(r, func(bytes int, err error) {
+		dropped += bytes
+
+		if report != nil {
+			report(bytes, err)
+		}
+	})
+
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			return records, reader.recordEnd, dropped, nil
+		}
+
+		if err != nil {
+			return nil, 0, 0, err
+		}
+
+		records = append(records, record)
+	}
+}
@@ -0,0 +1,177 @@
+package levels
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeLog(t *testing.T, records ...[]byte) []byte {
+	var buf bytes.Buffer
+	w := newLogWriter(&buf, 0)
+
+	for _, record := range records {
+		err := w.Append(record)
+		if err != nil {
+			t.Fatalf("unexpected error when appending: %s", err)
+		}
+	}
+
+	return buf.Bytes()
+}
+
+func TestLogFragments(t *testing.T) {
+	// The records fill the first block up to 3 bytes before its end, forcing padding, then span
+	// the second block entirely, needing FIRST, MIDDLE and LAST fragments.
+	records := [][]byte{
+		bytes.Repeat([]byte("a"), 1000),
+		bytes.Repeat([]byte("b"), logBlockSize-1000-3*logHeaderSize-3),
+		{},
+		bytes.Repeat([]byte("c"), 2*logBlockSize+100),
+		[]byte("d"),
+	}
+
+	data := writeLog(t, records...)
+
+	if kind := data[logBlockSize+6]; kind != firstRecord {
+		t.Fatalf("expected first fragment at start of second block, got type %d", kind)
+	}
+
+	if kind := data[2*logBlockSize+6]; kind != middleRecord {
+		t.Fatalf("expected middle fragment at start of third block, got type %d", kind)
+	}
+
+	got, validOffset, dropped, err := readLog(bytes.NewReader(data), nil)
+	if err != nil || dropped != 0 {
+		t.Fatalf("unexpected error when reading log: %v, %d bytes dropped", err, dropped)
+	}
+
+	if len(got) != len(records) || validOffset != int64(len(data)) {
+		t.Fatalf("expected %d records up to %d, got %d up to %d", len(records), len(data), len(got), validOffset)
+	}
+
+	for i := range records {
+		if !bytes.Equal(got[i], records[i]) {
+			t.Fatalf("unexpected record %d of length %d", i, len(got[i]))
+		}
+	}
+
+	// A record cut short by a crash is dropped without being reported.
+	got, validOffset, dropped, err = readLog(bytes.NewReader(data[:len(data)-1]), nil)
+	if err != nil || dropped != 0 || len(got) != len(records)-1 {
+		t.Fatalf("expected torn record to be dropped silently, got %d records, %d bytes dropped: %v", len(got), dropped, err)
+	}
+
+	if validOffset != int64(len(data)-logHeaderSize-1) {
+		t.Fatalf("expected valid offset before torn record, got %d", validOffset)
+	}
+}
+
+func TestLogCorruption(t *testing.T) {
+	var records [][]byte
+	for i := 0; i < 100; i++ {
+		records = append(records, []byte(fmt.Sprintf("record%03d", i)+string(bytes.Repeat([]byte("x"), 1000))))
+	}
+
+	data := writeLog(t, records...)
+	data[10]++
+
+	var reported []error
+	got, _, dropped, err := readLog(bytes.NewReader(data), func(bytes int, err error) {
+		reported = append(reported, err)
+	})
+
+	if err != nil {
+		t.Fatalf("unexpected error when reading log: %s", err)
+	}
+
+	// The checksum mismatch drops the rest of the first block, and the record spanning into the
+	// second block is missing its start.
+	if dropped == 0 || len(reported) != 2 || !errors.Is(reported[0], CorruptionError) {
+		t.Fatalf("expected two reported corruptions, got %v with %d bytes dropped", reported, dropped)
+	}
+
+	perBlock := logBlockSize / (logHeaderSize + len(records[0]))
+	if len(got) != len(records)-perBlock-1 || !bytes.Equal(got[0], records[perBlock+1]) {
+		t.Fatalf("expected records after the first block, got %d records", len(got))
+	}
+}
+
+func TestDatabaseLogCorruption(t *testing.T) {
+	dir := t.TempDir()
+
+	db, err := OpenDatabase(dir, DatabaseOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error when opening database: %s", err)
+	}
+
+	cf, _ := db.ColumnFamily(DefaultColumnFamily)
+	value := bytes.Repeat([]byte("v"), 1000)
+
+	for i := 0; i < 100; i++ {
+		err = db.Put(cf, []byte(fmt.Sprintf("key%03d", i)), value)
+		if err != nil {
+			t.Fatalf("unexpected error when putting: %s", err)
+		}
+	}
+
+	db.Close()
+
+	path := filepath.Join(dir, logFileName)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error when reading log: %s", err)
+	}
+
+	data[10]++
+
+	err = os.WriteFile(path, data, 0644)
+	if err != nil {
+		t.Fatalf("unexpected error when writing log: %s", err)
+	}
+
+	dropped := 0
+	db, err = OpenDatabase(dir, DatabaseOptions{ReportCorruption: func(bytes int, err error) { dropped += bytes }})
+	if err != nil {
+		t.Fatalf("unexpected error when reopening database: %s", err)
+	}
+
+	cf, _ = db.ColumnFamily(DefaultColumnFamily)
+
+	if dropped == 0 {
+		t.Fatalf("expected corruption to be reported")
+	}
+
+	if _, err = db.Get(cf, []byte("key000")); !errors.Is(err, KeyError) {
+		t.Fatalf("expected key in corrupted block to be lost, got %v", err)
+	}
+
+	if v, err := db.Get(cf, []byte("key099")); err != nil || !bytes.Equal(v, value) {
+		t.Fatalf("expected key after corrupted block to be recovered: %v", err)
+	}
+
+	// Writes after recovery must survive another reopen.
+	err = db.Put(cf, []byte("after"), value)
+	if err != nil {
+		t.Fatalf("unexpected error when putting: %s", err)
+	}
+
+	db.Close()
+
+	db, err = OpenDatabase(dir, DatabaseOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error when reopening database: %s", err)
+	}
+	defer db.Close()
+
+	cf, _ = db.ColumnFamily(DefaultColumnFamily)
+
+	for _, key := range []string{"key099", "after"} {
+		if _, err := db.Get(cf, []byte(key)); err != nil {
+			t.Fatalf("unexpected error when getting %q after reopening: %s", key, err)
+		}
+	}
+}